@@ -25,10 +25,13 @@
 package go_ipay
 
 import (
+	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/stremovskyy/go-ipay/consts"
+	"github.com/stremovskyy/go-ipay/idempotency"
 	"github.com/stremovskyy/go-ipay/internal/http"
 	"github.com/stremovskyy/go-ipay/ipay"
 	"github.com/stremovskyy/go-ipay/log"
@@ -36,28 +39,40 @@ import (
 )
 
 type client struct {
-	ipayClient *http.Client
+	ipayClient     *http.Client
+	retryPolicy    RetryPolicy
+	idempotency    idempotency.Store
+	inFlight       time.Duration
+	amountLimits   AmountLimits
+	skipValidation bool
 }
 
 func (c *client) SetLogLevel(levelDebug log.Level) {
-	log.SetLevel(levelDebug)
+	c.ipayClient.SetLogLevel(levelDebug)
+}
+
+func (c *client) LogSink() *log.Sink {
+	return c.ipayClient.Sink()
 }
 
 func NewDefaultClient() Ipay {
 	return &client{
-		ipayClient: http.NewClient(http.DefaultOptions()),
+		ipayClient:  http.NewClient(http.DefaultOptions()),
+		retryPolicy: DefaultRetryPolicy(),
 	}
 }
 
 func NewClientWithRecorder(rec recorder.Recorder) Ipay {
 	return &client{
-		ipayClient: http.NewClient(http.DefaultOptions()).WithRecorder(rec),
+		ipayClient:  http.NewClient(http.DefaultOptions()).WithRecorder(rec),
+		retryPolicy: DefaultRetryPolicy(),
 	}
 }
 
 func NewClient(options ...Option) Ipay {
 	c := &client{
-		ipayClient: http.NewClient(http.DefaultOptions()),
+		ipayClient:  http.NewClient(http.DefaultOptions()),
+		retryPolicy: DefaultRetryPolicy(),
 	}
 
 	for _, option := range options {
@@ -68,95 +83,167 @@ func NewClient(options ...Option) Ipay {
 }
 
 func (c *client) VerificationLink(request *Request, runOpts ...RunOption) (*url.URL, error) {
+	return c.VerificationLinkContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) VerificationLinkContext(ctx context.Context, request *Request, runOpts ...RunOption) (*url.URL, error) {
+	apiResponse, err := c.verification(ctx, request, runOpts)
+	if err != nil || apiResponse == nil {
+		return nil, err
+	}
+
+	if apiResponse.Url == "" {
+		return nil, fmt.Errorf("verification link: empty URL in API response")
+	}
+
+	u, err := url.Parse(apiResponse.Url)
+	if err != nil {
+		return nil, fmt.Errorf("verification link URL parsing: %w", err)
+	}
+
+	return u, nil
+}
+
+// verification sends the CreateToken3DS request behind VerificationLink. A dry run returns nil, nil.
+func (c *client) verification(ctx context.Context, request *Request, runOpts []RunOption) (*ipay.Response, error) {
+	return c.createToken(ctx, ipay.ActionCreateToken3DS, consts.VerificationLink, request, runOpts)
+}
+
+// createToken sends a CreateToken or CreateToken3DS request, which opens a card entry page that
+// saves the card without charging it. A dry run returns nil, nil.
+func (c *client) createToken(ctx context.Context, action ipay.Action, operation string, request *Request, runOpts []RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(operation, func(v *validator) { v.verification(request) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	createTokenRequest := ipay.NewRequest(
-		ipay.ActionCreateToken3DS,
+		action,
 		ipay.WithLanguage(ipay.LangUk),
 		ipay.WithAuth(request.GetAuth()),
 		ipay.WithInvoiceInTransactions(request.GetAmount(), request.GetSubMerchantID()),
 		ipay.WithRedirects(request.GetRedirects()),
 		ipay.WithPersonalData(request.GetPersonalData()),
 		ipay.WithPaymentID(request.GetPaymentID()),
-		ipay.WithAmount(0),
 		ipay.WithWebhookURL(request.GetWebhookURL()),
 		ipay.WithDescription(request.GetDescription()),
-		ipay.WithOutAmount(true),
 		ipay.WithAML(request.GetAML()),
 		ipay.WithMetadata(request.GetMetadata()),
-		ipay.WithOperationOperation(consts.VerificationLink),
+		ipay.WithOperationOperation(operation),
 	)
 
-	if opts.isDryRun() {
-		opts.handleDryRun(consts.ApiUrl, createTokenRequest)
-		return nil, nil
+	if request.VerifiesWithAmount() {
+		ipay.WithAmount(request.GetAmount())(createTokenRequest)
+		ipay.WithOutAmount(false)(createTokenRequest)
+	} else {
+		ipay.WithAmount(0)(createTokenRequest)
+		ipay.WithOutAmount(true)(createTokenRequest)
 	}
 
-	apiResponse, err := c.ipayClient.Api(createTokenRequest)
-	if err != nil {
-		return nil, fmt.Errorf("verification link API call: %w", err)
+	if request.HasCardData() {
+		cdata, err := request.cardData(c.ipayClient.Logger("cipher"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operation, err)
+		}
+
+		ipay.WithCardData(cdata)(createTokenRequest)
 	}
 
-	if apiResponse == nil || apiResponse.Url == "" {
-		return nil, fmt.Errorf("verification link: empty URL in API response")
+	if opts.isDryRun() {
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Api, createTokenRequest)
+		return nil, nil
 	}
 
-	u, err := url.Parse(apiResponse.Url)
+	apiResponse, err := c.ipayClient.Api(c.retryContext(ctx, opts, false), createTokenRequest)
 	if err != nil {
-		return nil, fmt.Errorf("verification link URL parsing: %w", err)
+		return nil, fmt.Errorf("%s API call: %w", operation, err)
 	}
 
-	return u, nil
+	return apiResponse, nil
 }
 
 func (c *client) Status(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.StatusContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) StatusContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.Status, func(v *validator) { v.lookup(request, false) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
-	statusRequest := ipay.NewRequest(
-		ipay.ActionGetPaymentStatus,
+	options := []func(*ipay.RequestWrapper){
 		ipay.WithLanguage(ipay.LangUk),
 		ipay.WithAuth(request.GetAuth()),
-		ipay.WithIpayPaymentID(request.GetIpayPaymentID()),
 		ipay.WithWebhookURL(request.GetWebhookURL()),
 		ipay.WithOperationOperation(consts.Status),
-	)
+	}
+
+	// Without pmt_id the payment is looked up by the merchant's ext_id.
+	if extID := request.GetPaymentID(); request.GetIpayPaymentID() == 0 && extID != nil && *extID != "" {
+		options = append(options, ipay.WithExtID(extID))
+	} else {
+		options = append(options, ipay.WithIpayPaymentID(request.GetIpayPaymentID()))
+	}
+
+	statusRequest := ipay.NewRequest(ipay.ActionGetPaymentStatus, options...)
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.ApiUrl, statusRequest)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Api, statusRequest)
 		return nil, nil
 	}
 
-	return c.ipayClient.Api(statusRequest)
+	return c.ipayClient.Api(c.retryContext(ctx, opts, true), statusRequest)
 }
 
 func (c *client) PaymentURL(request *Request, runOpts ...RunOption) (*ipay.PaymentResponse, error) {
+	return c.PaymentURLContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) PaymentURLContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.PaymentResponse, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.PaymentURL, func(v *validator) { v.paymentURL(request) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	XMLPaymentURLRequest := ipay.CreateXMLPaymentCreateRequest()
 	XMLPaymentURLRequest.SetAuth(request.GetAuth())
 	XMLPaymentURLRequest.SetRedirects(request.GetRedirects())
 	XMLPaymentURLRequest.AddTransaction(request.GetTransaction())
 	XMLPaymentURLRequest.SetPersonalData(request.GetPersonalData())
-	XMLPaymentURLRequest.AddCardToken(request.GetCardToken())
+
+	if request.HasCardData() {
+		cdata, err := request.cardData(c.ipayClient.Logger("cipher"))
+		if err != nil {
+			return nil, fmt.Errorf("payment URL: %w", err)
+		}
+
+		XMLPaymentURLRequest.AddCardData(cdata)
+	} else {
+		XMLPaymentURLRequest.AddCardToken(request.GetCardToken())
+	}
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.ApiXMLUrl, XMLPaymentURLRequest)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().ApiXML, XMLPaymentURLRequest)
 		return nil, nil
 	}
 
-	apiResponse, err := c.ipayClient.ApiXML(XMLPaymentURLRequest)
+	apiResponse, err := c.ipayClient.ApiXML(ctx, XMLPaymentURLRequest)
 	if err != nil {
 		return nil, fmt.Errorf("payment URL API call: %w", err)
 	}
@@ -165,37 +252,53 @@ func (c *client) PaymentURL(request *Request, runOpts ...RunOption) (*ipay.Payme
 }
 
 func (c *client) Payment(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.PaymentContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) PaymentContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.Payment, func(v *validator) { v.payment(request) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	if request.IsMobile() {
-		return c.handleMobilePayment(request, false, opts)
+		return c.handleMobilePayment(ctx, request, false, opts)
 	}
 
-	return c.handleStandardPayment(request, false, opts)
+	return c.handleStandardPayment(ctx, request, false, opts)
 }
 
 func (c *client) Hold(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.HoldContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) HoldContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.Hold, func(v *validator) { v.payment(request) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	if request.IsMobile() {
-		return c.handleMobilePayment(request, true, opts)
+		return c.handleMobilePayment(ctx, request, true, opts)
 	}
 
-	return c.handleStandardPayment(request, true, opts)
+	return c.handleStandardPayment(ctx, request, true, opts)
 }
 
-func (c *client) handleMobilePayment(request *Request, isPreauth bool, runOpts *runOptions) (*ipay.Response, error) {
+func (c *client) handleMobilePayment(ctx context.Context, request *Request, isPreauth bool, runOpts *runOptions) (*ipay.Response, error) {
 	var (
 		paymentRequest *ipay.RequestWrapper
-		apiFunc        func(*ipay.RequestWrapper) (*ipay.Response, error)
+		apiFunc        func(context.Context, *ipay.RequestWrapper) (*ipay.Response, error)
 		endpoint       string
 	)
 
@@ -215,6 +318,7 @@ func (c *client) handleMobilePayment(request *Request, isPreauth bool, runOpts *
 		ipay.WithPersonalData(request.GetPersonalData()),
 		ipay.WithMetadata(request.GetMetadata()),
 		ipay.WithReceiver(request.GetReceiver()),
+		ipay.WithSender(request.GetSender()),
 		ipay.WithAML(request.GetAML()),
 	}
 
@@ -242,7 +346,7 @@ func (c *client) handleMobilePayment(request *Request, isPreauth bool, runOpts *
 		}
 
 		apiFunc = c.ipayClient.ApplePayApi
-		endpoint = consts.ApplePayUrl
+		endpoint = c.ipayClient.Endpoints().ApplePay
 
 	case request.IsGooglePay():
 		if request.HasRecurrent() {
@@ -258,7 +362,7 @@ func (c *client) handleMobilePayment(request *Request, isPreauth bool, runOpts *
 		}
 
 		apiFunc = c.ipayClient.GooglePayApi
-		endpoint = consts.GooglePayUrl
+		endpoint = c.ipayClient.Endpoints().GooglePay
 
 	default:
 		return nil, fmt.Errorf("unsupported mobile payment type")
@@ -266,14 +370,24 @@ func (c *client) handleMobilePayment(request *Request, isPreauth bool, runOpts *
 
 	common = append(common, ipay.WithOperationOperation(operationKind))
 
+	if request.HasSplits() {
+		common = append(common, ipay.WithTransactions(request.GetSplitTransactions(true, false)))
+	}
+
 	paymentRequest = ipay.NewRequest(ipay.MobilePaymentCreate, common...)
 
 	if runOpts.isDryRun() {
-		runOpts.handleDryRun(endpoint, paymentRequest)
+		runOpts.handleDryRun(ctx, endpoint, paymentRequest)
 		return nil, nil
 	}
 
-	apiResponse, err := apiFunc(paymentRequest)
+	apiResponse, err := guarded(
+		ctx, c, paymentOperation(isPreauth), request.Merchant, request.GetPaymentID(),
+		func(ctx context.Context) (*ipay.Response, error) {
+			return apiFunc(c.retryContext(ctx, runOpts, false), paymentRequest)
+		},
+		c.reconcilePayment(request),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("mobile payment API call: %w", err)
 	}
@@ -281,7 +395,7 @@ func (c *client) handleMobilePayment(request *Request, isPreauth bool, runOpts *
 	return apiResponse, nil
 }
 
-func (c *client) handleStandardPayment(request *Request, preauth bool, runOpts *runOptions) (*ipay.Response, error) {
+func (c *client) handleStandardPayment(ctx context.Context, request *Request, preauth bool, runOpts *runOptions) (*ipay.Response, error) {
 	if request == nil {
 		return nil, fmt.Errorf("standard payment: %w", ErrRequestIsNil)
 	}
@@ -309,28 +423,54 @@ func (c *client) handleStandardPayment(request *Request, preauth bool, runOpts *
 		options = append(options, ipay.WithRecurrent(true))
 	}
 
-	if request.HasRecurrent() {
+	switch {
+	case request.HasRecurrent():
 		options = append(options, ipay.WithRecurrentToken(request.GetRecurrentToken()))
-	} else {
+	case request.HasCardData():
+		cdata, err := request.cardData(c.ipayClient.Logger("cipher"))
+		if err != nil {
+			return nil, fmt.Errorf("standard payment: %w", err)
+		}
+
+		options = append(options, ipay.WithCardData(cdata))
+	default:
 		options = append(options, ipay.WithCardToken(request.GetCardToken()))
 	}
 
+	if request.HasSplits() {
+		options = append(options, ipay.WithTransactions(request.GetSplitTransactions(true, true)))
+	}
+
 	holdRequest := ipay.NewRequest(ipay.ActionDebiting, options...)
 
 	if runOpts.isDryRun() {
-		runOpts.handleDryRun(consts.ApiUrl, holdRequest)
+		runOpts.handleDryRun(ctx, c.ipayClient.Endpoints().Api, holdRequest)
 		return nil, nil
 	}
 
-	return c.ipayClient.Api(holdRequest)
+	return guarded(
+		ctx, c, paymentOperation(preauth), request.Merchant, request.GetPaymentID(),
+		func(ctx context.Context) (*ipay.Response, error) {
+			return c.ipayClient.Api(c.retryContext(ctx, runOpts, false), holdRequest)
+		},
+		c.reconcilePayment(request),
+	)
 }
 
 func (c *client) Capture(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.CaptureContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) CaptureContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, fmt.Errorf("capture: %w", ErrRequestIsNil)
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.Capture, func(v *validator) { v.capture(request, true) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	options := []func(*ipay.RequestWrapper){
 		ipay.WithAuth(request.GetAuth()),
@@ -349,46 +489,98 @@ func (c *client) Capture(request *Request, runOpts ...RunOption) (*ipay.Response
 		options = append(options, ipay.WithAML(request.GetAML()))
 	}
 
+	if request.HasSplits() {
+		options = append(options, ipay.WithTransactions(request.GetSplitTransactions(false, true)))
+	}
+
 	captureRequest := ipay.NewRequest(ipay.ActionCompletion, options...)
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.ApiUrl, captureRequest)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Api, captureRequest)
 		return nil, nil
 	}
 
-	return c.ipayClient.Api(captureRequest)
+	return c.ipayClient.Api(c.retryContext(ctx, opts, false), captureRequest)
 }
 
 func (c *client) Refund(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.RefundContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) RefundContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	response, _, err := c.refund(ctx, request, runOpts)
+
+	return response, err
+}
+
+// refund reverses PaymentData.Amount of the payment, or its whole balance when the amount is 0.
+// Split refunds return each part to its sub-merchant. The payment is looked up with Status first
+// to tell a void of a hold from a refund of a settled payment and, unless SkipRefundBalanceCheck
+// is given, to reject amounts above the refundable balance.
+func (c *client) refund(ctx context.Context, request *Request, runOpts []RunOption) (*ipay.Response, *refundBalance, error) {
 	if request == nil {
-		return nil, fmt.Errorf("refund: %w", ErrRequestIsNil)
+		return nil, nil, fmt.Errorf("refund: %w", ErrRequestIsNil)
+	}
+
+	if err := c.validate(consts.Refund, func(v *validator) { v.capture(request, false) }); err != nil {
+		return nil, nil, err
 	}
 
-	opts := collectRunOptions(runOpts)
+	opts := c.collectRunOptions(runOpts)
 
-	refundRequest := ipay.NewRequest(
-		ipay.ActionReversal,
+	options := []func(*ipay.RequestWrapper){
 		ipay.WithAuth(request.GetAuth()),
 		ipay.WithIpayPaymentID(request.GetIpayPaymentID()),
 		ipay.WithWebhookURL(request.GetWebhookURL()),
 		ipay.WithMetadata(request.GetMetadata()),
 		ipay.WithOperationOperation(consts.Refund),
-	)
+	}
+
+	amount := request.GetAmount()
+	if amount > 0 {
+		options = append(options, ipay.WithAmountInTransactions(amount, request.GetSubMerchantID()))
+	}
+
+	if request.HasSplits() {
+		options = append(options, ipay.WithTransactions(request.GetSplitTransactions(false, true)))
+	}
+
+	refundRequest := ipay.NewRequest(ipay.ActionReversal, options...)
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.ApiUrl, refundRequest)
-		return nil, nil
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Api, refundRequest)
+		return nil, nil, nil
 	}
 
-	return c.ipayClient.Api(refundRequest)
+	balance, err := c.refundBalance(ctx, request, opts.refundedBefore())
+	if opts.checksRefundBalance() {
+		if err == nil {
+			err = balance.check(amount)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("refund: %w", err)
+		}
+	}
+
+	response, err := c.ipayClient.Api(c.retryContext(ctx, opts, false), refundRequest)
+
+	return response, balance, err
 }
 
 func (c *client) Credit(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.CreditContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) CreditContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, fmt.Errorf("credit: %w", ErrRequestIsNil)
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.Credit, func(v *validator) { v.credit(request) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	options := []func(*ipay.RequestWrapper){
 		ipay.WithAuth(request.GetAuth()),
@@ -397,6 +589,9 @@ func (c *client) Credit(request *Request, runOpts ...RunOption) (*ipay.Response,
 		ipay.WithWebhookURL(request.GetWebhookURL()),
 		ipay.WithTrackingData(request.GetTrackingData()),
 		ipay.WithReceiver(request.GetReceiver()),
+		ipay.WithSender(request.GetSender()),
+		ipay.WithAML(request.GetAML()),
+		ipay.WithCvd(request.GetReceiverCvd()),
 		ipay.WithMetadata(request.GetMetadata()),
 		ipay.WithOperationOperation(consts.Credit),
 		ipay.WithRelatedIDs(request.GetRelatedIDs()),
@@ -413,13 +608,20 @@ func (c *client) Credit(request *Request, runOpts ...RunOption) (*ipay.Response,
 	creditRequest := ipay.NewRequest(ipay.ActionCredit, options...)
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.ApiUrl, creditRequest)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Api, creditRequest)
 		return nil, nil
 	}
 
-	response, err := c.ipayClient.Api(creditRequest)
+	response, err := guarded(
+		ctx, c, consts.Credit, request.Merchant, request.GetPaymentID(),
+		func(ctx context.Context) (*ipay.Response, error) {
+			return c.ipayClient.Api(c.retryContext(ctx, opts, false), creditRequest)
+		},
+		c.reconcileCredit(request),
+	)
 	if err != nil {
-		return nil, fmt.Errorf("credit API call: %w", err)
+		// The response is kept, so that a declined transfer still carries its res_auth_code.
+		return response, fmt.Errorf("credit API call: %w", err)
 	}
 
 	if response == nil {
@@ -430,11 +632,19 @@ func (c *client) Credit(request *Request, runOpts ...RunOption) (*ipay.Response,
 }
 
 func (c *client) A2CPaymentStatus(request *Request, runOpts ...RunOption) (*ipay.Response, error) {
+	return c.A2CPaymentStatusContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) A2CPaymentStatusContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
 
-	runOptions := collectRunOptions(runOpts)
+	if err := c.validate(consts.A2CPaymentStatus, func(v *validator) { v.lookup(request, true) }); err != nil {
+		return nil, err
+	}
+
+	runOptions := c.collectRunOptions(runOpts)
 
 	extID := request.GetPaymentID()
 	pmtID := request.GetIpayPaymentID()
@@ -460,9 +670,9 @@ func (c *client) A2CPaymentStatus(request *Request, runOpts ...RunOption) (*ipay
 	statusRequest := ipay.NewRequest(ipay.ActionA2CPaymentStatus, opts...)
 
 	if runOptions.isDryRun() {
-		runOptions.handleDryRun(consts.ApiUrl, statusRequest)
+		runOptions.handleDryRun(ctx, c.ipayClient.Endpoints().Api, statusRequest)
 		return nil, nil
 	}
 
-	return c.ipayClient.Api(statusRequest)
+	return c.ipayClient.Api(c.retryContext(ctx, runOptions, true), statusRequest)
 }
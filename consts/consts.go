@@ -41,6 +41,7 @@ const (
 const (
 	VerificationLink           = "VerificationLink"
 	Status                     = "Status"
+	PaymentURL                 = "PaymentURL"
 	Payment                    = "Payment"
 	Hold                       = "Hold"
 	Capture                    = "Capture"
@@ -53,4 +54,6 @@ const (
 	CancelRepayment            = "CancelRepayment"
 	GetRepaymentStatus         = "GetRepaymentStatus"
 	GetRepaymentProcessingFile = "GetRepaymentProcessingFile"
+	CreateToken                = "CreateToken"
+	CreateToken3DS             = "CreateToken3DS"
 )
@@ -0,0 +1,43 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+
+	"github.com/stremovskyy/go-ipay/internal/http"
+)
+
+// WithRequestID returns a copy of ctx carrying requestID. Calls made with such a context
+// send it as X-Request-ID and pass it to the recorder instead of generating a new one,
+// so library activity can be correlated with the caller's own logs.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return http.ContextWithRequestID(ctx, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored on ctx by WithRequestID or by the library itself.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return http.RequestIDFromContext(ctx)
+}
@@ -0,0 +1,95 @@
+package go_ipay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/consts"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+func TestStatusContext_PropagatesContextAndRequestID(t *testing.T) {
+	const requestID = "caller-request-id"
+
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if got := req.Header.Get("X-Request-ID"); got != requestID {
+			t.Fatalf("X-Request-ID = %q, want %q", got, requestID)
+		}
+		if _, ok := req.Context().Deadline(); !ok {
+			t.Fatalf("request context has no deadline, caller context was not propagated")
+		}
+		if got, ok := RequestIDFromContext(req.Context()); !ok || got != requestID {
+			t.Fatalf("request context request ID = %q (%v), want %q", got, ok, requestID)
+		}
+
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5}}`)), nil
+	})
+
+	cl := NewClient(WithClient(&http.Client{Transport: rt}))
+
+	ctx, cancel := context.WithTimeout(WithRequestID(context.Background(), requestID), time.Minute)
+	defer cancel()
+
+	resp, err := cl.StatusContext(ctx, &Request{
+		Merchant:    &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData: &PaymentData{IpayPaymentID: utils.Ref(int64(1))},
+	})
+	if err != nil {
+		t.Fatalf("StatusContext() error: %v", err)
+	}
+	if got := resp.GetPaymentStatus(); got != ipay.PaymentStatusSuccess {
+		t.Fatalf("status = %d, want %d", got, ipay.PaymentStatusSuccess)
+	}
+}
+
+func TestPaymentContext_Canceled(t *testing.T) {
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	})
+
+	cl := NewClient(WithClient(&http.Client{Transport: rt}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cl.PaymentContext(ctx, &Request{
+		Merchant:      &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData:   &PaymentData{Amount: 100, PaymentID: utils.Ref("ext")},
+		PaymentMethod: &PaymentMethod{Card: &Card{Token: utils.Ref("token")}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDryRunContext_ReceivesRequestID(t *testing.T) {
+	cl := NewDefaultClient()
+
+	var (
+		gotEndpoint  string
+		gotRequestID string
+	)
+
+	_, err := cl.RefundContext(WithRequestID(context.Background(), "dry-run-id"), &Request{
+		Merchant:    &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData: &PaymentData{IpayPaymentID: utils.Ref(int64(1))},
+	}, DryRunContext(func(ctx context.Context, endpoint string, payload any) {
+		gotEndpoint = endpoint
+		gotRequestID, _ = RequestIDFromContext(ctx)
+	}))
+	if err != nil {
+		t.Fatalf("RefundContext() error: %v", err)
+	}
+
+	if gotEndpoint != consts.ApiUrl {
+		t.Fatalf("endpoint = %q, want %q", gotEndpoint, consts.ApiUrl)
+	}
+	if gotRequestID != "dry-run-id" {
+		t.Fatalf("request ID = %q, want %q", gotRequestID, "dry-run-id")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidAmount is returned when an amount cannot be represented exactly in minor units.
+	ErrInvalidAmount = errors.New("currency: invalid amount")
+	// ErrOverflow is returned when an amount or the result of an operation does not fit in int64.
+	ErrOverflow = errors.New("currency: amount overflows int64")
+	// ErrMismatch is returned when combining amounts in different currencies.
+	ErrMismatch = errors.New("currency: currency mismatch")
+)
+
+// Money is an amount in the minor units of its currency, e.g. kopecks for UAH.
+type Money struct {
+	Amount   int64
+	Currency Code
+}
+
+// New returns Money of amount minor units.
+func New(amount int64, code Code) Money {
+	return Money{Amount: amount, Currency: code}
+}
+
+// Parse parses a decimal amount in major units, e.g. "12.5" UAH is 1250 kopecks. The amount is
+// parsed as text so no float rounding is involved; digits beyond the currency exponent are only
+// accepted when they are zeros.
+func Parse(amount string, code Code) (Money, error) {
+	s := strings.TrimSpace(amount)
+
+	negative := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+
+	exp := code.Exponent()
+	if len(frac) > exp {
+		if strings.TrimRight(frac[exp:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, amount, exp)
+		}
+		frac = frac[:exp]
+	}
+
+	digits := strings.TrimLeft(whole+frac+strings.Repeat("0", exp-len(frac)), "0")
+	if digits == "" {
+		return New(0, code), nil
+	}
+
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || n > math.MaxInt64+1 || n == math.MaxInt64+1 && !negative {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, amount)
+	}
+
+	if negative {
+		return New(-int64(n), code), nil
+	}
+
+	return New(int64(n), code), nil
+}
+
+// FromFloat converts an amount in major units as iPay reports it in webhooks, e.g. 12.5. The float
+// is formatted with the fewest digits that read back to the same value before parsing, so 0.29
+// becomes 29 kopecks rather than 28.
+func FromFloat(amount float64, code Code) (Money, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, amount)
+	}
+
+	return Parse(strconv.FormatFloat(amount, 'f', -1, 64), code)
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, mismatch(m, o)
+	}
+
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, o)
+	}
+
+	return New(sum, m.Currency), nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, mismatch(m, o)
+	}
+
+	diff := m.Amount - o.Amount
+	if (o.Amount > 0 && diff > m.Amount) || (o.Amount < 0 && diff < m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, o)
+	}
+
+	return New(diff, m.Currency), nil
+}
+
+// Mul returns m multiplied by n.
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Amount * n
+	if m.Amount != 0 && (product/m.Amount != n || m.Amount == -1 && n == math.MinInt64) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrOverflow, m, n)
+	}
+
+	return New(product, m.Currency), nil
+}
+
+// Cmp compares m and o and returns -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, mismatch(m, o)
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Int returns the amount as int, as used by the request types.
+func (m Money) Int() (int, error) {
+	if int64(int(m.Amount)) != m.Amount {
+		return 0, fmt.Errorf("%w: %s does not fit in int", ErrOverflow, m)
+	}
+
+	return int(m.Amount), nil
+}
+
+// Major formats the amount in major units with the currency exponent, e.g. "12.50".
+func (m Money) Major() string {
+	abs := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		abs = -abs
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	exp := m.Currency.Exponent()
+	if exp == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String formats the amount with its currency, e.g. "12.50 UAH".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Major()
+	}
+
+	return m.Major() + " " + string(m.Currency)
+}
+
+func mismatch(m, o Money) error {
+	return fmt.Errorf("%w: %s and %s", ErrMismatch, m.Currency, o.Currency)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
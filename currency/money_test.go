@@ -0,0 +1,99 @@
+package currency
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr error
+	}{
+		{in: "12.5", want: 1250},
+		{in: "12.50", want: 1250},
+		{in: "0.29", want: 29},
+		{in: "-3.1", want: -310},
+		{in: "+7", want: 700},
+		{in: ".05", want: 5},
+		{in: "1.2300", want: 123},
+		{in: "92233720368547758.07", want: math.MaxInt64},
+		{in: "-92233720368547758.08", want: math.MinInt64},
+		{in: "92233720368547758.08", wantErr: ErrOverflow},
+		{in: "1.005", wantErr: ErrInvalidAmount},
+		{in: "", wantErr: ErrInvalidAmount},
+		{in: "1,50", wantErr: ErrInvalidAmount},
+		{in: "1e3", wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in, UAH)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+		}
+		if err == nil && got != New(tt.want, UAH) {
+			t.Fatalf("Parse(%q) = %v, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	for amount, want := range map[float64]int64{0.29: 29, 1.1: 110, 19.99: 1999, 1e6: 100000000} {
+		got, err := FromFloat(amount, UAH)
+		if err != nil || got.Amount != want {
+			t.Fatalf("FromFloat(%v) = %v, %v, want %d", amount, got, err, want)
+		}
+	}
+
+	if _, err := FromFloat(math.NaN(), UAH); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("FromFloat(NaN) error = %v, want %v", err, ErrInvalidAmount)
+	}
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	hold := New(10000, UAH)
+
+	left, err := hold.Sub(New(2550, UAH))
+	if err != nil || left != New(7450, UAH) {
+		t.Fatalf("Sub() = %v, %v, want 74.50 UAH", left, err)
+	}
+
+	if _, err := hold.Add(New(1, USD)); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Add(USD) error = %v, want %v", err, ErrMismatch)
+	}
+	if _, err := New(math.MaxInt64, UAH).Add(New(1, UAH)); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Add() error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := New(math.MinInt64, UAH).Sub(New(1, UAH)); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Sub() error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := New(math.MaxInt64/2+1, UAH).Mul(2); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Mul() error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := New(-1, UAH).Mul(math.MinInt64); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Mul(MinInt64) error = %v, want %v", err, ErrOverflow)
+	}
+
+	if cmp, err := left.Cmp(hold); err != nil || cmp != -1 {
+		t.Fatalf("Cmp() = %d, %v, want -1", cmp, err)
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	tests := map[Money]string{
+		New(1250, UAH):          "12.50 UAH",
+		New(5, USD):             "0.05 USD",
+		New(-5, EUR):            "-0.05 EUR",
+		New(0, UAH):             "0.00 UAH",
+		New(math.MinInt64, ""):  "-92233720368547758.08",
+		New(100, Code("CODE0")): "1.00 CODE0",
+	}
+
+	for m, want := range tests {
+		if got := m.String(); got != want {
+			t.Fatalf("String() = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknown is returned for codes that are not in the ISO 4217 list.
+var ErrUnknown = errors.New("currency: unknown currency code")
+
+// Currency describes an ISO 4217 currency.
+type Currency struct {
+	Code     Code
+	Numeric  int
+	Exponent int // Number of minor-unit digits, e.g. 2 for UAH.
+	Name     string
+}
+
+var byCode, byNumeric = index(currencies)
+
+func index(list []Currency) (map[Code]Currency, map[int]Currency) {
+	codes := make(map[Code]Currency, len(list))
+	numerics := make(map[int]Currency, len(list))
+	for _, c := range list {
+		codes[c.Code] = c
+		numerics[c.Numeric] = c
+	}
+
+	return codes, numerics
+}
+
+// Lookup returns the currency with the alphabetic code.
+func Lookup(code Code) (Currency, bool) {
+	c, ok := byCode[code]
+	return c, ok
+}
+
+// LookupNumeric returns the currency with the numeric code, e.g. 980 for UAH.
+func LookupNumeric(numeric int) (Currency, bool) {
+	c, ok := byNumeric[numeric]
+	return c, ok
+}
+
+// ParseCode resolves an alphabetic code in any case or a numeric code, as some webhook payloads
+// carry "980" instead of "UAH".
+func ParseCode(s string) (Code, error) {
+	s = strings.TrimSpace(s)
+
+	if n, err := strconv.Atoi(s); err == nil {
+		if c, ok := LookupNumeric(n); ok {
+			return c.Code, nil
+		}
+	} else if c, ok := Lookup(Code(strings.ToUpper(s))); ok {
+		return c.Code, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknown, s)
+}
+
+// All returns the ISO 4217 currencies sorted by code.
+func All() []Currency {
+	return append([]Currency(nil), currencies...)
+}
+
+func (c Code) String() string {
+	return string(c)
+}
+
+// Validate returns ErrUnknown if c is not an ISO 4217 code.
+func (c Code) Validate() error {
+	if _, ok := byCode[c]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknown, string(c))
+	}
+
+	return nil
+}
+
+// Exponent returns the number of digits after the decimal separator of the currency, e.g. 2 for
+// UAH where 1 hryvnia is 100 kopecks. Unknown codes use 2.
+func (c Code) Exponent() int {
+	if cur, ok := byCode[c]; ok {
+		return cur.Exponent
+	}
+
+	return 2
+}
+
+// Numeric returns the ISO 4217 numeric code, or 0 if c is unknown.
+func (c Code) Numeric() int {
+	return byCode[c].Numeric
+}
+
+// Name returns the ISO 4217 currency name, or "" if c is unknown.
+func (c Code) Name() string {
+	return byCode[c].Name
+}
+
+// Set is a set of allowed currencies. A nil or empty Set allows every valid code.
+type Set []Code
+
+// Allows reports whether code is in the set.
+func (s Set) Allows(code Code) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	for _, c := range s {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
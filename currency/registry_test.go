@@ -0,0 +1,64 @@
+package currency
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Code
+		wantErr error
+	}{
+		{in: "UAH", want: UAH},
+		{in: "usd", want: USD},
+		{in: "980", want: UAH},
+		{in: "978", want: EUR},
+		{in: "ABC", wantErr: ErrUnknown},
+		{in: "123", wantErr: ErrUnknown},
+		{in: "", wantErr: ErrUnknown},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCode(tt.in)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Fatalf("ParseCode(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	seen := make(map[int]Code)
+	for _, c := range All() {
+		if prev, ok := seen[c.Numeric]; ok {
+			t.Fatalf("numeric %d used by %s and %s", c.Numeric, prev, c.Code)
+		}
+		seen[c.Numeric] = c.Code
+		if err := c.Code.Validate(); err != nil {
+			t.Fatalf("Validate(%s) error: %v", c.Code, err)
+		}
+	}
+
+	if err := Code("UAX").Validate(); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("Validate(UAX) error = %v, want %v", err, ErrUnknown)
+	}
+	if UAH.Numeric() != 980 || UAH.Name() != "Hryvnia" || UAH.Exponent() != 2 {
+		t.Fatalf("UAH = %d %q %d", UAH.Numeric(), UAH.Name(), UAH.Exponent())
+	}
+	if got := New(1250, JPY).String(); got != "1250 JPY" {
+		t.Fatalf("String() = %q, want %q", got, "1250 JPY")
+	}
+	if got := New(1250, KWD).String(); got != "1.250 KWD" {
+		t.Fatalf("String() = %q, want %q", got, "1.250 KWD")
+	}
+}
+
+func TestSet_Allows(t *testing.T) {
+	if !Set(nil).Allows(USD) {
+		t.Fatal("empty Set does not allow USD")
+	}
+	if s := (Set{UAH}); !s.Allows(UAH) || s.Allows(USD) {
+		t.Fatalf("Set{UAH}.Allows() is wrong")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"strings"
+
+	"github.com/stremovskyy/go-ipay/consts"
+	"github.com/stremovskyy/go-ipay/internal/http"
+)
+
+// Environment describes the set of endpoints a client talks to.
+// Empty URLs fall back to the production endpoints.
+type Environment struct {
+	// Name is a human-readable profile name, e.g. "production".
+	Name string
+	// ApiURL is the JSON API endpoint (Debiting, Completion, Reversal, A2CPay, ...).
+	ApiURL string
+	// ApplePayURL is the Apple Pay PaymentCreate endpoint.
+	ApplePayURL string
+	// GooglePayURL is the Google Pay PaymentCreate endpoint.
+	GooglePayURL string
+	// ApiXMLURL is the XML payment page endpoint (api302).
+	ApiXMLURL string
+	// RepaymentURL is the Repayment API endpoint.
+	RepaymentURL string
+}
+
+var (
+	// EnvironmentProduction targets the public iPay endpoints. It is the default.
+	EnvironmentProduction = Environment{
+		Name:         "production",
+		ApiURL:       consts.ApiUrl,
+		ApplePayURL:  consts.ApplePayUrl,
+		GooglePayURL: consts.GooglePayUrl,
+		ApiXMLURL:    consts.ApiXMLUrl,
+		RepaymentURL: consts.RepaymentUrl,
+	}
+
+	// EnvironmentSandbox targets the endpoints used with iPay test merchants. iPay publishes no
+	// separate test hosts: test merchants are served by the public hosts, and the test merchant
+	// credentials and test cards select the sandbox behavior. Select this profile for test
+	// merchants, so that only these URLs change if iPay ever gives them hosts of their own.
+	EnvironmentSandbox = Environment{
+		Name:         "sandbox",
+		ApiURL:       consts.ApiUrl,
+		ApplePayURL:  consts.ApplePayUrl,
+		GooglePayURL: consts.GooglePayUrl,
+		ApiXMLURL:    consts.ApiXMLUrl,
+		RepaymentURL: consts.RepaymentUrl,
+	}
+)
+
+// CustomEnvironment derives every endpoint from a single base URL, which is convenient for
+// staging hosts, local stub servers and egress proxies:
+//
+//	<base>/api, <base>/api302, <base>/applepay, <base>/googlepay, <base>/repayment
+func CustomEnvironment(baseURL string) Environment {
+	base := strings.TrimRight(baseURL, "/")
+
+	return Environment{
+		Name:         "custom",
+		ApiURL:       base + "/api",
+		ApplePayURL:  base + "/applepay",
+		GooglePayURL: base + "/googlepay",
+		ApiXMLURL:    base + "/api302",
+		RepaymentURL: base + "/repayment",
+	}
+}
+
+func (e Environment) endpoints() http.Endpoints {
+	return http.Endpoints{
+		Api:       e.ApiURL,
+		ApplePay:  e.ApplePayURL,
+		GooglePay: e.GooglePayURL,
+		ApiXML:    e.ApiXMLURL,
+		Repayment: e.RepaymentURL,
+	}
+}
@@ -0,0 +1,83 @@
+package go_ipay
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/consts"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+)
+
+func TestWithEnvironment_DryRunReportsCustomEndpoints(t *testing.T) {
+	cl := NewClient(WithBaseURL("http://127.0.0.1:8080/"))
+
+	var gotEndpoint string
+	handler := DryRun(func(endpoint string, payload any) {
+		gotEndpoint = endpoint
+	})
+
+	_, err := cl.Status(&Request{
+		Merchant:    &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData: &PaymentData{IpayPaymentID: utils.Ref(int64(1))},
+	}, handler)
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if gotEndpoint != "http://127.0.0.1:8080/api" {
+		t.Fatalf("endpoint = %q, want %q", gotEndpoint, "http://127.0.0.1:8080/api")
+	}
+
+	guid := "guid"
+	_, err = cl.GetRepaymentStatus(&GetRepaymentStatusRequest{
+		Merchant:      &Merchant{Login: "l", RepaymentKey: "k"},
+		RepaymentGUID: &guid,
+	}, handler)
+	if err != nil {
+		t.Fatalf("GetRepaymentStatus() error: %v", err)
+	}
+	if gotEndpoint != "http://127.0.0.1:8080/repayment" {
+		t.Fatalf("endpoint = %q, want %q", gotEndpoint, "http://127.0.0.1:8080/repayment")
+	}
+}
+
+func TestWithEnvironment_PartialOverrideKeepsDefaults(t *testing.T) {
+	var gotURL string
+
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotURL = req.URL.String()
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5}}`)), nil
+	})
+
+	cl := NewClient(
+		WithClient(&http.Client{Transport: rt}),
+		WithEnvironment(Environment{RepaymentURL: "https://proxy.internal/repayment"}),
+	)
+
+	_, err := cl.Status(&Request{
+		Merchant:    &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData: &PaymentData{IpayPaymentID: utils.Ref(int64(1))},
+	})
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if gotURL != consts.ApiUrl {
+		t.Fatalf("url = %q, want %q", gotURL, consts.ApiUrl)
+	}
+}
+
+func TestWithEnvironment_Sandbox(t *testing.T) {
+	var gotEndpoint string
+	cl := NewClient(WithEnvironment(EnvironmentSandbox))
+
+	_, err := cl.Status(&Request{
+		Merchant:    &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData: &PaymentData{IpayPaymentID: utils.Ref(int64(1))},
+	}, DryRun(func(endpoint string, _ any) { gotEndpoint = endpoint }))
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if EnvironmentSandbox.Name != "sandbox" || gotEndpoint != EnvironmentSandbox.ApiURL {
+		t.Fatalf("%s endpoint = %q, want %q", EnvironmentSandbox.Name, gotEndpoint, EnvironmentSandbox.ApiURL)
+	}
+}
@@ -33,3 +33,22 @@ import (
 var ErrRequestIsNil = &ipay.Error{Code: 901, Message: "Request is nil", Details: "Request is nil"}
 var ErrMerchantIsNil = errors.New("merchant is nil")
 var ErrPersonalDataIsNil = errors.New("personal data is nil")
+
+// ErrCardDataInvalid is returned when CardData lacks a PAN or has an impossible expiry date.
+var ErrCardDataInvalid = errors.New("card data is invalid")
+
+// ErrCurrencyNotAllowed is returned when a request is in a currency outside Merchant.Currencies.
+var ErrCurrencyNotAllowed = errors.New("currency is not allowed for merchant")
+
+// ErrRefundExceedsBalance is returned when a refund asks for more than is left to refund.
+var ErrRefundExceedsBalance = errors.New("refund amount exceeds the refundable balance")
+
+// ErrNotRefundable is returned when refunding a payment that is neither held nor settled.
+var ErrNotRefundable = errors.New("payment cannot be refunded in its current status")
+
+// ErrRequestInFlight is returned when a request with the same ext_id is being sent by another caller.
+var ErrRequestInFlight = errors.New("request with this ext_id is already in flight")
+
+// ErrOutcomeUnknown is returned when an earlier attempt with the same ext_id ended ambiguously and
+// iPay could not be asked about its outcome. Resending is unsafe until the status is known.
+var ErrOutcomeUnknown = errors.New("outcome of a previous request with this ext_id is unknown")
@@ -25,16 +25,27 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/webhook"
 )
 
+const merchantKey = "demo-merchant-key"
+
 const sampleWebhookResponse = `
 <payment id="123456">
 	<ident>demo-transaction</ident>
-	<status>4</status>
+	<status>5</status>
 	<amount>100.00</amount>
 	<currency>UAH</currency>
 	<timestamp>1700000000</timestamp>
@@ -49,24 +60,41 @@ const sampleWebhookResponse = `
 		</transaction>
 	</transactions>
 	<salt>demo-salt</salt>
-	<sign>demo-signature</sign>
+	<sign>%s</sign>
 	<pmt_id>123</pmt_id>
 </payment>
 `
 
 func main() {
-	xmlData := []byte(sampleWebhookResponse)
+	handler := webhook.NewHandler(ipay.NewWebhookVerifier([]string{merchantKey})).
+		On(
+			webhook.EventPaymentSucceeded, func(_ context.Context, e *webhook.Event) error {
+				fmt.Printf("Payment succeeded: %s\n", e.Payment.String())
+				fmt.Println("Timestamp:", time.Unix(e.Payment.Timestamp, 0))
+				return nil
+			},
+		).
+		OnAny(
+			func(_ context.Context, e *webhook.Event) error {
+				fmt.Printf("Event %s for payment %d\n", e.Type, e.Payment.ID)
+				return nil
+			},
+		)
+
+	// In a real service: http.Handle("/ipay/notify", handler)
+	form := url.Values{"xml": {fmt.Sprintf(sampleWebhookResponse, sign("demo-salt", merchantKey))}}
+	req := httptest.NewRequest(http.MethodPost, "/ipay/notify", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	payment, err := ipay.ParsePaymentXML(xmlData)
-	if err != nil {
-		fmt.Println("Error parsing XML:", err)
-		return
-	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
 
-	// For demonstration, print out the payment ID and status
-	fmt.Printf("Payment: %s\n", payment.String())
+	fmt.Printf("Reply: %d %s\n", rec.Code, rec.Body.String())
+}
 
-	// To demonstrate timestamp conversion to a readable format
-	timestamp := time.Unix(payment.Timestamp, 0)
-	fmt.Println("Timestamp:", timestamp)
+// sign reproduces the signature iPay puts into a notification.
+func sign(salt, key string) string {
+	mac := hmac.New(sha512.New, []byte(key))
+	mac.Write([]byte(salt))
+	return hex.EncodeToString(mac.Sum(nil))
 }
@@ -0,0 +1,98 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package hold tracks pre-authorizations made with Hold until they are captured or released, and
+// captures or releases them automatically before they expire.
+package hold
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/currency"
+)
+
+// State is the lifecycle stage of a hold.
+type State string
+
+const (
+	// StatePending means iPay has not pre-authorized the hold yet, e.g. while the customer passes
+	// 3-D Secure or the payment is checked manually. Reconcile moves it on.
+	StatePending State = "pending"
+	// StateOpen means the funds are reserved and waiting for a capture or a release.
+	StateOpen State = "open"
+	// StateCaptured means the hold was captured, in full or in part.
+	StateCaptured State = "captured"
+	// StateReleased means the hold was voided or expired without a capture.
+	StateReleased State = "released"
+	// StateFailed means a pending hold was declined, so no funds were reserved.
+	StateFailed State = "failed"
+)
+
+// Action is what the manager does with a hold that reaches its deadline.
+type Action string
+
+const (
+	ActionCapture Action = "capture"
+	ActionRelease Action = "release"
+)
+
+// ErrNotFound is returned by Store.Get when the payment is unknown.
+var ErrNotFound = errors.New("hold: not found")
+
+// ErrNotOpen is returned when capturing or releasing a hold that is pending or already finalized.
+var ErrNotOpen = errors.New("hold: not open")
+
+// ErrMerchantMismatch is returned by Manager.Hold for a request of another merchant than the
+// manager's.
+var ErrMerchantMismatch = errors.New("hold: request merchant differs from the manager's")
+
+// Hold is a tracked pre-authorization.
+type Hold struct {
+	// PaymentID is the iPay pmt_id of the hold.
+	PaymentID  int64
+	ExtID      string
+	MerchantID string
+	State      State
+	// Amount is the reserved amount; Captured is what was captured of it.
+	Amount   currency.Money
+	Captured currency.Money
+	// OnDeadline is what happens to the hold once Deadline passes while it is still open.
+	OnDeadline Action
+	Deadline   time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Store persists holds. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save creates or replaces the hold with the same PaymentID.
+	Save(ctx context.Context, hold Hold) error
+	// Get returns the hold of the payment or ErrNotFound.
+	Get(ctx context.Context, paymentID int64) (*Hold, error)
+	// Due returns the pending holds of the merchant, and its open holds whose deadline is not
+	// after t.
+	Due(ctx context.Context, merchantID string, t time.Time) ([]Hold, error)
+}
@@ -0,0 +1,348 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package hold
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+const (
+	// DefaultDeadline leaves a day of margin before card schemes drop a pre-authorization, which
+	// they usually keep for 7 days.
+	DefaultDeadline = 6 * 24 * time.Hour
+	// DefaultInterval is how often Run looks for holds past their deadline.
+	DefaultInterval = time.Minute
+)
+
+// Manager places holds through a client, records them in a Store and finalizes them: on request
+// with Capture and Release, or automatically by Run once their deadline passes. A Manager serves
+// one merchant; run a single scheduler per merchant and store.
+type Manager struct {
+	client   go_ipay.Ipay
+	merchant *go_ipay.Merchant
+	store    Store
+	deadline time.Duration
+	action   Action
+	interval time.Duration
+	onError  func(Hold, error)
+	now      func() time.Time
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithDeadline sets how long after creation a hold is finalized automatically.
+func WithDeadline(d time.Duration) Option {
+	return func(m *Manager) {
+		m.deadline = d
+	}
+}
+
+// WithDeadlineAction sets what happens to holds still open at their deadline; the default is
+// ActionRelease.
+func WithDeadlineAction(action Action) Option {
+	return func(m *Manager) {
+		m.action = action
+	}
+}
+
+// WithInterval sets how often Run looks for holds past their deadline.
+func WithInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		m.interval = d
+	}
+}
+
+// WithErrorHandler receives the errors Run and ProcessDue hit while finalizing a hold. They keep
+// going with the next hold and retry the failed one on the next pass.
+func WithErrorHandler(handler func(Hold, error)) Option {
+	return func(m *Manager) {
+		m.onError = handler
+	}
+}
+
+// NewManager creates a Manager for the holds of merchant.
+func NewManager(client go_ipay.Ipay, merchant *go_ipay.Merchant, store Store, opts ...Option) *Manager {
+	m := &Manager{
+		client:   client,
+		merchant: merchant,
+		store:    store,
+		deadline: DefaultDeadline,
+		action:   ActionRelease,
+		interval: DefaultInterval,
+		onError:  func(Hold, error) {},
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Hold places the hold and records it: open when iPay pre-authorizes it, pending while the
+// payment is still processing, e.g. during 3-D Secure. The request is sent with the merchant of
+// the manager; a request with another merchant fails with ErrMerchantMismatch. A dry run returns
+// nil, nil.
+func (m *Manager) Hold(ctx context.Context, request *go_ipay.Request, runOpts ...go_ipay.RunOption) (*Hold, error) {
+	if request == nil {
+		return nil, go_ipay.ErrRequestIsNil
+	}
+	if request.Merchant != nil && request.Merchant.MerchantID != m.merchant.MerchantID {
+		return nil, fmt.Errorf("%w: %s", ErrMerchantMismatch, request.Merchant.MerchantID)
+	}
+
+	// A copy, so that the caller's request is left as it was.
+	held := *request
+	held.Merchant = m.merchant
+
+	result, err := m.client.HoldResultContext(ctx, &held, runOpts...)
+	if err != nil || result == nil {
+		return nil, err
+	}
+
+	state := StateOpen
+	switch {
+	case result.Status == ipay.PaymentStatusPreAuthorized:
+	case !result.Status.IsFinal():
+		state = StatePending
+	default:
+		return nil, fmt.Errorf("hold: payment %d is %s, not pre-authorized", result.PaymentID, result.Status.String())
+	}
+
+	amount := result.Amount
+	if amount.IsZero() {
+		amount = request.GetMoney()
+	}
+	if amount.Currency == "" {
+		amount.Currency = request.GetCurrency()
+	}
+
+	now := m.now()
+	h := Hold{
+		PaymentID:  result.PaymentID,
+		ExtID:      result.ExtID,
+		MerchantID: m.merchant.MerchantID,
+		State:      state,
+		Amount:     amount,
+		Captured:   currency.New(0, amount.Currency),
+		OnDeadline: m.action,
+		Deadline:   now.Add(m.deadline),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := m.store.Save(ctx, h); err != nil {
+		return nil, fmt.Errorf("hold: save %d: %w", h.PaymentID, err)
+	}
+
+	return &h, nil
+}
+
+// Capture captures amount of an open hold, or all of it when amount is 0. iPay releases the rest
+// of a partly captured hold.
+func (m *Manager) Capture(ctx context.Context, paymentID int64, amount int) (*Hold, error) {
+	h, err := m.open(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount == 0 {
+		amount = int(h.Amount.Amount)
+	}
+	if int64(amount) > h.Amount.Amount {
+		return nil, fmt.Errorf("hold: capture of %d exceeds the held %s", amount, h.Amount)
+	}
+
+	if _, err := m.client.CaptureResultContext(ctx, m.request(paymentID, amount)); err != nil {
+		return nil, m.failed(ctx, h, err)
+	}
+
+	h.State = StateCaptured
+	h.Captured = currency.New(int64(amount), h.Amount.Currency)
+
+	return m.save(ctx, h)
+}
+
+// Release voids an open hold.
+func (m *Manager) Release(ctx context.Context, paymentID int64) (*Hold, error) {
+	h, err := m.open(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := m.client.RefundResultContext(ctx, m.request(paymentID, 0)); err != nil {
+		return nil, m.failed(ctx, h, err)
+	}
+
+	h.State = StateReleased
+
+	return m.save(ctx, h)
+}
+
+// Reconcile asks iPay for the status of the hold. A pending hold becomes open once it is
+// pre-authorized, or failed when it is declined; a capture or a release made elsewhere, e.g. in
+// the iPay dashboard, is recorded.
+func (m *Manager) Reconcile(ctx context.Context, paymentID int64) (*Hold, error) {
+	h, err := m.store.Get(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A declined payment comes back with its status and an error describing the decline.
+	status, err := m.client.StatusResultContext(ctx, m.request(paymentID, 0))
+	if status == nil {
+		return nil, fmt.Errorf("hold: status of %d: %w", paymentID, err)
+	}
+
+	switch status.Status {
+	case ipay.PaymentStatusPreAuthorized:
+		if h.State != StatePending {
+			return h, nil
+		}
+		h.State = StateOpen
+	case ipay.PaymentStatusSuccess:
+		h.State = StateCaptured
+		h.Captured = currency.New(status.Amount.Amount, h.Amount.Currency)
+	case ipay.PaymentStatusCanceled, ipay.PaymentStatusFailed, ipay.PaymentStatusSecurityRefusal:
+		if h.State == StatePending {
+			h.State = StateFailed
+		} else {
+			h.State = StateReleased
+		}
+	default:
+		if err != nil {
+			return nil, fmt.Errorf("hold: status of %d: %w", paymentID, err)
+		}
+
+		return h, nil
+	}
+
+	return m.save(ctx, h)
+}
+
+// ProcessDue reconciles the pending holds and finalizes the open ones past their deadline. Each
+// is reconciled first, so a hold finalized elsewhere is only recorded. Failures go to the error
+// handler; only a failing Store stops the pass.
+func (m *Manager) ProcessDue(ctx context.Context) error {
+	now := m.now()
+
+	due, err := m.store.Due(ctx, m.merchant.MerchantID, now)
+	if err != nil {
+		return fmt.Errorf("hold: due holds: %w", err)
+	}
+
+	for _, h := range due {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		current, err := m.Reconcile(ctx, h.PaymentID)
+		if err != nil {
+			m.onError(h, err)
+			continue
+		}
+		if current.State != StateOpen || current.Deadline.After(now) {
+			continue
+		}
+
+		switch current.OnDeadline {
+		case ActionCapture:
+			_, err = m.Capture(ctx, h.PaymentID, 0)
+		default:
+			_, err = m.Release(ctx, h.PaymentID)
+		}
+		if err != nil {
+			m.onError(*current, err)
+		}
+	}
+
+	return nil
+}
+
+// Run calls ProcessDue right away and then at every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.ProcessDue(ctx); err != nil && ctx.Err() == nil {
+			m.onError(Hold{}, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) open(ctx context.Context, paymentID int64) (*Hold, error) {
+	h, err := m.store.Get(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.State != StateOpen {
+		return nil, fmt.Errorf("%w: %d is %s", ErrNotOpen, paymentID, h.State)
+	}
+
+	return h, nil
+}
+
+// failed reconciles a hold after a capture or release failed, since a common cause is a hold
+// finalized elsewhere, and returns err.
+func (m *Manager) failed(ctx context.Context, h *Hold, err error) error {
+	if current, rerr := m.Reconcile(ctx, h.PaymentID); rerr == nil && current.State != StateOpen {
+		return fmt.Errorf("%w: %d is %s: %w", ErrNotOpen, h.PaymentID, current.State, err)
+	}
+
+	return err
+}
+
+func (m *Manager) save(ctx context.Context, h *Hold) (*Hold, error) {
+	h.UpdatedAt = m.now()
+
+	if err := m.store.Save(ctx, *h); err != nil {
+		return nil, fmt.Errorf("hold: save %d: %w", h.PaymentID, err)
+	}
+
+	return h, nil
+}
+
+func (m *Manager) request(paymentID int64, amount int) *go_ipay.Request {
+	return &go_ipay.Request{
+		Merchant:    m.merchant,
+		PaymentData: &go_ipay.PaymentData{IpayPaymentID: &paymentID, Amount: amount},
+	}
+}
@@ -0,0 +1,209 @@
+package hold
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/ipaytest"
+)
+
+var merchant = &go_ipay.Merchant{MerchantID: "1", MerchantKey: "key"}
+
+func holdRequest(extID string, amount int) *go_ipay.Request {
+	return &go_ipay.Request{
+		PaymentData:   &go_ipay.PaymentData{Amount: amount, PaymentID: utils.Ref(extID), Currency: "UAH"},
+		PaymentMethod: &go_ipay.PaymentMethod{Card: &go_ipay.Card{Token: utils.Ref("tok")}},
+	}
+}
+
+func newManager(t *testing.T, opts ...Option) (*Manager, *ipaytest.Fake, *time.Time) {
+	t.Helper()
+
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(cl, merchant, NewMemoryStore(), opts...)
+	m.now = func() time.Time { return now }
+
+	return m, fake, &now
+}
+
+func TestManager_HoldAndPartialCapture(t *testing.T) {
+	ctx := t.Context()
+	m, fake, _ := newManager(t, WithDeadline(time.Hour))
+
+	h, err := m.Hold(ctx, holdRequest("order-1", 1000))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	if h.State != StateOpen || h.Amount.Amount != 1000 || h.Amount.Currency != "UAH" || h.Deadline != h.CreatedAt.Add(time.Hour) {
+		t.Fatalf("Hold() = %+v", h)
+	}
+
+	if _, err := m.Capture(ctx, h.PaymentID, 1500); err == nil {
+		t.Fatal("Capture(1500) error = nil, want an over-capture error")
+	}
+
+	h, err = m.Capture(ctx, h.PaymentID, 600)
+	if err != nil {
+		t.Fatalf("Capture() error: %v", err)
+	}
+	if h.State != StateCaptured || h.Captured.Amount != 600 {
+		t.Fatalf("Capture() = %+v", h)
+	}
+	if p, _ := fake.Payment(h.PaymentID); p.Status != ipay.PaymentStatusSuccess || p.Amount != 600 {
+		t.Fatalf("fake payment = %+v", p)
+	}
+
+	if _, err := m.Release(ctx, h.PaymentID); !errors.Is(err, ErrNotOpen) {
+		t.Fatalf("Release() error = %v, want %v", err, ErrNotOpen)
+	}
+}
+
+func TestManager_ProcessDue(t *testing.T) {
+	ctx := t.Context()
+	var handled []error
+	m, fake, now := newManager(t, WithDeadline(time.Hour), WithErrorHandler(func(_ Hold, err error) {
+		handled = append(handled, err)
+	}))
+
+	expiring, err := m.Hold(ctx, holdRequest("order-expiring", 500))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	elsewhere, err := m.Hold(ctx, holdRequest("order-elsewhere", 700))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+
+	*now = now.Add(30 * time.Minute)
+	later, err := m.Hold(ctx, holdRequest("order-later", 900))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+
+	// Captured in the dashboard, behind the manager's back.
+	if err := fake.SetStatus(elsewhere.PaymentID, ipay.PaymentStatusSuccess); err != nil {
+		t.Fatalf("SetStatus() error: %v", err)
+	}
+
+	*now = now.Add(45 * time.Minute)
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+	if len(handled) != 0 {
+		t.Fatalf("errors = %v, want none", handled)
+	}
+
+	want := map[int64]State{expiring.PaymentID: StateReleased, elsewhere.PaymentID: StateCaptured, later.PaymentID: StateOpen}
+	for id, state := range want {
+		h, err := m.store.Get(ctx, id)
+		if err != nil || h.State != state {
+			t.Fatalf("hold %d = %+v, %v, want %s", id, h, err, state)
+		}
+	}
+	if p, _ := fake.Payment(expiring.PaymentID); p.Status != ipay.PaymentStatusCanceled {
+		t.Fatalf("expired hold status = %d, want %d", p.Status, ipay.PaymentStatusCanceled)
+	}
+}
+
+func TestManager_AutoCapture(t *testing.T) {
+	ctx := t.Context()
+	m, fake, now := newManager(t, WithDeadline(time.Hour), WithDeadlineAction(ActionCapture))
+
+	h, err := m.Hold(ctx, holdRequest("order-1", 1000))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+
+	*now = now.Add(time.Hour)
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+
+	if p, _ := fake.Payment(h.PaymentID); p.Status != ipay.PaymentStatusSuccess || p.Amount != 1000 {
+		t.Fatalf("fake payment = %+v, want captured 1000", p)
+	}
+}
+
+func TestManager_PendingHold(t *testing.T) {
+	ctx := t.Context()
+	fake := ipaytest.New()
+	// The fake pre-authorizes holds right away; answer them as still registered, like a hold
+	// waiting for 3-D Secure.
+	registered := true
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := fake.RoundTrip(req)
+		if err != nil || !registered {
+			return resp, err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(bytes.NewReader(bytes.ReplaceAll(body, []byte(`"status":3`), []byte(`"status":1`))))
+		resp.ContentLength = -1
+
+		return resp, nil
+	})
+	cl := go_ipay.NewClient(go_ipay.WithClient(&http.Client{Transport: rt}))
+	m := NewManager(cl, merchant, NewMemoryStore(), WithDeadline(time.Hour))
+
+	authorized, err := m.Hold(ctx, holdRequest("order-authorized", 1000))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	declined, err := m.Hold(ctx, holdRequest("order-declined", 500))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	if authorized.State != StatePending || authorized.Amount.Amount != 1000 {
+		t.Fatalf("Hold() = %+v, want a pending hold of 1000", authorized)
+	}
+	if _, err := m.Release(ctx, authorized.PaymentID); !errors.Is(err, ErrNotOpen) {
+		t.Fatalf("Release() error = %v, want %v", err, ErrNotOpen)
+	}
+
+	registered = false
+	if err := fake.SetStatus(declined.PaymentID, ipay.PaymentStatusFailed); err != nil {
+		t.Fatalf("SetStatus() error: %v", err)
+	}
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+
+	if h, _ := m.store.Get(ctx, authorized.PaymentID); h.State != StateOpen {
+		t.Fatalf("pre-authorized hold = %+v, want it open", h)
+	}
+	if h, _ := m.store.Get(ctx, declined.PaymentID); h.State != StateFailed {
+		t.Fatalf("declined hold = %+v, want it failed", h)
+	}
+	if p, _ := fake.Payment(authorized.PaymentID); p.Status != ipay.PaymentStatusPreAuthorized {
+		t.Fatalf("fake payment = %+v, want it still held before the deadline", p)
+	}
+}
+
+func TestManager_HoldMerchant(t *testing.T) {
+	ctx := t.Context()
+	m, _, _ := newManager(t)
+
+	request := holdRequest("order-1", 1000)
+	if _, err := m.Hold(ctx, request); err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	if request.Merchant != nil {
+		t.Fatalf("Hold() set the request merchant to %+v", request.Merchant)
+	}
+
+	request = holdRequest("order-2", 1000)
+	request.Merchant = &go_ipay.Merchant{MerchantID: "2", MerchantKey: "other"}
+	if _, err := m.Hold(ctx, request); !errors.Is(err, ErrMerchantMismatch) {
+		t.Fatalf("Hold() error = %v, want %v", err, ErrMerchantMismatch)
+	}
+}
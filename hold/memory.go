@@ -0,0 +1,87 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package hold
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps holds in process memory, so they are lost on restart. Use a persistent Store
+// in production.
+type MemoryStore struct {
+	mu    sync.Mutex
+	holds map[int64]Hold
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{holds: make(map[int64]Hold)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, hold Hold) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.holds[hold.PaymentID] = hold
+
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, paymentID int64) (*Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hold, ok := s.holds[paymentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &hold, nil
+}
+
+// Due implements Store. Holds are returned by deadline, earliest first.
+func (s *MemoryStore) Due(_ context.Context, merchantID string, t time.Time) ([]Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Hold
+	for _, hold := range s.holds {
+		if hold.MerchantID != merchantID {
+			continue
+		}
+		if hold.State == StatePending || (hold.State == StateOpen && !hold.Deadline.After(t)) {
+			due = append(due, hold)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Deadline.Before(due[j].Deadline) })
+
+	return due, nil
+}
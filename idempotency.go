@@ -0,0 +1,314 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/consts"
+	"github.com/stremovskyy/go-ipay/idempotency"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/repayment"
+)
+
+// defaultInFlightTimeout is how long a pending record blocks duplicates when the HTTP client has
+// no timeout to derive it from.
+const defaultInFlightTimeout = time.Minute
+
+// errNoRecord is returned by a status lookup that succeeded without describing the request.
+var errNoRecord = errors.New("status lookup returned no record")
+
+// WithIdempotencyStore guards Payment, Hold, Credit and CreateRepayment against double submission.
+// Requests are keyed by operation, merchant and ext_id; requests without an ext_id are not guarded.
+//
+// A duplicate of a completed request returns the stored response without calling iPay. A duplicate
+// of a request that is still being sent fails with ErrRequestInFlight. When an earlier attempt ended
+// ambiguously (timeout, broken connection, 5xx) the client asks iPay for the status first and only
+// resends when iPay does not know the ext_id.
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(c *client) {
+		c.idempotency = store
+	}
+}
+
+// WithIdempotencyInFlightTimeout sets how long a pending record blocks duplicates with
+// ErrRequestInFlight. An older pending record is taken for one whose sender died, and the request
+// is looked up and possibly sent again, so the timeout must outlast the longest send. By default
+// it is derived from the timeout of the HTTP client and the retry policy of the client, or one
+// minute when the HTTP client has no timeout. Set it when calls use a per-call Retry policy with
+// more attempts or longer backoffs.
+func WithIdempotencyInFlightTimeout(timeout time.Duration) Option {
+	return func(c *client) {
+		c.inFlight = timeout
+	}
+}
+
+// inFlightTimeout is how long a pending record blocks duplicates. The record is pending while its
+// owner looks an ambiguous request up and sends it, so the window covers two calls, each with
+// every attempt and backoff of the client retry policy.
+func (c *client) inFlightTimeout() time.Duration {
+	if c.inFlight > 0 {
+		return c.inFlight
+	}
+
+	timeout := c.ipayClient.Timeout()
+	if timeout <= 0 {
+		return defaultInFlightTimeout
+	}
+
+	policy := c.retryPolicy.internal()
+	policy.Jitter = 0
+
+	attempts := max(policy.MaxAttempts, 1)
+	call := time.Duration(attempts) * timeout
+	for attempt := 1; attempt < attempts; attempt++ {
+		call += policy.Backoff(attempt)
+	}
+
+	return 2 * call
+}
+
+// reconcileFunc looks up the outcome of an ambiguous request. found is false only when iPay
+// answered that it has no record of it, which makes a resend safe; any other failure is an error.
+type reconcileFunc[T any] func(ctx context.Context) (response *T, found bool, err error)
+
+func guarded[T any](ctx context.Context, c *client, operation string, merchant *Merchant, extID *string, send func(context.Context) (*T, error), reconcile reconcileFunc[T]) (*T, error) {
+	if c.idempotency == nil || extID == nil || *extID == "" {
+		return send(ctx)
+	}
+
+	key := idempotencyKey(operation, merchant, *extID)
+
+	record, created, err := c.idempotency.Reserve(ctx, key, operation)
+	if err != nil {
+		return nil, fmt.Errorf("idempotency reserve %s: %w", key, err)
+	}
+
+	if !created {
+		switch {
+		case record.State == idempotency.StateCompleted:
+			var stored T
+			if err := json.Unmarshal(record.Response, &stored); err != nil {
+				return nil, fmt.Errorf("idempotency decode %s: %w", key, err)
+			}
+
+			return &stored, nil
+		case record.State == idempotency.StatePending && time.Since(record.UpdatedAt) < c.inFlightTimeout():
+			return nil, ErrRequestInFlight
+		}
+
+		if err := c.claimIdempotent(ctx, key, record.State); err != nil {
+			return nil, err
+		}
+
+		// The record is pending and ours: a failed lookup leaves it so, and a later duplicate
+		// retries the lookup once it is stale.
+		response, found, err := reconcile(ctx)
+		if err != nil {
+			return nil, errors.Join(ErrOutcomeUnknown, err)
+		}
+		if found {
+			c.storeIdempotent(ctx, key, idempotency.StateCompleted, response)
+			return response, nil
+		}
+	}
+
+	response, err := send(ctx)
+	switch {
+	case err == nil:
+		c.storeIdempotent(ctx, key, idempotency.StateCompleted, response)
+	case isDefinitiveAPIError(err):
+		// iPay answered and rejected the request, so it may be corrected and sent again.
+		if releaseErr := c.idempotency.Release(context.WithoutCancel(ctx), key); releaseErr != nil {
+			return response, errors.Join(err, fmt.Errorf("idempotency release %s: %w", key, releaseErr))
+		}
+	default:
+		c.storeIdempotent(ctx, key, idempotency.StateAmbiguous, nil)
+	}
+
+	return response, err
+}
+
+// claimIdempotent takes over an ambiguous record, or a pending one whose sender died, by moving it
+// to pending. Both steps are compare-and-swap updates, so of several concurrent duplicates exactly
+// one claims the record; the others fail with ErrRequestInFlight.
+func (c *client) claimIdempotent(ctx context.Context, key string, state idempotency.State) error {
+	steps := []idempotency.State{idempotency.StateAmbiguous, idempotency.StatePending}
+	if state == idempotency.StateAmbiguous {
+		steps = steps[1:]
+	}
+
+	for _, next := range steps {
+		err := c.idempotency.Update(ctx, key, state, next, nil)
+		if errors.Is(err, idempotency.ErrConflict) || errors.Is(err, idempotency.ErrNotFound) {
+			return ErrRequestInFlight
+		}
+		if err != nil {
+			return fmt.Errorf("idempotency claim %s: %w", key, err)
+		}
+
+		state = next
+	}
+
+	return nil
+}
+
+// storeIdempotent records the outcome of a pending record even when ctx is already canceled;
+// losing it would let a later duplicate through.
+func (c *client) storeIdempotent(ctx context.Context, key string, state idempotency.State, response any) {
+	var raw []byte
+	if response != nil {
+		var err error
+		if raw, err = json.Marshal(response); err != nil {
+			c.ipayClient.Logger("iPay Idempotency:").Error("cannot encode response for %s: %v", key, err)
+			state = idempotency.StateAmbiguous
+			raw = nil
+		}
+	}
+
+	if err := c.idempotency.Update(context.WithoutCancel(ctx), key, idempotency.StatePending, state, raw); err != nil {
+		c.ipayClient.Logger("iPay Idempotency:").Error("cannot update %s: %v", key, err)
+	}
+}
+
+func idempotencyKey(operation string, merchant *Merchant, extID string) string {
+	merchantID := ""
+	if merchant != nil {
+		merchantID = merchant.MerchantID
+	}
+
+	return fmt.Sprintf("%s:%s:%s", operation, merchantID, extID)
+}
+
+// isDefinitiveAPIError reports whether err is an answer from iPay rather than a failure to get one.
+func isDefinitiveAPIError(err error) bool {
+	var ipayErr *ipay.IpayError
+	if errors.As(err, &ipayErr) {
+		return true
+	}
+
+	var repaymentErr *repayment.APIError
+	if errors.As(err, &repaymentErr) {
+		return repaymentErr.StatusCode < 500
+	}
+
+	return false
+}
+
+// isNotFound reports whether iPay answered that it does not know the looked up ext_id.
+func isNotFound(err error) bool {
+	var ipayErr *ipay.IpayError
+	if errors.As(err, &ipayErr) {
+		return ipayErr.IsNotFound()
+	}
+
+	var repaymentErr *repayment.APIError
+	if errors.As(err, &repaymentErr) {
+		return repaymentErr.IsNotFound()
+	}
+
+	return false
+}
+
+func paymentOperation(preauth bool) string {
+	if preauth {
+		return consts.Hold
+	}
+
+	return consts.Payment
+}
+
+func (c *client) reconcilePayment(request *Request) reconcileFunc[ipay.Response] {
+	return func(ctx context.Context) (*ipay.Response, bool, error) {
+		response, err := c.StatusContext(
+			ctx, &Request{
+				Merchant:    request.Merchant,
+				PaymentData: &PaymentData{PaymentID: request.GetPaymentID()},
+			},
+		)
+		if err != nil {
+			if isNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+
+		if response == nil || response.PmtIdInt64() == 0 {
+			return nil, false, errNoRecord
+		}
+
+		return response, true, nil
+	}
+}
+
+func (c *client) reconcileCredit(request *Request) reconcileFunc[ipay.Response] {
+	return func(ctx context.Context) (*ipay.Response, bool, error) {
+		response, err := c.A2CPaymentStatusContext(
+			ctx, &Request{
+				Merchant:    request.Merchant,
+				PaymentData: &PaymentData{PaymentID: request.GetPaymentID()},
+			},
+		)
+		if err != nil {
+			if isNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+
+		if response == nil || response.PmtIdInt64() == 0 {
+			return nil, false, errNoRecord
+		}
+
+		return response, true, nil
+	}
+}
+
+func (c *client) reconcileRepayment(request *CreateRepaymentRequest) reconcileFunc[repayment.Response] {
+	return func(ctx context.Context) (*repayment.Response, bool, error) {
+		response, err := c.GetRepaymentStatusContext(
+			ctx, &GetRepaymentStatusRequest{
+				Merchant: request.Merchant,
+				ExtID:    &request.ExtID,
+			},
+		)
+		if err != nil {
+			if isNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+
+		if response == nil || response.RepaymentGUID == nil {
+			return nil, false, errNoRecord
+		}
+
+		return response, true, nil
+	}
+}
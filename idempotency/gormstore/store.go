@@ -0,0 +1,148 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package gormstore implements idempotency.Store on top of GORM.
+package gormstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/stremovskyy/go-ipay/idempotency"
+)
+
+// Model is the table row of a record.
+type Model struct {
+	Key       string `gorm:"column:idempotency_key;primaryKey;size:191"`
+	Operation string `gorm:"size:64"`
+	State     string `gorm:"size:16;index"`
+	Response  []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName implements gorm's Tabler.
+func (Model) TableName() string {
+	return "ipay_idempotency_records"
+}
+
+// Store is an idempotency.Store backed by a SQL database.
+type Store struct {
+	db *gorm.DB
+}
+
+// New creates a Store. Call AutoMigrate once to create the table.
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// AutoMigrate creates or updates the records table.
+func (s *Store) AutoMigrate() error {
+	return s.db.AutoMigrate(&Model{})
+}
+
+// Reserve implements idempotency.Store. It relies on the primary key to make concurrent
+// reservations of the same key from different processes safe.
+func (s *Store) Reserve(ctx context.Context, key string, operation string) (*idempotency.Record, bool, error) {
+	row := Model{
+		Key:       key,
+		Operation: operation,
+		State:     string(idempotency.StatePending),
+	}
+
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+
+	if res.RowsAffected == 1 {
+		return toRecord(row), true, nil
+	}
+
+	existing, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return existing, false, nil
+}
+
+// Get implements idempotency.Store.
+func (s *Store) Get(ctx context.Context, key string) (*idempotency.Record, error) {
+	var row Model
+
+	err := s.db.WithContext(ctx).Where("idempotency_key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, idempotency.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toRecord(row), nil
+}
+
+// Update implements idempotency.Store. The expected state is part of the UPDATE condition, so
+// the database decides which of several concurrent updates wins.
+func (s *Store) Update(ctx context.Context, key string, expected, state idempotency.State, response []byte) error {
+	res := s.db.WithContext(ctx).Model(&Model{}).Where("idempotency_key = ? AND state = ?", key, string(expected)).Updates(
+		map[string]any{
+			"state":      string(state),
+			"response":   response,
+			"updated_at": time.Now(),
+		},
+	)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	if _, err := s.Get(ctx, key); err != nil {
+		return err
+	}
+
+	return idempotency.ErrConflict
+}
+
+// Release implements idempotency.Store.
+func (s *Store) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("idempotency_key = ?", key).Delete(&Model{}).Error
+}
+
+func toRecord(row Model) *idempotency.Record {
+	return &idempotency.Record{
+		Key:       row.Key,
+		Operation: row.Operation,
+		State:     idempotency.State(row.State),
+		Response:  row.Response,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
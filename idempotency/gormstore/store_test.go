@@ -0,0 +1,126 @@
+package gormstore
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/stremovskyy/go-ipay/idempotency"
+)
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "idempotency.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	s := New(db)
+	if err := s.AutoMigrate(); err != nil {
+		t.Fatalf("AutoMigrate() error: %v", err)
+	}
+
+	return s
+}
+
+func TestStore(t *testing.T) {
+	ctx := t.Context()
+	s := newStore(t)
+
+	rec, created, err := s.Reserve(ctx, "k", "Payment")
+	if err != nil || !created || rec.State != idempotency.StatePending || rec.Operation != "Payment" {
+		t.Fatalf("first Reserve() = %+v, created %v, err %v, want a new pending record", rec, created, err)
+	}
+
+	if err := s.Update(ctx, "k", idempotency.StatePending, idempotency.StateCompleted, []byte(`{"pmt_id":1}`)); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	rec, created, err = s.Reserve(ctx, "k", "Payment")
+	if err != nil || created {
+		t.Fatalf("second Reserve() = created %v, err %v, want existing record", created, err)
+	}
+	if rec.State != idempotency.StateCompleted || string(rec.Response) != `{"pmt_id":1}` {
+		t.Fatalf("record = %+v, want completed with response", rec)
+	}
+
+	if err := s.Update(ctx, "k", idempotency.StatePending, idempotency.StateAmbiguous, nil); !errors.Is(err, idempotency.ErrConflict) {
+		t.Fatalf("Update(wrong state) error = %v, want ErrConflict", err)
+	}
+	if rec, _ := s.Get(ctx, "k"); rec.State != idempotency.StateCompleted {
+		t.Fatalf("record after conflict = %+v, want it unchanged", rec)
+	}
+
+	if err := s.Release(ctx, "k"); err != nil {
+		t.Fatalf("Release() error: %v", err)
+	}
+	if _, err := s.Get(ctx, "k"); !errors.Is(err, idempotency.ErrNotFound) {
+		t.Fatalf("Get() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Update(ctx, "k", idempotency.StatePending, idempotency.StatePending, nil); !errors.Is(err, idempotency.ErrNotFound) {
+		t.Fatalf("Update() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Concurrent(t *testing.T) {
+	ctx := t.Context()
+	s := newStore(t)
+
+	const workers = 10
+
+	var (
+		wg              sync.WaitGroup
+		reserved, taken atomic.Int32
+	)
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, created, err := s.Reserve(ctx, "k", "Payment")
+			if err != nil {
+				t.Errorf("Reserve() error: %v", err)
+			}
+			if created {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved.Load() != 1 {
+		t.Fatalf("%d reservations created the record, want 1", reserved.Load())
+	}
+
+	if err := s.Update(ctx, "k", idempotency.StatePending, idempotency.StateAmbiguous, nil); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			switch err := s.Update(ctx, "k", idempotency.StateAmbiguous, idempotency.StatePending, nil); {
+			case err == nil:
+				taken.Add(1)
+			case !errors.Is(err, idempotency.ErrConflict):
+				t.Errorf("Update() error = %v, want ErrConflict", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if taken.Load() != 1 {
+		t.Fatalf("%d updates took over the record, want 1", taken.Load())
+	}
+}
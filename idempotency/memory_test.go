@@ -0,0 +1,74 @@
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := t.Context()
+	s := NewMemoryStore()
+
+	if _, created, err := s.Reserve(ctx, "k", "Payment"); err != nil || !created {
+		t.Fatalf("first Reserve() = created %v, err %v, want created", created, err)
+	}
+
+	if err := s.Update(ctx, "k", StatePending, StateCompleted, []byte(`{"pmt_id":1}`)); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	rec, created, err := s.Reserve(ctx, "k", "Payment")
+	if err != nil || created {
+		t.Fatalf("second Reserve() = created %v, err %v, want existing record", created, err)
+	}
+	if rec.State != StateCompleted || string(rec.Response) != `{"pmt_id":1}` {
+		t.Fatalf("record = %+v, want completed with response", rec)
+	}
+
+	if err := s.Release(ctx, "k"); err != nil {
+		t.Fatalf("Release() error: %v", err)
+	}
+	if _, err := s.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Update(ctx, "k", StatePending, StatePending, nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Update() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStore_UpdateIsCompareAndSwap(t *testing.T) {
+	ctx := t.Context()
+	s := NewMemoryStore()
+
+	if _, _, err := s.Reserve(ctx, "k", "Payment"); err != nil {
+		t.Fatalf("Reserve() error: %v", err)
+	}
+	if err := s.Update(ctx, "k", StatePending, StateAmbiguous, nil); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	var (
+		wg  sync.WaitGroup
+		won atomic.Int32
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			switch err := s.Update(ctx, "k", StateAmbiguous, StatePending, nil); {
+			case err == nil:
+				won.Add(1)
+			case !errors.Is(err, ErrConflict):
+				t.Errorf("Update() error = %v, want ErrConflict", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if won.Load() != 1 {
+		t.Fatalf("%d updates took over the record, want 1", won.Load())
+	}
+}
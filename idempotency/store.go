@@ -0,0 +1,79 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package idempotency remembers money-moving requests by their ext_id so that a request
+// is not sent to iPay twice.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// State is the lifecycle stage of a recorded request.
+type State string
+
+const (
+	// StatePending means the request is being sent right now.
+	StatePending State = "pending"
+	// StateAmbiguous means the request may or may not have reached iPay (timeout, broken connection, 5xx).
+	StateAmbiguous State = "ambiguous"
+	// StateCompleted means iPay accepted the request; Record.Response holds its answer.
+	StateCompleted State = "completed"
+)
+
+var (
+	// ErrNotFound is returned by Store.Get and Store.Update when the key is unknown.
+	ErrNotFound = errors.New("idempotency: record not found")
+	// ErrConflict is returned by Store.Update when the record is no longer in the expected state.
+	ErrConflict = errors.New("idempotency: record changed concurrently")
+)
+
+// Record is a single remembered request.
+type Record struct {
+	Key       string
+	Operation string
+	State     State
+	// Response is the JSON encoded response, set once the record is completed.
+	Response  []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store persists records. Implementations must be safe for concurrent use and Reserve must be
+// atomic across every process that shares the store.
+type Store interface {
+	// Reserve creates a pending record for key. When a record already exists it is returned
+	// unchanged and created is false.
+	Reserve(ctx context.Context, key string, operation string) (record *Record, created bool, err error)
+	// Get returns the record stored under key or ErrNotFound.
+	Get(ctx context.Context, key string) (*Record, error)
+	// Update sets the state and response of an existing record if it is still in the expected
+	// state, and returns ErrConflict otherwise. The check and the write must be atomic, so that
+	// only one of several concurrent callers moves a record out of a state.
+	Update(ctx context.Context, key string, expected, state State, response []byte) error
+	// Release deletes key so that the request may be sent again.
+	Release(ctx context.Context, key string) error
+}
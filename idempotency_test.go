@@ -0,0 +1,301 @@
+package go_ipay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/idempotency"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// actionTransport answers every request through reply, keyed by the iPay action, and records the actions.
+func actionTransport(t *testing.T, actions *[]string, reply func(action string, n int) (*http.Response, error)) teststand.RoundTripperFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("read request: %v", err)
+		}
+
+		var wrapper ipay.RequestWrapper
+		if err := json.Unmarshal(body, &wrapper); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		action := string(wrapper.Request.Action)
+		*actions = append(*actions, action)
+
+		return reply(action, len(*actions))
+	}
+}
+
+func TestPayment_Idempotency(t *testing.T) {
+	okPayment := []byte(`{"response":{"pmt_id":77,"status":5}}`)
+	timeout := errors.New("i/o timeout")
+
+	tests := []struct {
+		name        string
+		reply       func(action string, n int) (*http.Response, error)
+		wantActions []string
+		wantPmtID   int64
+	}{
+		{
+			name: "completed duplicate returns stored response",
+			reply: func(string, int) (*http.Response, error) {
+				return teststand.Response(200, "application/json", okPayment), nil
+			},
+			wantActions: []string{string(ipay.ActionDebiting)},
+			wantPmtID:   77,
+		},
+		{
+			name: "ambiguous attempt reconciled by status",
+			reply: func(action string, n int) (*http.Response, error) {
+				if n == 1 {
+					return nil, timeout
+				}
+				return teststand.Response(200, "application/json", okPayment), nil
+			},
+			wantActions: []string{string(ipay.ActionDebiting), string(ipay.ActionGetPaymentStatus)},
+			wantPmtID:   77,
+		},
+		{
+			name: "ambiguous attempt unknown to iPay is resent",
+			reply: func(action string, n int) (*http.Response, error) {
+				switch {
+				case n == 1:
+					return nil, timeout
+				case action == string(ipay.ActionGetPaymentStatus):
+					return teststand.Response(200, "application/json", []byte(`{"response":{"error":"payment not found","error_code":"404"}}`)), nil
+				default:
+					return teststand.Response(200, "application/json", okPayment), nil
+				}
+			},
+			wantActions: []string{string(ipay.ActionDebiting), string(ipay.ActionGetPaymentStatus), string(ipay.ActionDebiting)},
+			wantPmtID:   77,
+		},
+		{
+			name: "rejected attempt is released",
+			reply: func(action string, n int) (*http.Response, error) {
+				if n == 1 {
+					return teststand.Response(200, "application/json", []byte(`{"response":{"res_auth_code":101}}`)), nil
+				}
+				return teststand.Response(200, "application/json", okPayment), nil
+			},
+			wantActions: []string{string(ipay.ActionDebiting), string(ipay.ActionDebiting)},
+			wantPmtID:   77,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actions []string
+			cl := NewClient(
+				WithClient(&http.Client{Transport: actionTransport(t, &actions, tt.reply)}),
+				WithIdempotencyStore(idempotency.NewMemoryStore()),
+			)
+
+			request := paymentRequest()
+			request.PaymentData.IpayPaymentID = nil
+
+			_, _ = cl.Payment(request)
+			resp, err := cl.Payment(request)
+			if err != nil {
+				t.Fatalf("second Payment() error: %v", err)
+			}
+			if got := resp.PmtIdInt64(); got != tt.wantPmtID {
+				t.Fatalf("pmt_id = %d, want %d", got, tt.wantPmtID)
+			}
+			if len(actions) != len(tt.wantActions) {
+				t.Fatalf("actions = %v, want %v", actions, tt.wantActions)
+			}
+			for i := range actions {
+				if actions[i] != tt.wantActions[i] {
+					t.Fatalf("actions = %v, want %v", actions, tt.wantActions)
+				}
+			}
+		})
+	}
+}
+
+func TestPayment_IdempotencyInFlight(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	cl := NewClient(WithIdempotencyStore(store))
+
+	request := paymentRequest()
+	if _, _, err := store.Reserve(t.Context(), idempotencyKey("Payment", request.Merchant, *request.GetPaymentID()), "Payment"); err != nil {
+		t.Fatalf("Reserve() error: %v", err)
+	}
+
+	if _, err := cl.Payment(request); !errors.Is(err, ErrRequestInFlight) {
+		t.Fatalf("Payment() error = %v, want ErrRequestInFlight", err)
+	}
+}
+
+func TestPayment_IdempotencyLookupErrorIsUnknown(t *testing.T) {
+	for name, status := range map[string]string{
+		"transient code":   `{"response":{"res_auth_code":907}}`,
+		"auth error":       `{"response":{"error":"Public key not found","error_code":"601"}}`,
+		"answer no pmt_id": `{"response":{"status":5}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var actions []string
+			cl := NewClient(
+				WithClient(&http.Client{Transport: actionTransport(t, &actions, func(action string, n int) (*http.Response, error) {
+					if n == 1 {
+						return nil, errors.New("i/o timeout")
+					}
+					return teststand.Response(200, "application/json", []byte(status)), nil
+				})}),
+				WithIdempotencyStore(idempotency.NewMemoryStore()),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+			)
+
+			request := paymentRequest()
+			request.PaymentData.IpayPaymentID = nil
+
+			_, _ = cl.Payment(request)
+			if _, err := cl.Payment(request); !errors.Is(err, ErrOutcomeUnknown) {
+				t.Fatalf("second Payment() error = %v, want ErrOutcomeUnknown", err)
+			}
+			if want := []string{string(ipay.ActionDebiting), string(ipay.ActionGetPaymentStatus)}; len(actions) != 2 || actions[1] != want[1] {
+				t.Fatalf("actions = %v, want %v", actions, want)
+			}
+		})
+	}
+}
+
+func TestPayment_IdempotencyConcurrentTakeover(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sends int
+	)
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var wrapper ipay.RequestWrapper
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &wrapper); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		if wrapper.Request.Action == ipay.ActionGetPaymentStatus {
+			return teststand.Response(200, "application/json", []byte(`{"response":{"error":"payment not found"}}`)), nil
+		}
+
+		mu.Lock()
+		sends++
+		mu.Unlock()
+
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":77,"status":5}}`)), nil
+	})
+
+	const duplicates = 10
+
+	store := &barrierStore{MemoryStore: idempotency.NewMemoryStore()}
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithIdempotencyStore(store))
+
+	request := paymentRequest()
+	request.PaymentData.IpayPaymentID = nil
+
+	key := idempotencyKey("Payment", request.Merchant, *request.GetPaymentID())
+	if _, _, err := store.MemoryStore.Reserve(t.Context(), key, "Payment"); err != nil {
+		t.Fatalf("Reserve() error: %v", err)
+	}
+	if err := store.Update(t.Context(), key, idempotency.StatePending, idempotency.StateAmbiguous, nil); err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+
+	store.reserved.Add(duplicates)
+
+	var wg sync.WaitGroup
+	for range duplicates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			request := paymentRequest()
+			request.PaymentData.IpayPaymentID = nil
+
+			if _, err := cl.Payment(request); err != nil && !errors.Is(err, ErrRequestInFlight) {
+				t.Errorf("Payment() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sends != 1 {
+		t.Fatalf("payment sent %d times, want 1", sends)
+	}
+}
+
+// barrierStore holds every Reserve until reserved is done, so that all duplicates see the same
+// record before any of them claims it.
+type barrierStore struct {
+	*idempotency.MemoryStore
+	reserved sync.WaitGroup
+}
+
+func (s *barrierStore) Reserve(ctx context.Context, key string, operation string) (*idempotency.Record, bool, error) {
+	record, created, err := s.MemoryStore.Reserve(ctx, key, operation)
+	s.reserved.Done()
+	s.reserved.Wait()
+
+	return record, created, err
+}
+
+func TestClient_InFlightTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want time.Duration
+	}{
+		// A status lookup and a send, each with 3 attempts of 15s and backoffs of 200ms and 400ms.
+		{name: "default", want: 2 * (3*15*time.Second + 600*time.Millisecond)},
+		{name: "no retries", opts: []Option{WithClient(&http.Client{Timeout: 10 * time.Second}), WithRetryPolicy(RetryPolicy{MaxAttempts: 1})}, want: 20 * time.Second},
+		{name: "no http timeout", opts: []Option{WithClient(&http.Client{})}, want: time.Minute},
+		{name: "configured", opts: []Option{WithIdempotencyInFlightTimeout(5 * time.Minute)}, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewClient(tt.opts...).(*client).inFlightTimeout(); got != tt.want {
+				t.Fatalf("inFlightTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayment_IdempotencyStalePending(t *testing.T) {
+	var actions []string
+	store := idempotency.NewMemoryStore()
+	cl := NewClient(
+		WithClient(&http.Client{Transport: actionTransport(t, &actions, func(action string, _ int) (*http.Response, error) {
+			if action == string(ipay.ActionGetPaymentStatus) {
+				return teststand.Response(200, "application/json", []byte(`{"response":{"error":"payment not found"}}`)), nil
+			}
+			return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":77,"status":5}}`)), nil
+		})}),
+		WithIdempotencyStore(store),
+		WithIdempotencyInFlightTimeout(time.Millisecond),
+	)
+
+	request := paymentRequest()
+	request.PaymentData.IpayPaymentID = nil
+	if _, _, err := store.Reserve(t.Context(), idempotencyKey("Payment", request.Merchant, *request.GetPaymentID()), "Payment"); err != nil {
+		t.Fatalf("Reserve() error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// The sender of the pending record is taken for dead: the payment is looked up, then sent.
+	if _, err := cl.Payment(request); err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+	if want := []string{string(ipay.ActionGetPaymentStatus), string(ipay.ActionDebiting)}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+}
@@ -25,6 +25,7 @@
 package go_ipay
 
 import (
+	"context"
 	"net/url"
 
 	"github.com/stremovskyy/go-ipay/ipay"
@@ -34,17 +35,58 @@ import (
 
 type Ipay interface {
 	VerificationLink(request *Request, opts ...RunOption) (*url.URL, error)
+	VerificationLinkContext(ctx context.Context, request *Request, opts ...RunOption) (*url.URL, error)
+	CreateToken(request *Request, opts ...RunOption) (*ipay.TokenizationResult, error)
+	CreateTokenContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.TokenizationResult, error)
+	CreateToken3DS(request *Request, opts ...RunOption) (*ipay.TokenizationResult, error)
+	CreateToken3DSContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.TokenizationResult, error)
 	Status(request *Request, opts ...RunOption) (*ipay.Response, error)
+	StatusContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.Response, error)
 	A2CPaymentStatus(request *Request, opts ...RunOption) (*ipay.Response, error)
+	A2CPaymentStatusContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.Response, error)
 	PaymentURL(invoiceRequest *Request, opts ...RunOption) (*ipay.PaymentResponse, error)
+	PaymentURLContext(ctx context.Context, invoiceRequest *Request, opts ...RunOption) (*ipay.PaymentResponse, error)
 	Payment(invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
+	PaymentContext(ctx context.Context, invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
 	Hold(invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
+	HoldContext(ctx context.Context, invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
 	Capture(invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
+	CaptureContext(ctx context.Context, invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
 	Refund(invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
+	RefundContext(ctx context.Context, invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
 	Credit(invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
+	CreditContext(ctx context.Context, invoiceRequest *Request, opts ...RunOption) (*ipay.Response, error)
 	CreateRepayment(request *CreateRepaymentRequest, opts ...RunOption) (*repayment.Response, error)
+	CreateRepaymentContext(ctx context.Context, request *CreateRepaymentRequest, opts ...RunOption) (*repayment.Response, error)
 	CancelRepayment(request *CancelRepaymentRequest, opts ...RunOption) (*repayment.Response, error)
+	CancelRepaymentContext(ctx context.Context, request *CancelRepaymentRequest, opts ...RunOption) (*repayment.Response, error)
 	GetRepaymentStatus(request *GetRepaymentStatusRequest, opts ...RunOption) (*repayment.Response, error)
+	GetRepaymentStatusContext(ctx context.Context, request *GetRepaymentStatusRequest, opts ...RunOption) (*repayment.Response, error)
 	GetRepaymentProcessingFile(request *GetRepaymentProcessingFileRequest, opts ...RunOption) ([]byte, error)
+	GetRepaymentProcessingFileContext(ctx context.Context, request *GetRepaymentProcessingFileRequest, opts ...RunOption) ([]byte, error)
+
+	// Typed variants of the calls above. When iPay answers with an error, the result is returned
+	// together with the error, so a declined payment still carries its status and bank answer.
+	PaymentResult(request *Request, opts ...RunOption) (*ipay.PaymentResult, error)
+	PaymentResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.PaymentResult, error)
+	HoldResult(request *Request, opts ...RunOption) (*ipay.HoldResult, error)
+	HoldResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.HoldResult, error)
+	CaptureResult(request *Request, opts ...RunOption) (*ipay.CaptureResult, error)
+	CaptureResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.CaptureResult, error)
+	RefundResult(request *Request, opts ...RunOption) (*ipay.RefundResult, error)
+	RefundResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.RefundResult, error)
+	CreditResult(request *Request, opts ...RunOption) (*ipay.CreditResult, error)
+	CreditResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.CreditResult, error)
+	StatusResult(request *Request, opts ...RunOption) (*ipay.StatusResult, error)
+	StatusResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.StatusResult, error)
+	VerificationResult(request *Request, opts ...RunOption) (*ipay.VerificationResult, error)
+	VerificationResultContext(ctx context.Context, request *Request, opts ...RunOption) (*ipay.VerificationResult, error)
+
+	// WaitForFinalStatus polls the payment status with backoff until it is final or ctx is done.
+	WaitForFinalStatus(ctx context.Context, request *Request, policy PollPolicy) (*ipay.StatusResult, error)
+
 	SetLogLevel(levelDebug log.Level)
+	// LogSink returns the destination of the client's logs, to share it with components built
+	// outside the client, such as webhook.Handler.
+	LogSink() *log.Sink
 }
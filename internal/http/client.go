@@ -30,18 +30,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/google/uuid"
-
 	"github.com/stremovskyy/go-ipay/consts"
 	"github.com/stremovskyy/go-ipay/ipay"
 	"github.com/stremovskyy/go-ipay/log"
+	"github.com/stremovskyy/go-ipay/redact"
+	"github.com/stremovskyy/go-ipay/telemetry"
 	"github.com/stremovskyy/recorder"
 )
 
@@ -55,52 +56,52 @@ const (
 )
 
 type Client struct {
-	client    *http.Client
-	options   *Options
-	loggers   map[loggerType]*log.Logger
-	loggersMu sync.RWMutex
-	recorder  recorder.Recorder
+	client   *http.Client
+	options  *Options
+	sink     *log.Sink
+	recorder recorder.Recorder
+	redactor *redact.Redactor
+	tracer   telemetry.Tracer
+	metrics  telemetry.Metrics
 }
 
 // Api handles the standard iPay API request.
-func (c *Client) Api(apiRequest *ipay.RequestWrapper) (*ipay.Response, error) {
-	return c.sendRequest(consts.ApiUrl, apiRequest, c.loggerFor(loggerTypeHTTP))
+func (c *Client) Api(ctx context.Context, apiRequest *ipay.RequestWrapper) (*ipay.Response, error) {
+	return c.sendRequest(ctx, c.options.Endpoints.Api, apiRequest, c.loggerFor(loggerTypeHTTP))
 }
 
 // ApplePayApi handles the Apple Pay-specific API request.
-func (c *Client) ApplePayApi(apiRequest *ipay.RequestWrapper) (*ipay.Response, error) {
-	return c.sendRequest(consts.ApplePayUrl, apiRequest, c.loggerFor(loggerTypeApplePay))
+func (c *Client) ApplePayApi(ctx context.Context, apiRequest *ipay.RequestWrapper) (*ipay.Response, error) {
+	return c.sendRequest(ctx, c.options.Endpoints.ApplePay, apiRequest, c.loggerFor(loggerTypeApplePay))
 }
 
 // GooglePayApi handles the Google Pay-specific API request.
-func (c *Client) GooglePayApi(apiRequest *ipay.RequestWrapper) (*ipay.Response, error) {
-	return c.sendRequest(consts.GooglePayUrl, apiRequest, c.loggerFor(loggerTypeGooglePay))
+func (c *Client) GooglePayApi(ctx context.Context, apiRequest *ipay.RequestWrapper) (*ipay.Response, error) {
+	return c.sendRequest(ctx, c.options.Endpoints.GooglePay, apiRequest, c.loggerFor(loggerTypeGooglePay))
 }
 
 func (c *Client) loggerFor(category loggerType) *log.Logger {
-	c.loggersMu.RLock()
-	logger, ok := c.loggers[category]
-	c.loggersMu.RUnlock()
-	if ok {
-		return logger
-	}
-
-	c.loggersMu.Lock()
-	defer c.loggersMu.Unlock()
+	return c.sink.Logger(string(category))
+}
 
-	// Double-check to avoid races when creating loggers lazily.
-	if logger, ok = c.loggers[category]; ok {
-		return logger
-	}
+// Logger returns a logger that writes to the client's log destination.
+func (c *Client) Logger(prefix string) *log.Logger {
+	return c.sink.Logger(prefix)
+}
 
-	if c.loggers == nil {
-		c.loggers = make(map[loggerType]*log.Logger)
-	}
+// Sink returns the client's log destination.
+func (c *Client) Sink() *log.Sink {
+	return c.sink
+}
 
-	logger = log.NewLogger(string(category))
-	c.loggers[category] = logger
+// SetLogger routes the client's logs to l. A nil l restores the classic stderr output.
+func (c *Client) SetLogger(l *slog.Logger) {
+	c.sink.SetLogger(l)
+}
 
-	return logger
+// SetLogLevel sets the log level of this client only.
+func (c *Client) SetLogLevel(level log.Level) {
+	c.sink.SetLevel(level)
 }
 
 // WithRecorder attaches a recorder to the client.
@@ -111,67 +112,88 @@ func (c *Client) WithRecorder(rec recorder.Recorder) *Client {
 }
 
 // sendRequest handles sending an HTTP request and processing the response.
-func (c *Client) sendRequest(apiURL string, apiRequest *ipay.RequestWrapper, logger *log.Logger) (*ipay.Response, error) {
-	requestID := uuid.New().String()
+func (c *Client) sendRequest(ctx context.Context, apiURL string, apiRequest *ipay.RequestWrapper, logger *log.Logger) (*ipay.Response, error) {
+	ctx, requestID := EnsureRequestID(ctx)
+	tags := tagsRetriever(apiRequest)
+	logger = requestLogger(logger, requestID, apiURL, tags)
 	logger.Debug("Request ID: %v", requestID)
 
 	jsonBody, err := json.Marshal(apiRequest)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot marshal request", err, logger, requestID, nil)
+		return nil, c.logAndReturnError(ctx, "cannot marshal request", err, logger, requestID, nil)
 	}
 
-	logger.Debug("Request: %v", string(jsonBody))
+	c.logPayload(logger, "Request", jsonBody)
 
-	ctx := context.WithValue(context.Background(), CtxKeyRequestID, requestID)
-	tags := tagsRetriever(apiRequest)
+	op := ipayOperation(apiURL, apiRequest)
+	ctx, span := c.startOperation(ctx, op)
+
+	var response *ipay.Response
+	err = c.withRetry(ctx, logger, tags, func(tags map[string]string) (int, error) {
+		attemptCtx, finish := c.startAttempt(ctx, op, tags)
+		resp, statusCode, attemptErr := c.sendAttempt(attemptCtx, apiURL, jsonBody, logger, requestID, tags)
+		finish(statusCode, attemptErr)
+		response = resp
+
+		return statusCode, attemptErr
+	})
+
+	endOperation(span, paymentStatus(response), err)
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
+	return response, err
+}
+
+// sendAttempt performs a single HTTP round trip of an already marshalled JSON request.
+func (c *Client) sendAttempt(ctx context.Context, apiURL string, jsonBody []byte, logger *log.Logger, requestID string, tags map[string]string) (*ipay.Response, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return nil, c.logAndReturnError("cannot create request", err, logger, requestID, tags)
+		return nil, 0, c.logAndReturnError(ctx, "cannot create request", err, logger, requestID, tags)
 	}
 
 	c.setHeaders(req, requestID)
 
 	if c.recorder != nil {
-		if errr := c.recorder.RecordRequest(ctx, nil, requestID, jsonBody, tags); errr != nil {
+		if errr := c.recorder.RecordRequest(ctx, nil, requestID, c.redactor.Bytes(jsonBody), tags); errr != nil {
 			logger.Error("%s: cannot record request: %v", "error", errr)
 		}
 	}
 
+	tStart := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot send request", err, logger, requestID, tags)
+		return nil, 0, c.logAndReturnError(ctx, "cannot send request", err, logger.With("duration", time.Since(tStart)), requestID, tags)
 	}
 	defer c.safeClose(resp.Body, logger)
 
+	logger = logger.With("http_status", resp.StatusCode, "duration", time.Since(tStart))
+
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot read response", err, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "cannot read response", err, logger, requestID, tags)
 	}
 
-	logger.Debug("Response: %v", string(raw))
+	c.logPayload(logger, "Response", raw)
 	logger.Debug("Response status: %v", resp.StatusCode)
 
 	if c.recorder != nil {
-		if errr := c.recorder.RecordResponse(ctx, nil, requestID, raw, tags); errr != nil {
+		if errr := c.recorder.RecordResponse(ctx, nil, requestID, c.redactor.Bytes(raw), tags); errr != nil {
 			logger.Error("%s: cannot record response %v", "error", errr)
 		}
 	}
 
 	response, err := ipay.UnmarshalJSONResponse(raw)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot unmarshal response", err, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "cannot unmarshal response", err, logger, requestID, tags)
 	}
 
-	return response, response.GetError()
+	return response, resp.StatusCode, response.GetError()
 }
 
 // logAndReturnError logs an error and optionally records it.
-func (c *Client) logAndReturnError(msg string, err error, logger *log.Logger, requestID string, tags map[string]string) error {
+func (c *Client) logAndReturnError(ctx context.Context, msg string, err error, logger *log.Logger, requestID string, tags map[string]string) error {
 	// Logger is printf-style, not structured; include the error in the formatted message.
 	logger.Error("%s: %v", msg, err)
 	if c.recorder != nil {
-		ctx := context.WithValue(context.Background(), CtxKeyRequestID, requestID)
 		if recordErr := c.recorder.RecordError(ctx, nil, requestID, err, tags); recordErr != nil {
 			logger.Error("%s: cannot record error %v", "error", recordErr)
 		}
@@ -189,6 +211,15 @@ func (c *Client) setHeaders(req *http.Request, requestID string) {
 	req.Header.Set("Api-Version", consts.ApiVersion)
 }
 
+// logPayload logs a request or response body at debug level, with card data and secrets masked.
+func (c *Client) logPayload(logger *log.Logger, label string, raw []byte) {
+	if !logger.Enabled(log.LevelDebug) {
+		return
+	}
+
+	logger.Debug("%s: %s", label, c.redactor.Bytes(raw))
+}
+
 // safeClose ensures the body is closed properly and logs any error.
 func (c *Client) safeClose(body io.ReadCloser, logger *log.Logger) {
 	if err := body.Close(); err != nil {
@@ -196,6 +227,28 @@ func (c *Client) safeClose(body io.ReadCloser, logger *log.Logger) {
 	}
 }
 
+// requestLogger binds the attributes shared by every line logged for one request: the request ID,
+// the endpoint and, when the tags carry them, the operation and payment identifiers.
+func requestLogger(logger *log.Logger, requestID, endpoint string, tags map[string]string) *log.Logger {
+	attrs := []any{"request_id", requestID, "endpoint", endpoint}
+
+	if operation := tags["operation"]; operation != "" {
+		attrs = append(attrs, "operation", operation)
+	}
+
+	if pmtID := tags["payment_id"]; pmtID != "" {
+		attrs = append(attrs, "pmt_id", pmtID)
+	}
+
+	if extID := tags["invoice_id"]; extID != "" {
+		attrs = append(attrs, "ext_id", extID)
+	} else if extID := tags["ext_id"]; extID != "" {
+		attrs = append(attrs, "ext_id", extID)
+	}
+
+	return logger.With(attrs...)
+}
+
 // tagsRetriever extracts tags from the request for logging or recording purposes.
 func tagsRetriever(request *ipay.RequestWrapper) map[string]string {
 	tags := make(map[string]string)
@@ -216,25 +269,46 @@ func tagsRetriever(request *ipay.RequestWrapper) map[string]string {
 }
 
 // ApiXML handles XML API requests.
-func (c *Client) ApiXML(ipayXMLPayment *ipay.XmlPayment) (*ipay.PaymentResponse, error) {
-	logger := c.loggerFor(loggerTypeHTTPXML)
-	requestID := uuid.New().String()
+func (c *Client) ApiXML(ctx context.Context, ipayXMLPayment *ipay.XmlPayment) (*ipay.PaymentResponse, error) {
+	op := operation{name: consts.PaymentURL, endpoint: c.options.Endpoints.ApiXML}
+	if ipayXMLPayment.Auth.MchID != nil {
+		op.merchantID = strconv.FormatInt(*ipayXMLPayment.Auth.MchID, 10)
+	}
+
+	ctx, span := c.startOperation(ctx, op)
+	attemptCtx, finish := c.startAttempt(ctx, op, nil)
+	response, statusCode, err := c.sendXML(attemptCtx, ipayXMLPayment)
+	finish(statusCode, err)
+
+	status := ""
+	if response != nil {
+		status = strconv.Itoa(response.Status)
+	}
+	endOperation(span, status, err)
+
+	return response, err
+}
+
+// sendXML posts a payment to the XML API. XML requests are not retried.
+func (c *Client) sendXML(ctx context.Context, ipayXMLPayment *ipay.XmlPayment) (*ipay.PaymentResponse, int, error) {
+	ctx, requestID := EnsureRequestID(ctx)
+	logger := requestLogger(c.loggerFor(loggerTypeHTTPXML), requestID, c.options.Endpoints.ApiXML, map[string]string{"operation": consts.PaymentURL})
 
 	logger.Debug("Request ID: %v", requestID)
 
 	xmlBody, err := ipayXMLPayment.Marshal()
 	if err != nil {
-		return nil, fmt.Errorf("cannot marshal request: %w", err)
+		return nil, 0, fmt.Errorf("cannot marshal request: %w", err)
 	}
 
-	logger.Debug("Request: %v", string(xmlBody))
+	c.logPayload(logger, "Request", xmlBody)
 
 	formData := url.Values{}
 	formData.Set("data", string(xmlBody))
 
-	req, err := http.NewRequest("POST", consts.ApiXMLUrl, strings.NewReader(formData.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.options.Endpoints.ApiXML, strings.NewReader(formData.Encode()))
 	if err != nil {
-		return nil, c.logAndReturnError("cannot create XML request", err, logger, requestID, nil)
+		return nil, 0, c.logAndReturnError(ctx, "cannot create XML request", err, logger, requestID, nil)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -246,21 +320,25 @@ func (c *Client) ApiXML(ipayXMLPayment *ipay.XmlPayment) (*ipay.PaymentResponse,
 	tStart := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot send XML request", err, logger, requestID, nil)
+		return nil, 0, c.logAndReturnError(ctx, "cannot send XML request", err, logger.With("duration", time.Since(tStart)), requestID, nil)
 	}
+
+	logger = logger.With("http_status", resp.StatusCode, "duration", time.Since(tStart))
 	logger.Debug("Request time: %v", time.Since(tStart))
 
 	defer c.safeClose(resp.Body, logger)
 
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot read XML response", err, logger, requestID, nil)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "cannot read XML response", err, logger, requestID, nil)
 	}
 
-	logger.Debug("Response: %v", string(raw))
+	c.logPayload(logger, "Response", raw)
 	logger.Debug("Response status: %v", resp.StatusCode)
 
-	return ipay.UnmarshalXmlResponse(raw)
+	response, err := ipay.UnmarshalXmlResponse(raw)
+
+	return response, resp.StatusCode, err
 }
 
 // SetClient allows for replacing the default HTTP client.
@@ -268,6 +346,36 @@ func (c *Client) SetClient(cl *http.Client) {
 	c.client = cl
 }
 
+// Timeout returns the timeout of a single request of the HTTP client; zero means none.
+func (c *Client) Timeout() time.Duration {
+	if c.client == nil {
+		return 0
+	}
+
+	return c.client.Timeout
+}
+
+// SetEndpoints overrides the endpoints used by the client. Empty fields keep their current value.
+func (c *Client) SetEndpoints(endpoints Endpoints) {
+	c.options.Endpoints = c.options.Endpoints.Merge(endpoints)
+}
+
+// Endpoints returns the endpoints currently used by the client.
+func (c *Client) Endpoints() Endpoints {
+	return c.options.Endpoints
+}
+
+// SetRedactor replaces the redactor applied to payloads before they are logged or recorded.
+// A nil redactor turns redaction off.
+func (c *Client) SetRedactor(r *redact.Redactor) {
+	c.redactor = r
+}
+
+// Redactor returns the redactor of the client.
+func (c *Client) Redactor() *redact.Redactor {
+	return c.redactor
+}
+
 // SetRecorder allows for attaching a new recorder.
 func (c *Client) SetRecorder(r recorder.Recorder) {
 	c.recorder = r
@@ -275,6 +383,12 @@ func (c *Client) SetRecorder(r recorder.Recorder) {
 
 // NewClient initializes a new HTTP client with options.
 func NewClient(options *Options) *Client {
+	if options == nil {
+		options = DefaultOptions()
+	}
+
+	options.Endpoints = DefaultEndpoints().Merge(options.Endpoints)
+
 	dialer := &net.Dialer{
 		Timeout:   30 * time.Second,
 		KeepAlive: options.KeepAlive,
@@ -293,13 +407,11 @@ func NewClient(options *Options) *Client {
 	}
 
 	return &Client{
-		client:  cl,
-		options: options,
-		loggers: map[loggerType]*log.Logger{
-			loggerTypeHTTP:      log.NewLogger(string(loggerTypeHTTP)),
-			loggerTypeApplePay:  log.NewLogger(string(loggerTypeApplePay)),
-			loggerTypeGooglePay: log.NewLogger(string(loggerTypeGooglePay)),
-			loggerTypeHTTPXML:   log.NewLogger(string(loggerTypeHTTPXML)),
-		},
+		client:   cl,
+		options:  options,
+		sink:     log.NewSink(nil),
+		redactor: redact.New(),
+		tracer:   telemetry.Noop{},
+		metrics:  telemetry.Noop{},
 	}
 }
@@ -24,7 +24,14 @@
 
 package http
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/stremovskyy/go-ipay/consts"
+)
 
 // Options for http client
 type Options struct {
@@ -33,6 +40,48 @@ type Options struct {
 	MaxIdleConns    int
 	IdleConnTimeout time.Duration
 	IsDebug         bool
+	Endpoints       Endpoints
+}
+
+// Endpoints holds the URLs the client sends requests to.
+type Endpoints struct {
+	Api       string
+	ApplePay  string
+	GooglePay string
+	ApiXML    string
+	Repayment string
+}
+
+// DefaultEndpoints returns the production iPay endpoints.
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Api:       consts.ApiUrl,
+		ApplePay:  consts.ApplePayUrl,
+		GooglePay: consts.GooglePayUrl,
+		ApiXML:    consts.ApiXMLUrl,
+		Repayment: consts.RepaymentUrl,
+	}
+}
+
+// Merge returns a copy of e where every non-empty field of override replaces the current value.
+func (e Endpoints) Merge(override Endpoints) Endpoints {
+	if override.Api != "" {
+		e.Api = override.Api
+	}
+	if override.ApplePay != "" {
+		e.ApplePay = override.ApplePay
+	}
+	if override.GooglePay != "" {
+		e.GooglePay = override.GooglePay
+	}
+	if override.ApiXML != "" {
+		e.ApiXML = override.ApiXML
+	}
+	if override.Repayment != "" {
+		e.Repayment = override.Repayment
+	}
+
+	return e
 }
 
 func DefaultOptions() *Options {
@@ -42,6 +91,7 @@ func DefaultOptions() *Options {
 		MaxIdleConns:    100,
 		IdleConnTimeout: 90 * time.Second,
 		IsDebug:         false,
+		Endpoints:       DefaultEndpoints(),
 	}
 }
 
@@ -50,3 +100,42 @@ type CtxKey string
 const (
 	CtxKeyRequestID CtxKey = "request_id"
 )
+
+// ContextWithRequestID returns a copy of ctx carrying the given request ID.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, CtxKeyRequestID, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored on ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	requestID, ok := ctx.Value(CtxKeyRequestID).(string)
+	if !ok || requestID == "" {
+		return "", false
+	}
+
+	return requestID, true
+}
+
+// EnsureRequestID normalizes ctx and makes sure it carries a request ID,
+// reusing the caller's one when present.
+func EnsureRequestID(ctx context.Context) (context.Context, string) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		return ctx, requestID
+	}
+
+	requestID := uuid.New().String()
+
+	return ContextWithRequestID(ctx, requestID), requestID
+}
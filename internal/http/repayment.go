@@ -34,8 +34,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"strings"
-
-	"github.com/google/uuid"
+	"time"
 
 	"github.com/stremovskyy/go-ipay/consts"
 	"github.com/stremovskyy/go-ipay/log"
@@ -45,201 +44,236 @@ import (
 const loggerTypeRepayment loggerType = "iPay Repayment:"
 
 // RepaymentJSONApi sends a Repayment API request as JSON (application/json).
-func (c *Client) RepaymentJSONApi(apiRequest *repayment.RequestWrapper) (*repayment.Response, error) {
-	return c.sendRepaymentJSONRequest(consts.RepaymentUrl, apiRequest, c.loggerFor(loggerTypeRepayment))
+func (c *Client) RepaymentJSONApi(ctx context.Context, apiRequest *repayment.RequestWrapper) (*repayment.Response, error) {
+	return c.sendRepaymentJSONRequest(ctx, c.options.Endpoints.Repayment, apiRequest, c.loggerFor(loggerTypeRepayment))
 }
 
 // RepaymentProcessingFileApi sends a Repayment API request and returns raw bytes (typically a CSV file).
 // The API may respond with JSON errors, so callers should treat a non-nil error as authoritative even
 // when raw bytes are returned.
-func (c *Client) RepaymentProcessingFileApi(apiRequest *repayment.RequestWrapper) ([]byte, error) {
-	return c.sendRepaymentProcessingFileRequest(consts.RepaymentUrl, apiRequest, c.loggerFor(loggerTypeRepayment))
+func (c *Client) RepaymentProcessingFileApi(ctx context.Context, apiRequest *repayment.RequestWrapper) ([]byte, error) {
+	return c.sendRepaymentProcessingFileRequest(ctx, c.options.Endpoints.Repayment, apiRequest, c.loggerFor(loggerTypeRepayment))
 }
 
 // RepaymentApi sends a Repayment API request with a CSV file in multipart/form-data.
-func (c *Client) RepaymentApi(apiRequest *repayment.RequestWrapper, fileName string, file io.Reader) (*repayment.Response, error) {
-	return c.sendRepaymentMultipartRequest(consts.RepaymentUrl, apiRequest, fileName, file, c.loggerFor(loggerTypeRepayment))
+func (c *Client) RepaymentApi(ctx context.Context, apiRequest *repayment.RequestWrapper, fileName string, file io.Reader) (*repayment.Response, error) {
+	return c.sendRepaymentMultipartRequest(ctx, c.options.Endpoints.Repayment, apiRequest, fileName, file, c.loggerFor(loggerTypeRepayment))
 }
 
-func (c *Client) sendRepaymentJSONRequest(apiURL string, apiRequest *repayment.RequestWrapper, logger *log.Logger) (*repayment.Response, error) {
-	requestID := uuid.New().String()
+func (c *Client) sendRepaymentJSONRequest(ctx context.Context, apiURL string, apiRequest *repayment.RequestWrapper, logger *log.Logger) (*repayment.Response, error) {
+	ctx, requestID := EnsureRequestID(ctx)
+	logger = requestLogger(logger, requestID, apiURL, tagsRetrieverRepayment(apiRequest))
 	logger.Debug("Request ID: %v", requestID)
 
 	if apiRequest == nil {
-		return nil, c.logAndReturnError("repayment request is nil", fmt.Errorf("request is nil"), logger, requestID, nil)
+		return nil, c.logAndReturnError(ctx, "repayment request is nil", fmt.Errorf("request is nil"), logger, requestID, nil)
 	}
 
 	jsonBody, err := json.Marshal(apiRequest)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot marshal repayment request", err, logger, requestID, tagsRetrieverRepayment(apiRequest))
+		return nil, c.logAndReturnError(ctx, "cannot marshal repayment request", err, logger, requestID, tagsRetrieverRepayment(apiRequest))
 	}
 
-	logger.Debug("Request: %v", string(jsonBody))
+	c.logPayload(logger, "Request", jsonBody)
 
-	ctx := context.WithValue(context.Background(), CtxKeyRequestID, requestID)
-	tags := tagsRetrieverRepayment(apiRequest)
+	op := repaymentOperation(apiURL, apiRequest)
+	ctx, span := c.startOperation(ctx, op)
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, c.logAndReturnError("cannot create repayment request", err, logger, requestID, tags)
-	}
+	var response *repayment.Response
+	err = c.withRetry(ctx, logger, tagsRetrieverRepayment(apiRequest), func(tags map[string]string) (int, error) {
+		attemptCtx, finish := c.startAttempt(ctx, op, tags)
+		resp, statusCode, attemptErr := c.sendRepaymentJSONAttempt(attemptCtx, apiURL, jsonBody, logger, requestID, tags)
+		finish(statusCode, attemptErr)
+		response = resp
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "GO IPAY/"+consts.Version)
-	req.Header.Set("X-Request-ID", requestID)
+		return statusCode, attemptErr
+	})
 
-	if c.recorder != nil {
-		if errr := c.recorder.RecordRequest(ctx, nil, requestID, jsonBody, tags); errr != nil {
-			logger.Error("%s: cannot record request: %v", "error", errr)
-		}
-	}
+	endOperation(span, repaymentStatus(response), err)
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, c.logAndReturnError("cannot send repayment request", err, logger, requestID, tags)
-	}
-	defer c.safeClose(resp.Body, logger)
+	return response, err
+}
 
-	raw, err := io.ReadAll(resp.Body)
+func (c *Client) sendRepaymentJSONAttempt(ctx context.Context, apiURL string, jsonBody []byte, logger *log.Logger, requestID string, tags map[string]string) (*repayment.Response, int, error) {
+	raw, resp, err := c.doRepaymentJSON(ctx, apiURL, jsonBody, "application/json", logger, requestID, tags)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot read repayment response", err, logger, requestID, tags)
+		return nil, 0, err
 	}
 
-	logger.Debug("Response: %v", string(raw))
-	logger.Debug("Response status: %v", resp.StatusCode)
-
-	if c.recorder != nil {
-		if errr := c.recorder.RecordResponse(ctx, nil, requestID, raw, tags); errr != nil {
-			logger.Error("%s: cannot record response %v", "error", errr)
-		}
-	}
+	logger = logger.With("http_status", resp.StatusCode)
 
 	if !isLikelyJSONResponse(resp, raw) {
 		apiErr := nonJSONRepaymentAPIError(resp, raw)
-		return nil, c.logAndReturnError("repayment API returned non-JSON response", apiErr, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "repayment API returned non-JSON response", apiErr, logger, requestID, tags)
 	}
 
 	response, err := repayment.UnmarshalJSONResponse(raw)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot unmarshal repayment response", err, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "cannot unmarshal repayment response", err, logger, requestID, tags)
 	}
 
-	return response, response.GetError()
+	return response, resp.StatusCode, response.GetError()
 }
 
-func (c *Client) sendRepaymentProcessingFileRequest(apiURL string, apiRequest *repayment.RequestWrapper, logger *log.Logger) ([]byte, error) {
-	requestID := uuid.New().String()
+func (c *Client) sendRepaymentProcessingFileRequest(ctx context.Context, apiURL string, apiRequest *repayment.RequestWrapper, logger *log.Logger) ([]byte, error) {
+	ctx, requestID := EnsureRequestID(ctx)
+	logger = requestLogger(logger, requestID, apiURL, tagsRetrieverRepayment(apiRequest))
 	logger.Debug("Request ID: %v", requestID)
 
 	if apiRequest == nil {
-		return nil, c.logAndReturnError("repayment request is nil", fmt.Errorf("request is nil"), logger, requestID, nil)
+		return nil, c.logAndReturnError(ctx, "repayment request is nil", fmt.Errorf("request is nil"), logger, requestID, nil)
 	}
 
 	jsonBody, err := json.Marshal(apiRequest)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot marshal repayment request", err, logger, requestID, tagsRetrieverRepayment(apiRequest))
+		return nil, c.logAndReturnError(ctx, "cannot marshal repayment request", err, logger, requestID, tagsRetrieverRepayment(apiRequest))
 	}
 
-	logger.Debug("Request: %v", string(jsonBody))
+	c.logPayload(logger, "Request", jsonBody)
 
-	ctx := context.WithValue(context.Background(), CtxKeyRequestID, requestID)
-	tags := tagsRetrieverRepayment(apiRequest)
+	op := repaymentOperation(apiURL, apiRequest)
+	ctx, span := c.startOperation(ctx, op)
+
+	var raw []byte
+	err = c.withRetry(ctx, logger, tagsRetrieverRepayment(apiRequest), func(tags map[string]string) (int, error) {
+		attemptCtx, finish := c.startAttempt(ctx, op, tags)
+		body, statusCode, attemptErr := c.sendRepaymentProcessingFileAttempt(attemptCtx, apiURL, jsonBody, logger, requestID, tags)
+		finish(statusCode, attemptErr)
+		raw = body
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(jsonBody))
+		return statusCode, attemptErr
+	})
+
+	endOperation(span, "", err)
+
+	return raw, err
+}
+
+func (c *Client) sendRepaymentProcessingFileAttempt(ctx context.Context, apiURL string, jsonBody []byte, logger *log.Logger, requestID string, tags map[string]string) ([]byte, int, error) {
+	raw, resp, err := c.doRepaymentJSON(ctx, apiURL, jsonBody, "text/csv, application/json", logger, requestID, tags)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot create repayment request", err, logger, requestID, tags)
+		return nil, 0, err
+	}
+
+	logger = logger.With("http_status", resp.StatusCode)
+
+	isJSON := false
+	if ct := resp.Header.Get("Content-Type"); ct != "" && (ct == "application/json" || bytes.Contains([]byte(ct), []byte("application/json"))) {
+		isJSON = true
+	}
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		isJSON = true
+	}
+
+	// If the server returned JSON, attempt to parse and surface API errors.
+	if isJSON {
+		parsed, parseErr := repayment.UnmarshalJSONResponse(raw)
+		if parseErr != nil {
+			return raw, resp.StatusCode, c.logAndReturnError(ctx, "cannot unmarshal repayment response", parseErr, logger, requestID, tags)
+		}
+		if apiErr := parsed.GetError(); apiErr != nil {
+			return raw, resp.StatusCode, apiErr
+		}
+	}
+
+	// Treat non-2xx statuses as errors even when the payload is not JSON.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return raw, resp.StatusCode, fmt.Errorf("repayment processing file: unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	return raw, resp.StatusCode, nil
+}
+
+// doRepaymentJSON posts jsonBody to apiURL, recording the exchange, and returns the raw response body.
+func (c *Client) doRepaymentJSON(ctx context.Context, apiURL string, jsonBody []byte, accept string, logger *log.Logger, requestID string, tags map[string]string) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, nil, c.logAndReturnError(ctx, "cannot create repayment request", err, logger, requestID, tags)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/csv, application/json")
+	req.Header.Set("Accept", accept)
 	req.Header.Set("User-Agent", "GO IPAY/"+consts.Version)
 	req.Header.Set("X-Request-ID", requestID)
 
 	if c.recorder != nil {
-		if errr := c.recorder.RecordRequest(ctx, nil, requestID, jsonBody, tags); errr != nil {
+		if errr := c.recorder.RecordRequest(ctx, nil, requestID, c.redactor.Bytes(jsonBody), tags); errr != nil {
 			logger.Error("%s: cannot record request: %v", "error", errr)
 		}
 	}
 
+	tStart := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot send repayment request", err, logger, requestID, tags)
+		return nil, nil, c.logAndReturnError(ctx, "cannot send repayment request", err, logger.With("duration", time.Since(tStart)), requestID, tags)
 	}
 	defer c.safeClose(resp.Body, logger)
 
+	logger = logger.With("http_status", resp.StatusCode, "duration", time.Since(tStart))
+
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot read repayment response", err, logger, requestID, tags)
+		return nil, nil, c.logAndReturnError(ctx, "cannot read repayment response", err, logger, requestID, tags)
 	}
 
-	logger.Debug("Response: %v", string(raw))
+	c.logPayload(logger, "Response", raw)
 	logger.Debug("Response status: %v", resp.StatusCode)
 
 	if c.recorder != nil {
-		if errr := c.recorder.RecordResponse(ctx, nil, requestID, raw, tags); errr != nil {
+		if errr := c.recorder.RecordResponse(ctx, nil, requestID, c.redactor.Bytes(raw), tags); errr != nil {
 			logger.Error("%s: cannot record response %v", "error", errr)
 		}
 	}
 
-	isJSON := false
-	if ct := resp.Header.Get("Content-Type"); ct != "" && (ct == "application/json" || bytes.Contains([]byte(ct), []byte("application/json"))) {
-		isJSON = true
-	}
-	trimmed := bytes.TrimSpace(raw)
-	if len(trimmed) > 0 && trimmed[0] == '{' {
-		isJSON = true
-	}
+	return raw, resp, nil
+}
 
-	// If the server returned JSON, attempt to parse and surface API errors.
-	if isJSON {
-		parsed, parseErr := repayment.UnmarshalJSONResponse(raw)
-		if parseErr != nil {
-			return raw, c.logAndReturnError("cannot unmarshal repayment response", parseErr, logger, requestID, tags)
-		}
-		if apiErr := parsed.GetError(); apiErr != nil {
-			return raw, apiErr
-		}
-	}
+func (c *Client) sendRepaymentMultipartRequest(ctx context.Context, apiURL string, apiRequest *repayment.RequestWrapper, fileName string, file io.Reader, logger *log.Logger) (*repayment.Response, error) {
+	op := repaymentOperation(apiURL, apiRequest)
+	ctx, span := c.startOperation(ctx, op)
 
-	// Treat non-2xx statuses as errors even when the payload is not JSON.
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return raw, fmt.Errorf("repayment processing file: unexpected HTTP status %d", resp.StatusCode)
-	}
+	attemptCtx, finish := c.startAttempt(ctx, op, nil)
+	response, statusCode, err := c.sendRepaymentMultipart(attemptCtx, apiURL, apiRequest, fileName, file, logger)
+	finish(statusCode, err)
 
-	return raw, nil
+	endOperation(span, repaymentStatus(response), err)
+
+	return response, err
 }
 
-func (c *Client) sendRepaymentMultipartRequest(apiURL string, apiRequest *repayment.RequestWrapper, fileName string, file io.Reader, logger *log.Logger) (*repayment.Response, error) {
-	requestID := uuid.New().String()
+// sendRepaymentMultipart uploads a repayment file. Multipart requests are not retried, as the
+// file reader cannot be rewound.
+func (c *Client) sendRepaymentMultipart(ctx context.Context, apiURL string, apiRequest *repayment.RequestWrapper, fileName string, file io.Reader, logger *log.Logger) (*repayment.Response, int, error) {
+	ctx, requestID := EnsureRequestID(ctx)
+	logger = requestLogger(logger, requestID, apiURL, tagsRetrieverRepayment(apiRequest))
 	logger.Debug("Request ID: %v", requestID)
 
 	if apiRequest == nil {
-		return nil, c.logAndReturnError("repayment request is nil", fmt.Errorf("request is nil"), logger, requestID, nil)
+		return nil, 0, c.logAndReturnError(ctx, "repayment request is nil", fmt.Errorf("request is nil"), logger, requestID, nil)
 	}
 	if file == nil {
-		return nil, c.logAndReturnError("repayment file is nil", fmt.Errorf("file is nil"), logger, requestID, tagsRetrieverRepayment(apiRequest))
+		return nil, 0, c.logAndReturnError(ctx, "repayment file is nil", fmt.Errorf("file is nil"), logger, requestID, tagsRetrieverRepayment(apiRequest))
 	}
 	if fileName == "" {
-		return nil, c.logAndReturnError("repayment file name is empty", fmt.Errorf("file name is empty"), logger, requestID, tagsRetrieverRepayment(apiRequest))
+		return nil, 0, c.logAndReturnError(ctx, "repayment file name is empty", fmt.Errorf("file name is empty"), logger, requestID, tagsRetrieverRepayment(apiRequest))
 	}
 
 	jsonBody, err := json.Marshal(apiRequest)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot marshal repayment request", err, logger, requestID, tagsRetrieverRepayment(apiRequest))
+		return nil, 0, c.logAndReturnError(ctx, "cannot marshal repayment request", err, logger, requestID, tagsRetrieverRepayment(apiRequest))
 	}
 
-	logger.Debug("Request: %v", string(jsonBody))
+	c.logPayload(logger, "Request", jsonBody)
 	logger.Debug("File: %s", fileName)
 
-	ctx := context.WithValue(context.Background(), CtxKeyRequestID, requestID)
 	tags := tagsRetrieverRepayment(apiRequest)
 
 	bodyReader, contentType := buildRepaymentMultipartBody(jsonBody, fileName, file)
 
-	req, err := http.NewRequest("POST", apiURL, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bodyReader)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot create repayment request", err, logger, requestID, tags)
+		return nil, 0, c.logAndReturnError(ctx, "cannot create repayment request", err, logger, requestID, tags)
 	}
 
 	req.Header.Set("Content-Type", contentType)
@@ -248,42 +282,45 @@ func (c *Client) sendRepaymentMultipartRequest(apiURL string, apiRequest *repaym
 	req.Header.Set("X-Request-ID", requestID)
 
 	if c.recorder != nil {
-		if errr := c.recorder.RecordRequest(ctx, nil, requestID, jsonBody, tags); errr != nil {
+		if errr := c.recorder.RecordRequest(ctx, nil, requestID, c.redactor.Bytes(jsonBody), tags); errr != nil {
 			logger.Error("%s: cannot record request: %v", "error", errr)
 		}
 	}
 
+	tStart := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot send repayment request", err, logger, requestID, tags)
+		return nil, 0, c.logAndReturnError(ctx, "cannot send repayment request", err, logger.With("duration", time.Since(tStart)), requestID, tags)
 	}
 	defer c.safeClose(resp.Body, logger)
 
+	logger = logger.With("http_status", resp.StatusCode, "duration", time.Since(tStart))
+
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot read repayment response", err, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "cannot read repayment response", err, logger, requestID, tags)
 	}
 
-	logger.Debug("Response: %v", string(raw))
+	c.logPayload(logger, "Response", raw)
 	logger.Debug("Response status: %v", resp.StatusCode)
 
 	if c.recorder != nil {
-		if errr := c.recorder.RecordResponse(ctx, nil, requestID, raw, tags); errr != nil {
+		if errr := c.recorder.RecordResponse(ctx, nil, requestID, c.redactor.Bytes(raw), tags); errr != nil {
 			logger.Error("%s: cannot record response %v", "error", errr)
 		}
 	}
 
 	if !isLikelyJSONResponse(resp, raw) {
 		apiErr := nonJSONRepaymentAPIError(resp, raw)
-		return nil, c.logAndReturnError("repayment API returned non-JSON response", apiErr, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "repayment API returned non-JSON response", apiErr, logger, requestID, tags)
 	}
 
 	response, err := repayment.UnmarshalJSONResponse(raw)
 	if err != nil {
-		return nil, c.logAndReturnError("cannot unmarshal repayment response", err, logger, requestID, tags)
+		return nil, resp.StatusCode, c.logAndReturnError(ctx, "cannot unmarshal repayment response", err, logger, requestID, tags)
 	}
 
-	return response, response.GetError()
+	return response, resp.StatusCode, response.GetError()
 }
 
 func isLikelyJSONResponse(resp *http.Response, raw []byte) bool {
@@ -320,7 +357,7 @@ func nonJSONRepaymentAPIError(resp *http.Response, raw []byte) error {
 
 	// Include HTTP metadata to help debug gateways/proxies returning plain text.
 	msg := fmt.Sprintf("unexpected non-JSON response (status=%d, content-type=%q): %s", status, contentType, body)
-	return &repayment.APIError{Message: msg}
+	return &repayment.APIError{Message: msg, StatusCode: status}
 }
 
 func buildRepaymentMultipartBody(jsonBody []byte, fileName string, file io.Reader) (io.Reader, string) {
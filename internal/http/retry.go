@@ -0,0 +1,164 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/log"
+)
+
+// RetryPolicy describes how a failed request is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt.
+	Multiplier float64
+	// Jitter is the fraction (0..1) of every delay that is randomized.
+	Jitter float64
+}
+
+// Backoff returns the delay before attempt+1, given that attempt (starting at 1) has failed.
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+const ctxKeyRetryPolicy CtxKey = "retry_policy"
+
+// ContextWithRetryPolicy returns a copy of ctx that makes the client retry with policy.
+// A nil policy disables retries.
+func ContextWithRetryPolicy(ctx context.Context, policy *RetryPolicy) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, ctxKeyRetryPolicy, policy)
+}
+
+func retryPolicyFromContext(ctx context.Context) *RetryPolicy {
+	policy, _ := ctx.Value(ctxKeyRetryPolicy).(*RetryPolicy)
+
+	return policy
+}
+
+// IsRetryable reports whether an attempt that ended with statusCode and err is worth repeating:
+// transport failures, HTTP 5xx and transient iPay error codes are; cancellation is not.
+func IsRetryable(ctx context.Context, statusCode int, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if statusCode >= 500 {
+		return true
+	}
+
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var ipayErr *ipay.IpayError
+	if errors.As(err, &ipayErr) {
+		return ipayErr.IsTransient()
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// withRetry calls attempt until it succeeds, fails with a non-retryable error or the policy
+// stored on ctx is exhausted. Every attempt shares the request ID; tags get the attempt number.
+func (c *Client) withRetry(ctx context.Context, logger *log.Logger, tags map[string]string, attempt func(tags map[string]string) (int, error)) error {
+	policy := retryPolicyFromContext(ctx)
+
+	for n := 1; ; n++ {
+		attemptTags := tags
+		if policy != nil && policy.MaxAttempts > 1 {
+			attemptTags = make(map[string]string, len(tags)+1)
+			for k, v := range tags {
+				attemptTags[k] = v
+			}
+			attemptTags["attempt"] = strconv.Itoa(n)
+		}
+
+		statusCode, err := attempt(attemptTags)
+		if policy == nil || n >= policy.MaxAttempts || !IsRetryable(ctx, statusCode, err) {
+			return err
+		}
+
+		delay := policy.Backoff(n)
+		logger.Warning("attempt %d failed (status %d, error: %v), retrying in %v", n, statusCode, err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return err
+		case <-timer.C:
+		}
+	}
+}
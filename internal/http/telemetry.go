@@ -0,0 +1,191 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/repayment"
+	"github.com/stremovskyy/go-ipay/telemetry"
+)
+
+// operation describes the logical call whose HTTP attempts are traced and measured.
+type operation struct {
+	name       string
+	action     string
+	merchantID string
+	endpoint   string
+}
+
+func ipayOperation(endpoint string, request *ipay.RequestWrapper) operation {
+	op := operation{name: request.Operation, action: string(request.Request.Action), endpoint: endpoint}
+	if request.Request.Auth.MchID != nil {
+		op.merchantID = strconv.FormatInt(*request.Request.Auth.MchID, 10)
+	}
+
+	return op
+}
+
+func repaymentOperation(endpoint string, request *repayment.RequestWrapper) operation {
+	op := operation{endpoint: endpoint}
+	if request == nil {
+		return op
+	}
+
+	op.name, op.action = request.Operation, string(request.Request.Action)
+	if request.Request.Body.MchID != nil {
+		op.merchantID = strconv.FormatInt(*request.Request.Body.MchID, 10)
+	}
+
+	return op
+}
+
+func (o operation) attributes() []telemetry.Attribute {
+	attrs := []telemetry.Attribute{
+		telemetry.String(telemetry.AttrOperation, o.name),
+		telemetry.String(telemetry.AttrEndpoint, o.endpoint),
+	}
+
+	if o.action != "" {
+		attrs = append(attrs, telemetry.String(telemetry.AttrAction, o.action))
+	}
+
+	if o.merchantID != "" {
+		attrs = append(attrs, telemetry.String(telemetry.AttrMerchantID, o.merchantID))
+	}
+
+	return attrs
+}
+
+// SetTracer sets the tracer of the client. A nil tracer turns tracing off.
+func (c *Client) SetTracer(tracer telemetry.Tracer) {
+	if tracer == nil {
+		tracer = telemetry.Noop{}
+	}
+
+	c.tracer = tracer
+}
+
+// SetMetrics sets the metrics sink of the client. A nil sink turns metrics off.
+func (c *Client) SetMetrics(metrics telemetry.Metrics) {
+	if metrics == nil {
+		metrics = telemetry.Noop{}
+	}
+
+	c.metrics = metrics
+}
+
+// startOperation starts the span that parents every attempt of op.
+func (c *Client) startOperation(ctx context.Context, op operation) (context.Context, telemetry.Span) {
+	return c.tracer.Start(ctx, telemetry.SpanPrefix+op.name, op.attributes()...)
+}
+
+// endOperation ends an operation span with its outcome: the payment or repayment status when
+// iPay answered, the error otherwise.
+func endOperation(span telemetry.Span, status string, err error) {
+	if status != "" {
+		span.SetAttributes(telemetry.String(telemetry.AttrStatus, status))
+	}
+
+	if err != nil {
+		span.SetAttributes(telemetry.String(telemetry.AttrErrorType, errorType(err)))
+		span.RecordError(err)
+	}
+
+	span.End()
+}
+
+// startAttempt starts the span of one HTTP attempt. The returned func ends it and reports the
+// attempt to the metrics sink.
+func (c *Client) startAttempt(ctx context.Context, op operation, tags map[string]string) (context.Context, func(statusCode int, err error)) {
+	attempt := 1
+	if n, err := strconv.Atoi(tags["attempt"]); err == nil {
+		attempt = n
+	}
+
+	attrs := append(op.attributes(), telemetry.Int(telemetry.AttrAttempt, attempt))
+	ctx, span := c.tracer.Start(ctx, telemetry.SpanAttempt, attrs...)
+	start := time.Now()
+
+	return ctx, func(statusCode int, err error) {
+		request := telemetry.Request{
+			Operation:  op.name,
+			Action:     op.action,
+			Endpoint:   op.endpoint,
+			MerchantID: op.merchantID,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Duration:   time.Since(start),
+			Err:        err,
+		}
+
+		if statusCode != 0 {
+			span.SetAttributes(telemetry.Int(telemetry.AttrHTTPStatus, statusCode))
+		}
+
+		if err != nil {
+			request.ErrorType = errorType(err)
+			span.SetAttributes(telemetry.String(telemetry.AttrErrorType, request.ErrorType))
+			span.RecordError(err)
+		}
+
+		span.End()
+		c.metrics.RecordRequest(ctx, request)
+	}
+}
+
+func errorType(err error) string {
+	var ipayErr *ipay.IpayError
+	if errors.As(err, &ipayErr) && ipayErr.Type != "" {
+		return ipayErr.Type
+	}
+
+	var apiErr *repayment.APIError
+	if errors.As(err, &apiErr) {
+		return "repayment"
+	}
+
+	return ipay.ErrorTypeTransport
+}
+
+func paymentStatus(response *ipay.Response) string {
+	if response == nil || response.Status == nil {
+		return ""
+	}
+
+	return response.Status.String()
+}
+
+func repaymentStatus(response *repayment.Response) string {
+	if response == nil || response.Status == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*response.Status)
+}
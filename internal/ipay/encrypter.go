@@ -31,12 +31,16 @@ import (
 	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"github.com/stremovskyy/go-ipay/log"
 )
 
+// Cipher encrypts and decrypts data in the iPay format: base64 ciphertext, base64 GCM tag and
+// base64 IV joined with dots. The AES-256 key is the first 32 bytes of SHA-512 of the merchant key.
 type Cipher interface {
 	EncryptData(rawData string) (string, error)
+	DecryptData(encData string) (string, error)
 }
 
 type encrypter struct {
@@ -44,8 +48,9 @@ type encrypter struct {
 	logger *log.Logger
 }
 
-func NewCipher(key string) Cipher {
-	return &encrypter{key: key, logger: log.NewLogger("cipher")}
+// NewCipher returns the cipher of the merchant key. A nil logger logs nothing.
+func NewCipher(key string, logger *log.Logger) Cipher {
+	return &encrypter{key: key, logger: logger}
 }
 
 func (c *encrypter) EncryptData(rawData string) (string, error) {
@@ -53,33 +58,22 @@ func (c *encrypter) EncryptData(rawData string) (string, error) {
 		return "", fmt.Errorf("data to encrypt is empty")
 	}
 
-	if c.key == "" {
-		return "", fmt.Errorf("key is empty")
-	}
-
-	// Convert the key to a SHA-512 hash to ensure it's 64 bytes and then truncate to 32 bytes for AES-256
-	keyHash := sha512.Sum512([]byte(c.key))
-	key := keyHash[:32]
 	iv := make([]byte, 12)
 	if _, err := rand.Read(iv); err != nil {
 		return "", fmt.Errorf("failed to generate IV: %w", err)
 	}
 
-	c.logger.Debug("Encrypting data: %s", rawData)
-	c.logger.Debug("Key: %x", key)
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create new cipher: %w", err)
-	}
+	return c.encrypt(rawData, iv)
+}
 
-	aesgcm, err := cipher.NewGCM(block)
+func (c *encrypter) encrypt(rawData string, iv []byte) (string, error) {
+	aesgcm, err := c.aead()
 	if err != nil {
-		return "", fmt.Errorf("failed to create new GCM: %w", err)
+		return "", err
 	}
 
 	// Encrypt the data using per-call random IV.
-	ciphertext := aesgcm.Seal(nil, iv, []byte(rawData), nil) // #nosec G407 -- IV generated above via crypto/rand.
+	ciphertext := aesgcm.Seal(nil, iv, []byte(rawData), nil) // #nosec G407 -- IV generated by the caller via crypto/rand.
 	// Separate the tag from the ciphertext
 	tag := ciphertext[len(ciphertext)-aesgcm.Overhead():]
 	encData := ciphertext[:len(ciphertext)-aesgcm.Overhead()]
@@ -95,3 +89,61 @@ func (c *encrypter) EncryptData(rawData string) (string, error) {
 	// Return the encoded data and the tag concatenated, similar to the PHP version
 	return base64.StdEncoding.EncodeToString(encData) + "." + tagBase64 + "." + ivBase64, nil
 }
+
+func (c *encrypter) DecryptData(encData string) (string, error) {
+	if encData == "" {
+		return "", fmt.Errorf("data to decrypt is empty")
+	}
+
+	parts := strings.Split(encData, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("encrypted data has %d parts, want 3", len(parts))
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		b, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode part %d: %w", i+1, err)
+		}
+		decoded[i] = b
+	}
+
+	aesgcm, err := c.aead()
+	if err != nil {
+		return "", err
+	}
+
+	data, tag, iv := decoded[0], decoded[1], decoded[2]
+	if len(iv) != aesgcm.NonceSize() {
+		return "", fmt.Errorf("IV has %d bytes, want %d", len(iv), aesgcm.NonceSize())
+	}
+
+	plain, err := aesgcm.Open(nil, iv, append(data, tag...), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data: %w", err)
+	}
+
+	return string(plain), nil
+}
+
+func (c *encrypter) aead() (cipher.AEAD, error) {
+	if c.key == "" {
+		return nil, fmt.Errorf("key is empty")
+	}
+
+	// Convert the key to a SHA-512 hash to ensure it's 64 bytes and then truncate to 32 bytes for AES-256
+	keyHash := sha512.Sum512([]byte(c.key))
+
+	block, err := aes.NewCipher(keyHash[:32])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new cipher: %w", err)
+	}
+
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new GCM: %w", err)
+	}
+
+	return aesgcm, nil
+}
@@ -60,7 +60,7 @@ func TestNewCipher(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(
 			tt.name, func(t *testing.T) {
-				got := NewCipher(tt.args.key).(*encrypter) // Type assertion to access the key
+				got := NewCipher(tt.args.key, nil).(*encrypter) // Type assertion to access the key
 				if got.key != tt.wantKey {
 					t.Errorf("NewCipher() key = %v, want %v", got.key, tt.wantKey)
 				}
@@ -70,7 +70,7 @@ func TestNewCipher(t *testing.T) {
 }
 
 func Test_encrypter_EncryptData(t *testing.T) {
-	mockLogger := log.NewLogger("cipher")
+	mockLogger := log.NewSink(nil).Logger("cipher")
 
 	type fields struct {
 		key    string
@@ -170,3 +170,69 @@ func Test_encrypter_EncryptData(t *testing.T) {
 		)
 	}
 }
+
+// No published iPay vector or captured sandbox cdata is available, so this one was produced
+// outside Go, with the crypto module of Node.js 20 (OpenSSL 3.0), following the format described
+// on Cipher:
+//
+//	key := sha512("mysecretkey1234567890")[:32]
+//	aes-256-gcm(key, iv c0ffeec0ffeec0ffeec0ffee) -> base64(data).base64(tag).base64(iv)
+func Test_encrypter_KnownVector(t *testing.T) {
+	c := &encrypter{key: "mysecretkey1234567890"}
+	const (
+		plain = `{"pan":"4111111111111111","exp_month":"12","exp_year":"29","cvv":"123"}`
+		want  = "k2H6LxXHR8cXklbNmUqAuAoIbIdi22IJ6W1L4ArCj+RiEPYvkWW3XyBfD0BHCDToonACy9utbwWdEddOJDsUr8PpUqcXJ0s=.sFB6ldUaxx0Fbw7wXhnqWg==.wP/uwP/uwP/uwP/u"
+	)
+
+	got, err := c.DecryptData(want)
+	if err != nil {
+		t.Fatalf("DecryptData() error = %v", err)
+	}
+	if got != plain {
+		t.Fatalf("DecryptData() = %q, want %q", got, plain)
+	}
+
+	enc, err := c.encrypt(plain, []byte{0xc0, 0xff, 0xee, 0xc0, 0xff, 0xee, 0xc0, 0xff, 0xee, 0xc0, 0xff, 0xee})
+	if err != nil {
+		t.Fatalf("encrypt() error = %v", err)
+	}
+	if enc != want {
+		t.Fatalf("encrypt() = %q, want %q", enc, want)
+	}
+}
+
+func Test_encrypter_DecryptData(t *testing.T) {
+	c := &encrypter{key: "mysecretkey1234567890"}
+
+	encrypted, err := c.EncryptData("4111111111111111")
+	if err != nil {
+		t.Fatalf("EncryptData() error = %v", err)
+	}
+
+	plain, err := c.DecryptData(encrypted)
+	if err != nil || plain != "4111111111111111" {
+		t.Fatalf("DecryptData() = %q, %v", plain, err)
+	}
+
+	parts := strings.Split(encrypted, ".")
+	tests := []struct {
+		name string
+		key  string
+		data string
+	}{
+		{name: "wrong key", key: "otherkey", data: encrypted},
+		{name: "tampered tag", key: c.key, data: parts[0] + "." + parts[0] + "." + parts[2]},
+		{name: "missing part", key: c.key, data: parts[0] + "." + parts[1]},
+		{name: "bad base64", key: c.key, data: "!." + parts[1] + "." + parts[2]},
+		{name: "empty", key: c.key, data: ""},
+		{name: "empty key", key: "", data: encrypted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (&encrypter{key: tt.key}).DecryptData(tt.data); err == nil {
+				t.Fatal("DecryptData() error = nil, want an error")
+			}
+		})
+	}
+}
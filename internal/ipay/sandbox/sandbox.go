@@ -119,6 +119,8 @@ func (s *SandboxSimulator) SimulatePayment(cardNumber string, amount float64) (P
 					return PaymentSuccess, nil
 				}
 				return PaymentFailure, errors.New("payment amount exceeds limit for success")
+			case PreAuthorizationPossible, PreAuthorizationRegardlessOfAmount:
+				return PaymentPreAuthorized, nil
 			case FailureRegardlessOfAmount, FailureRandomErrorA2CPay:
 				return PaymentFailure, errors.New("simulated failure")
 			case FailureInsufficientBalanceA2CPay:
@@ -30,6 +30,7 @@ import (
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/sha3"
@@ -82,6 +83,19 @@ func NewSigner(_ string) Signer {
 	return &signer{}
 }
 
+// VerifySign reports whether sign is the HMAC-SHA512 of salt under key, i.e. the value Sign
+// would produce for that salt. The comparison is done in constant time.
+func VerifySign(salt string, sign string, key string) bool {
+	if salt == "" || sign == "" || key == "" {
+		return false
+	}
+
+	expected := hashHmacSha512(salt, key)
+	got := strings.ToLower(strings.TrimSpace(sign))
+
+	return hmac.Equal([]byte(expected), []byte(got))
+}
+
 func hashHmacSha512(data string, key string) string {
 	mac := hmac.New(sha512.New, []byte(key))
 	mac.Write([]byte(data))
@@ -25,6 +25,7 @@
 package ipay
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -69,3 +70,20 @@ func Test_signer_Sign(t *testing.T) {
 		t.Errorf("Sign() returned an empty string, expected a non-empty signature")
 	}
 }
+
+func TestVerifySign(t *testing.T) {
+	const sign = "ff06ab36757777815c008d32c8e14a705b4e7bf310351a06a23b612dc4c7433e7757d20525a5593b71020ea2ee162d2311b247e9855862b270122419652c0c92"
+
+	if !VerifySign("hello", sign, "key") {
+		t.Errorf("VerifySign() = false for a valid signature")
+	}
+	if !VerifySign("hello", strings.ToUpper(sign), "key") {
+		t.Errorf("VerifySign() = false for an upper-case signature")
+	}
+	if VerifySign("hello", sign, "other-key") {
+		t.Errorf("VerifySign() = true for a wrong key")
+	}
+	if VerifySign("", sign, "key") {
+		t.Errorf("VerifySign() = true for an empty salt")
+	}
+}
@@ -30,8 +30,14 @@ func Ref[T any](value T) *T {
 	return &value
 }
 
-func FormatAmount(amount float64) string {
-	return fmt.Sprintf("%.2f", amount/100)
+// Deref returns the value p points to, or the zero value for nil.
+func Deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+
+	return *p
 }
 
 func SafeString(s *string) string {
@@ -0,0 +1,78 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipay
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/stremovskyy/go-ipay/internal/ipay"
+	"github.com/stremovskyy/go-ipay/log"
+)
+
+// CardData is raw card data sent encrypted in the cdata field.
+type CardData struct {
+	Pan      string `json:"pan"`
+	ExpMonth string `json:"exp_month"` // Two digits, 01-12.
+	ExpYear  string `json:"exp_year"`  // Two digits.
+	Cvv      string `json:"cvv,omitempty"`
+	Holder   string `json:"holder,omitempty"`
+}
+
+// EncryptCardData encrypts card with the merchant key into the value of the cdata field.
+func EncryptCardData(merchantKey string, card CardData) (string, error) {
+	return EncryptCardDataWithLogger(merchantKey, card, nil)
+}
+
+// EncryptCardDataWithLogger is EncryptCardData that writes the debug output of the cipher to
+// logger; the client passes a logger of its own sink.
+func EncryptCardDataWithLogger(merchantKey string, card CardData, logger *log.Logger) (string, error) {
+	raw, err := json.Marshal(card)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal card data: %w", err)
+	}
+
+	cdata, err := ipay.NewCipher(merchantKey, logger).EncryptData(string(raw))
+	if err != nil {
+		return "", fmt.Errorf("cannot encrypt card data: %w", err)
+	}
+
+	return cdata, nil
+}
+
+// DecryptCardData reverses EncryptCardData.
+func DecryptCardData(merchantKey string, cdata string) (*CardData, error) {
+	raw, err := ipay.NewCipher(merchantKey, nil).DecryptData(cdata)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt card data: %w", err)
+	}
+
+	var card CardData
+	if err := json.Unmarshal([]byte(raw), &card); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal card data: %w", err)
+	}
+
+	return &card, nil
+}
@@ -0,0 +1,40 @@
+package ipay
+
+import "testing"
+
+// cdataVector is {"pan":"4111111111111111","exp_month":"12","exp_year":"29","cvv":"123"}
+// encrypted with the key "mysecretkey1234567890" and the IV "ipay-test-iv".
+const cdataVector = "l+DYX2eod+4+R8rdDj6zCbY6FNTuzC+nbZUfcn3Lp7URkOwYLh92CaMFJtaazk7Em78/zNLJpWe4cmLlsIFjNwxsstUY9Do=.hJTYnx03UwiU32BJEwXy9g==.aXBheS10ZXN0LWl2"
+
+func TestDecryptCardData_KnownVector(t *testing.T) {
+	card, err := DecryptCardData("mysecretkey1234567890", cdataVector)
+	if err != nil {
+		t.Fatalf("DecryptCardData() error = %v", err)
+	}
+
+	want := CardData{Pan: "4111111111111111", ExpMonth: "12", ExpYear: "29", Cvv: "123"}
+	if *card != want {
+		t.Fatalf("DecryptCardData() = %+v, want %+v", *card, want)
+	}
+}
+
+func TestCardData_RoundTrip(t *testing.T) {
+	want := CardData{Pan: "5168755512345678", ExpMonth: "01", ExpYear: "30", Cvv: "999", Holder: "IVAN PETRENKO"}
+
+	cdata, err := EncryptCardData("merchant-key", want)
+	if err != nil {
+		t.Fatalf("EncryptCardData() error = %v", err)
+	}
+
+	got, err := DecryptCardData("merchant-key", cdata)
+	if err != nil {
+		t.Fatalf("DecryptCardData() error = %v", err)
+	}
+	if *got != want {
+		t.Fatalf("DecryptCardData() = %+v, want %+v", *got, want)
+	}
+
+	if _, err := DecryptCardData("other-key", cdata); err == nil {
+		t.Fatal("DecryptCardData() with another key: error = nil")
+	}
+}
@@ -24,6 +24,8 @@
 
 package ipay
 
+import "github.com/stremovskyy/go-ipay/currency"
+
 type Lang string
 
 const (
@@ -31,10 +33,14 @@ const (
 	LangEn Lang = "en"
 )
 
-type CurrencyKey string
+// CurrencyKey is an alias of currency.Code.
+//
+// Deprecated: use currency.Code.
+type CurrencyKey = currency.Code
 
+// Deprecated: use the constants of the currency package.
 const (
-	CurrencyUAH CurrencyKey = "UAH"
-	CurrencyUSD CurrencyKey = "USD"
-	CurrencyEUR CurrencyKey = "EUR"
+	CurrencyUAH = currency.UAH
+	CurrencyUSD = currency.USD
+	CurrencyEUR = currency.EUR
 )
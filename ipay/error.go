@@ -41,6 +41,11 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("IpayError: code %d, message: %s", e.Code, e.Message)
 }
 
+// A2CPayMessage describes the res_auth_code of a Credit (A2CPay) transfer.
+func A2CPayMessage(code int) string {
+	return getErrorMessageA2CPay(code)
+}
+
 func getErrorMessageA2CPay(code int) string {
 	errorMessages := map[int]string{
 		0:   "Successful transaction",
@@ -60,7 +60,13 @@ func (e *IpayError) Error() string {
 
 // IsTransient returns true if the error is transient and can be retried.
 func (e *IpayError) IsTransient() bool {
-	switch e.Code {
+	return IsTransientCode(e.Code)
+}
+
+// IsTransientCode reports whether an iPay error or res_auth_code code is temporary, such as an
+// unavailable issuer, so that the operation can be repeated.
+func IsTransientCode(code int) bool {
+	switch code {
 	case 907, 908, 909, 52: // TODO: more transient error codes as needed
 		return true
 	default:
@@ -68,6 +74,28 @@ func (e *IpayError) IsTransient() bool {
 	}
 }
 
+// IsNotFound reports whether iPay answered that it has no payment with the requested ext_id or
+// pmt_id. Only this answer proves that a payment was never made; any other error, including a
+// temporary one, says nothing about it.
+func (e *IpayError) IsNotFound() bool {
+	if e.IsTransient() {
+		return false
+	}
+
+	message := strings.ToLower(e.Message)
+	if !strings.Contains(message, "not found") {
+		return false
+	}
+
+	for _, subject := range []string{"payment", "transaction", "transfer", "ext_id", "pmt_id"} {
+		if strings.Contains(message, subject) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func inferErrorType(code int) string {
 	switch {
 	case code >= 600 && code <= 699:
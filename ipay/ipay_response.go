@@ -29,6 +29,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/stremovskyy/go-ipay/currency"
 	"github.com/stremovskyy/go-ipay/internal/ipay"
 	"github.com/stremovskyy/go-ipay/internal/utils"
 )
@@ -57,6 +58,8 @@ type Response struct {
 	CardMask         *string          `json:"card_mask"`
 	BankResponse     *BankResponse    `json:"bank_response"`
 	BankAcquirerName *string          `json:"bank_acquirer_name"`
+
+	raw []byte
 }
 
 func (p *Response) PrettyPrint() {
@@ -103,8 +106,8 @@ func (p *Response) PrettyPrint() {
 				fmt.Printf(" SUb Merchant MFO: %d\n", utils.SafeInt(tx.SmchMfo))
 				fmt.Printf(" SUb Merchant OKPO: %d\n", utils.SafeInt(tx.SmchOkpo))
 				fmt.Printf(" SUb Merchant account Number: %d\n", utils.SafeInt(tx.SmchRr))
-				fmt.Printf(" Invoice Amount: %s\n", utils.FormatAmount(float64(utils.SafeInt(tx.Invoice))))
-				fmt.Printf(" Amount: %s\n", utils.FormatAmount(float64(utils.SafeInt(tx.Amount))))
+				fmt.Printf(" Invoice Amount: %s\n", currency.New(int64(utils.SafeInt(tx.Invoice)), "").Major())
+				fmt.Printf(" Amount: %s\n", currency.New(int64(utils.SafeInt(tx.Amount)), "").Major())
 			}
 		}
 
@@ -116,8 +119,8 @@ func (p *Response) PrettyPrint() {
 		value string
 	}{
 		{"ID", fmt.Sprintf("%d", p.Pmt.PmtId)},
-		{"Invoice", utils.FormatAmount(float64(p.Pmt.Invoice))},
-		{"Amount", fmt.Sprintf("%s %s", utils.FormatAmount(p.Pmt.Amount), utils.SafeString(&p.Pmt.Currency))},
+		{"Invoice", p.Pmt.InvoiceMoney().String()},
+		{"Amount", formatMajor(p.Pmt.Amount, p.Pmt.Currency)},
 		{"Status", p.Pmt.Status.String()},
 		{"Date", p.Pmt.InitDate},
 		{"Card", utils.SafeString(p.Pmt.CardMask)},
@@ -211,8 +214,19 @@ func (r Response) getInt64FromInterface(value interface{}) int64 {
 	}
 
 	switch v := value.(type) {
-	case int, int64, float64:
-		return int64(v.(float64))
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return int64(f)
+		}
 	case string:
 		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
 			return i
@@ -221,6 +235,11 @@ func (r Response) getInt64FromInterface(value interface{}) int64 {
 	return 0
 }
 
+// Raw returns the JSON body the response was decoded from, or nil for responses built in code.
+func (r Response) Raw() json.RawMessage {
+	return r.raw
+}
+
 func (r Response) PmtIdInt64() int64 {
 	return r.getInt64FromInterface(r.PmtId)
 }
@@ -233,8 +252,19 @@ func (r Response) InvoiceAmountInt64() int64 {
 	return r.getInt64FromInterface(r.Invoice)
 }
 
+// formatMajor formats an amount iPay reported in major units, falling back to the raw float.
+func formatMajor(amount float64, code string) string {
+	m, err := currency.FromFloat(amount, currency.Code(code))
+	if err != nil {
+		return fmt.Sprintf("%v %s", amount, code)
+	}
+
+	return m.String()
+}
+
 type ResponseTransaction struct {
 	TrnId    *int    `json:"trn_id"`
+	SmchId   *int    `json:"smch_id"`
 	SmchRr   *int    `json:"smch_rr"`
 	SmchMfo  *int    `json:"smch_mfo"`
 	SmchOkpo *int    `json:"smch_okpo"`
@@ -266,5 +296,7 @@ func UnmarshalJSONResponse(data []byte) (*Response, error) {
 		return nil, fmt.Errorf("error unmarshalling JSON response: %w", err)
 	}
 
+	resp.Response.raw = data
+
 	return &resp.Response, nil
 }
@@ -104,6 +104,40 @@ func WithInvoiceInTransactions(amountString int, subMerchantId *int) func(*Reque
 	}
 }
 
+// WithTransactions replaces the transactions with txs, e.g. one per sub-merchant of a split
+// payment. Fields a transaction leaves empty are copied from the first transaction built by the
+// options before it, so apply it last to keep the currency, description and info they set.
+func WithTransactions(txs []RequestTransaction) func(*RequestWrapper) {
+	return func(rw *RequestWrapper) {
+		var template RequestTransaction
+		if first := rw.Request.Body.Transactions.First(); first != nil {
+			template = *first
+		}
+
+		transactions := make(RequestTransactions, len(txs))
+		for i, tx := range txs {
+			if tx.Desc == "" {
+				tx.Desc = template.Desc
+			}
+
+			if tx.Currency == "" {
+				tx.Currency = template.Currency
+			}
+
+			if template.Info != nil {
+				if tx.Info == nil {
+					tx.Info = &Info{}
+				}
+				tx.Info.MergeWith(template.Info)
+			}
+
+			transactions[i] = tx
+		}
+
+		rw.Request.Body.Transactions = transactions
+	}
+}
+
 func WithInvoiceAmount(amount int) func(*RequestWrapper) {
 	return func(rw *RequestWrapper) {
 		rw.Request.Body.Invoice = &amount
@@ -384,6 +418,13 @@ func WithTrackingToken(token *string) func(*RequestWrapper) {
 	}
 }
 
+// WithCardData sets the encrypted card data, see EncryptCardData.
+func WithCardData(cdata *string) func(*RequestWrapper) {
+	return func(rw *RequestWrapper) {
+		rw.Request.Body.Cdata = cdata
+	}
+}
+
 func WithCardPan(pan *string) func(*RequestWrapper) {
 	return func(rw *RequestWrapper) {
 		if rw.Request.Body.Card == nil {
@@ -414,6 +455,27 @@ func WithReceiver(receiver *Receiver) func(*RequestWrapper) {
 	}
 }
 
+func WithSender(sender *Sender) func(*RequestWrapper) {
+	return func(rw *RequestWrapper) {
+		rw.Request.Body.Sender = sender
+	}
+}
+
+// WithCvd sets the card holder data sent in info.cvd.
+func WithCvd(cvd *Cvd) func(*RequestWrapper) {
+	return func(rw *RequestWrapper) {
+		if cvd == nil {
+			return
+		}
+
+		if rw.Request.Body.Info == nil {
+			rw.Request.Body.Info = &Info{}
+		}
+
+		rw.Request.Body.Info.Cvd = cvd
+	}
+}
+
 func WithRecurrentToken(token *string) func(*RequestWrapper) {
 	return func(rw *RequestWrapper) {
 		rw.Request.Body.RecurrentToken = token
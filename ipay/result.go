@@ -0,0 +1,295 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipay
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+)
+
+// dateLayouts are the formats iPay uses for dates in JSON responses.
+var dateLayouts = []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02T15:04:05"}
+
+// Result holds the fields shared by all typed results, decoded from a Response. Fields iPay did
+// not send are zero, including the currency of the amounts when the response carries none.
+type Result struct {
+	PaymentID      int64
+	ExtID          string
+	Status         PaymentStatus
+	Invoice        currency.Money // Requested amount.
+	Amount         currency.Money // Charged amount, including commission.
+	CardMask       string
+	CardToken      string
+	RecurrentToken string
+	CardType       string
+	CreatedAt      time.Time
+	Bank           BankInfo
+	Transactions   []TransactionResult
+
+	// Use3DS is set when the card was checked with 3-D Secure.
+	Use3DS bool
+	// ValidTaxID is set when iPay accepted the tax ID sent with the payment.
+	ValidTaxID bool
+
+	// Raw is the JSON body the result was decoded from, kept for auditing.
+	Raw json.RawMessage
+}
+
+// BankInfo describes the acquiring bank and its answer.
+type BankInfo struct {
+	Name       string
+	AuthCode   int    // res_auth_code
+	ErrorCode  string // bnk_error_note, e.g. "42-insufficient_funds"
+	ErrorGroup int
+	RRN        string
+}
+
+// TransactionResult is a transaction of a payment. Split payments have one per sub-merchant, in
+// the order of the request.
+type TransactionResult struct {
+	ID            int64
+	SubMerchantID int // Zero unless iPay echoed smch_id.
+	Invoice       currency.Money
+	Amount        currency.Money
+	// Account, MFO, OKPO and Bank are the sub-merchant's bank details.
+	Account int
+	MFO     int
+	OKPO    int
+	Bank    string
+}
+
+// PaymentResult is the result of Payment.
+type PaymentResult struct{ Result }
+
+// HoldResult is the result of Hold.
+type HoldResult struct{ Result }
+
+// CaptureResult is the result of Capture.
+type CaptureResult struct{ Result }
+
+// RefundKind tells what a Reversal did to the payment.
+type RefundKind int
+
+const (
+	RefundKindUnknown RefundKind = iota
+	// RefundKindVoid is a reversal of an uncaptured hold; the funds are released, never charged.
+	RefundKindVoid
+	// RefundKindRefund is a reversal of a settled payment; the funds are returned to the card.
+	RefundKindRefund
+)
+
+func (k RefundKind) String() string {
+	switch k {
+	case RefundKindVoid:
+		return "void"
+	case RefundKindRefund:
+		return "refund"
+	default:
+		return "unknown"
+	}
+}
+
+// RefundResult is the result of Refund.
+type RefundResult struct {
+	Result
+	// Kind comes from the status of the payment before the refund; unknown if it could not be
+	// looked up.
+	Kind RefundKind
+	// Refunded is the amount reversed by this call; zero if iPay declined it, or if the whole
+	// balance was reversed and the payment could not be looked up.
+	Refunded currency.Money
+	// Remaining is the refundable balance left afterwards, when the payment could be looked up.
+	Remaining currency.Money
+}
+
+// CreditResult is the result of Credit.
+type CreditResult struct{ Result }
+
+// StatusResult is the result of Status.
+type StatusResult struct{ Result }
+
+// VerificationResult is the result of VerificationLink.
+type VerificationResult struct {
+	Result
+	// URL is the page where the customer verifies the card.
+	URL string
+}
+
+// TokenizationResult is the result of CreateToken and CreateToken3DS.
+type TokenizationResult struct {
+	Result
+	// URL is the page where the customer enters the card.
+	URL string
+	// Requested3DS is set for CreateToken3DS, which asks for a 3-D Secure check of the card.
+	// Whether the customer passed it is reported by Use3DS of the webhook or Status.
+	Requested3DS bool
+}
+
+// Result decodes the loosely typed fields of the response. The payment status is normalized
+// across the card and mobile endpoints, see GetPaymentStatus.
+func (r Response) Result() Result {
+	res := Result{
+		PaymentID: r.PmtIdInt64(),
+		ExtID:     utils.Deref(r.ExtId),
+		Status:    r.GetPaymentStatus(),
+		Invoice:   currency.New(r.InvoiceAmountInt64(), ""),
+		Amount:    currency.New(r.AmountInt64(), ""),
+		CardMask:  utils.Deref(r.CardMask),
+		Bank: BankInfo{
+			Name:     utils.Deref(r.BankAcquirerName),
+			AuthCode: r.ResAuthCode,
+		},
+		Raw: r.Raw(),
+	}
+
+	if r.BnkErrorNote != nil {
+		res.Bank.ErrorCode = string(*r.BnkErrorNote)
+	}
+
+	if r.BankResponse != nil {
+		res.Bank.ErrorGroup = r.BankResponse.ErrorGroup
+	}
+
+	for _, tx := range r.Transactions {
+		res.Transactions = append(res.Transactions, TransactionResult{
+			ID:            int64(utils.SafeInt(tx.TrnId)),
+			SubMerchantID: utils.SafeInt(tx.SmchId),
+			Invoice:       currency.New(int64(utils.SafeInt(tx.Invoice)), ""),
+			Amount:        currency.New(int64(utils.SafeInt(tx.Amount)), ""),
+			Account:       utils.SafeInt(tx.SmchRr),
+			MFO:           utils.SafeInt(tx.SmchMfo),
+			OKPO:          utils.SafeInt(tx.SmchOkpo),
+			Bank:          utils.Deref(tx.SmchBank),
+		})
+	}
+
+	if r.Pmt != nil {
+		res.mergePayment(r.Pmt)
+	}
+
+	return res
+}
+
+// Transaction returns the transaction of the sub-merchant, or nil if there is none.
+func (r *Result) Transaction(subMerchantID int) *TransactionResult {
+	for i := range r.Transactions {
+		if r.Transactions[i].SubMerchantID == subMerchantID {
+			return &r.Transactions[i]
+		}
+	}
+
+	return nil
+}
+
+// mergePayment fills the fields the top level of the response left empty from its pmt object.
+func (res *Result) mergePayment(p *Payment) {
+	if res.PaymentID == 0 {
+		res.PaymentID = int64(p.PmtId)
+		if res.PaymentID == 0 {
+			res.PaymentID = p.ID
+		}
+	}
+
+	if res.ExtID == "" {
+		res.ExtID = utils.Deref(p.ExtID)
+	}
+
+	if res.Invoice.IsZero() {
+		res.Invoice = p.InvoiceMoney()
+	}
+
+	if res.Amount.IsZero() {
+		if amount, err := p.AmountMoney(); err == nil {
+			res.Amount = amount
+		}
+	}
+
+	if res.CardMask == "" {
+		res.CardMask = utils.Deref(p.CardMask)
+	}
+
+	if res.Bank.Name == "" {
+		res.Bank.Name = utils.Deref(p.BankName)
+	}
+
+	if res.Bank.ErrorCode == "" {
+		if note, ok := p.BnkErrorNote.(string); ok {
+			res.Bank.ErrorCode = note
+		}
+	}
+
+	if res.Bank.ErrorGroup == 0 {
+		switch group := p.BnkErrorGroup.(type) {
+		case float64:
+			res.Bank.ErrorGroup = int(group)
+		case string:
+			res.Bank.ErrorGroup, _ = strconv.Atoi(group)
+		}
+	}
+
+	res.CardToken = utils.Deref(p.CardToken)
+	res.RecurrentToken = utils.Deref(p.RecurrentToken)
+	res.CardType = utils.Deref(p.CardType)
+	res.Use3DS = utils.Deref(p.Use3DS)
+	res.ValidTaxID = p.ValidTaxID == 1
+	res.Bank.RRN = utils.Deref(p.RRN)
+	res.CreatedAt = parseDate(p.InitDate, p.Timestamp)
+
+	if len(res.Transactions) == 0 {
+		for _, tx := range p.Transactions.Transaction {
+			res.Transactions = append(res.Transactions, TransactionResult{
+				ID:      tx.ID,
+				Invoice: currency.New(int64(tx.Invoice), ""),
+				Amount:  currency.New(int64(tx.Amount), ""),
+			})
+		}
+	}
+
+	code := p.CurrencyCode()
+	res.Invoice.Currency = code
+	res.Amount.Currency = code
+	for i := range res.Transactions {
+		res.Transactions[i].Invoice.Currency = code
+		res.Transactions[i].Amount.Currency = code
+	}
+}
+
+func parseDate(value string, timestamp int64) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	if timestamp > 0 {
+		return time.Unix(timestamp, 0).UTC()
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,146 @@
+package ipay
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+)
+
+func TestResponse_Int64Accessors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  int64
+	}{
+		{name: "int", value: 42, want: 42},
+		{name: "int64", value: int64(42), want: 42},
+		{name: "float64", value: float64(42), want: 42},
+		{name: "string", value: "42", want: 42},
+		{name: "nil", value: nil, want: 0},
+		{name: "garbage", value: "n/a", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Response{PmtId: tt.value, Invoice: tt.value, Amount: tt.value}
+			if got := r.PmtIdInt64(); got != tt.want {
+				t.Fatalf("PmtIdInt64() = %d, want %d", got, tt.want)
+			}
+			if got := r.InvoiceAmountInt64(); got != tt.want {
+				t.Fatalf("InvoiceAmountInt64() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponse_Result(t *testing.T) {
+	raw := []byte(`{"response":{"pmt_id":123,"ext_id":"order-1","status":5,"invoice":10000,"amount":10150,` +
+		`"card_mask":"444433******1111","res_auth_code":0,"bank_acquirer_name":"PrivatBank",` +
+		`"transactions":[{"trn_id":7,"invoice":10000,"amount":10150,"smch_bank":"Bank"}]}}`)
+
+	response, err := UnmarshalJSONResponse(raw)
+	if err != nil {
+		t.Fatalf("UnmarshalJSONResponse() error = %v", err)
+	}
+
+	got := response.Result()
+	if got.PaymentID != 123 || got.ExtID != "order-1" || got.Status != PaymentStatusSuccess {
+		t.Fatalf("Result() = %+v", got)
+	}
+	if got.Invoice.Amount != 10000 || got.Amount.Amount != 10150 || got.CardMask != "444433******1111" || got.Bank.Name != "PrivatBank" {
+		t.Fatalf("Result() = %+v", got)
+	}
+	if len(got.Transactions) != 1 || got.Transactions[0] != (TransactionResult{ID: 7, Invoice: currency.New(10000, ""), Amount: currency.New(10150, ""), Bank: "Bank"}) {
+		t.Fatalf("Transactions = %+v", got.Transactions)
+	}
+	if string(got.Raw) != string(raw) {
+		t.Fatalf("Raw = %s, want the original body", got.Raw)
+	}
+}
+
+func TestResponse_ResultFromPmtAndMobileStatus(t *testing.T) {
+	response := Response{
+		PmtStatus: utils.Ref("4"),
+		Pmt: &Payment{
+			PmtId:          55,
+			ExtID:          utils.Ref("order-2"),
+			Invoice:        500,
+			Amount:         5.05,
+			Currency:       "UAH",
+			InitDate:       "2024-03-01 10:20:30",
+			CardToken:      utils.Ref("card-token"),
+			RecurrentToken: utils.Ref("recurrent-token"),
+			BnkErrorNote:   "42-insufficient_funds",
+			BnkErrorGroup:  float64(2),
+			RRN:            utils.Ref("rrn-1"),
+		},
+	}
+
+	got := response.Result()
+	want := Result{
+		PaymentID:      55,
+		ExtID:          "order-2",
+		Status:         PaymentStatusFailed,
+		Invoice:        currency.New(500, currency.UAH),
+		Amount:         currency.New(505, currency.UAH),
+		CardToken:      "card-token",
+		RecurrentToken: "recurrent-token",
+		CreatedAt:      time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC),
+		Bank:           BankInfo{ErrorCode: "42-insufficient_funds", ErrorGroup: 2, RRN: "rrn-1"},
+	}
+
+	if got.PaymentID != want.PaymentID || got.ExtID != want.ExtID || got.Status != want.Status ||
+		got.Invoice != want.Invoice || got.Amount != want.Amount ||
+		got.CardToken != want.CardToken || got.RecurrentToken != want.RecurrentToken ||
+		!got.CreatedAt.Equal(want.CreatedAt) || got.Bank != want.Bank {
+		t.Fatalf("Result() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPaymentStatus_IsFinal(t *testing.T) {
+	final := map[PaymentStatus]bool{
+		PaymentStatusRegistered:          false,
+		PaymentStatusManualProcessing:    false,
+		PaymentStatusAMLRequired:         false,
+		PaymentStatusUnknown:             false,
+		PaymentStatusPreAuthorized:       true,
+		PaymentStatusSuccess:             true,
+		PaymentStatusFailed:              true,
+		PaymentStatusCanceled:            true,
+		PaymentStatusSecurityRefusal:     true,
+		PaymentStatusSuccessWithoutClaim: true,
+	}
+
+	for status, want := range final {
+		if got := status.IsFinal(); got != want {
+			t.Fatalf("%s.IsFinal() = %v, want %v", status.String(), got, want)
+		}
+	}
+}
+
+func TestIpayError_IsNotFound(t *testing.T) {
+	for body, want := range map[string]bool{
+		`{"error":"payment not found","error_code":"404"}`:    true,
+		`{"error":"Transaction not found"}`:                   true,
+		`{"error":"Public key not found","error_code":"601"}`: false,
+		`{"error":"invalid sign"}`:                            false,
+		`{"res_auth_code":907}`:                               false,
+	} {
+		var r Response
+		if err := json.Unmarshal([]byte(body), &r); err != nil {
+			t.Fatalf("decode %s: %v", body, err)
+		}
+
+		var ierr *IpayError
+		if !errors.As(r.GetError(), &ierr) {
+			t.Fatalf("GetError(%s) = %v, want an *IpayError", body, r.GetError())
+		}
+		if got := ierr.IsNotFound(); got != want {
+			t.Fatalf("IsNotFound(%s) = %v, want %v", body, got, want)
+		}
+	}
+}
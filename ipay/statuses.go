@@ -75,3 +75,19 @@ func (s *PaymentStatus) Is(paymentStatus PaymentStatus) bool {
 
 	return *s == paymentStatus
 }
+
+// IsFinal reports whether the payment has left processing: it succeeded, was declined, canceled or
+// refused, or the funds are held and wait for Capture or Refund.
+func (s *PaymentStatus) IsFinal() bool {
+	if s == nil {
+		return false
+	}
+
+	switch *s {
+	case PaymentStatusPreAuthorized, PaymentStatusFailed, PaymentStatusSuccess, PaymentStatusCanceled,
+		PaymentStatusSuccessWithoutClaim, PaymentStatusSecurityRefusal:
+		return true
+	default:
+		return false
+	}
+}
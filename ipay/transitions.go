@@ -0,0 +1,201 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipay
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrStaleStatus reports an update that precedes the current status, such as a late
+	// Registered notification for a payment that already succeeded.
+	ErrStaleStatus = errors.New("payment status update is out of order")
+	// ErrIllegalTransition reports an update no payment can go through, such as Failed to Success.
+	ErrIllegalTransition = errors.New("payment status transition is not possible")
+)
+
+// Operation is a call that changes an existing payment.
+type Operation string
+
+const (
+	// OperationCapture charges held funds.
+	OperationCapture Operation = "Capture"
+	// OperationRefund voids a hold or returns a charged amount.
+	OperationRefund Operation = "Refund"
+)
+
+// transitions lists the statuses each status can move to. Unknown is the state before anything
+// was observed, so it can become any status.
+var transitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusRegistered: {
+		PaymentStatusPreAuthorized, PaymentStatusSuccess, PaymentStatusSuccessWithoutClaim, PaymentStatusFailed,
+		PaymentStatusCanceled, PaymentStatusSecurityRefusal, PaymentStatusAMLRequired, PaymentStatusManualProcessing,
+	},
+	PaymentStatusAMLRequired: {
+		PaymentStatusPreAuthorized, PaymentStatusSuccess, PaymentStatusSuccessWithoutClaim, PaymentStatusFailed,
+		PaymentStatusCanceled, PaymentStatusSecurityRefusal, PaymentStatusManualProcessing,
+	},
+	PaymentStatusManualProcessing: {
+		PaymentStatusPreAuthorized, PaymentStatusSuccess, PaymentStatusSuccessWithoutClaim, PaymentStatusFailed,
+		PaymentStatusCanceled, PaymentStatusSecurityRefusal,
+	},
+	// A hold is captured or voided.
+	PaymentStatusPreAuthorized: {PaymentStatusSuccess, PaymentStatusCanceled},
+	// A settled payment can only be reversed.
+	PaymentStatusSuccess:             {PaymentStatusCanceled},
+	PaymentStatusSuccessWithoutClaim: {PaymentStatusSuccess, PaymentStatusCanceled},
+}
+
+var operations = map[PaymentStatus][]Operation{
+	PaymentStatusPreAuthorized:       {OperationCapture, OperationRefund},
+	PaymentStatusSuccess:             {OperationRefund},
+	PaymentStatusSuccessWithoutClaim: {OperationRefund},
+}
+
+// TransitionError is returned by CheckTransition and PaymentState.Apply for an update that
+// cannot follow the current status. It wraps ErrStaleStatus or ErrIllegalTransition.
+type TransitionError struct {
+	From PaymentStatus
+	To   PaymentStatus
+	Err  error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s -> %s: %v", e.From.String(), e.To.String(), e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// CanTransitionTo reports whether a payment in this status can move directly to next.
+func (s *PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	if s == nil || *s == PaymentStatusUnknown {
+		return next != PaymentStatusUnknown
+	}
+
+	for _, to := range transitions[*s] {
+		if to == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckTransition validates an update from this status to next. Repeating the current status is
+// not an error. An update that the payment has already gone past returns ErrStaleStatus, any
+// other impossible one ErrIllegalTransition.
+func (s *PaymentStatus) CheckTransition(next PaymentStatus) error {
+	if s == nil || *s == next || s.CanTransitionTo(next) {
+		return nil
+	}
+
+	err := ErrIllegalTransition
+	if next == PaymentStatusUnknown || next.reaches(*s) {
+		err = ErrStaleStatus
+	}
+
+	return &TransitionError{From: *s, To: next, Err: err}
+}
+
+// reaches reports whether target can follow s after any number of transitions.
+func (s PaymentStatus) reaches(target PaymentStatus) bool {
+	seen := map[PaymentStatus]bool{s: true}
+	queue := []PaymentStatus{s}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, next := range transitions[current] {
+			if next == target {
+				return true
+			}
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return false
+}
+
+// AllowedOperations lists the operations a payment in this status accepts.
+func (s *PaymentStatus) AllowedOperations() []Operation {
+	if s == nil {
+		return nil
+	}
+
+	return append([]Operation(nil), operations[*s]...)
+}
+
+// Allows reports whether a payment in this status accepts op.
+func (s *PaymentStatus) Allows(op Operation) bool {
+	if s == nil {
+		return false
+	}
+
+	for _, allowed := range operations[*s] {
+		if allowed == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PaymentState follows a single payment through the updates from webhooks and status responses,
+// which may arrive out of order. The zero value starts at PaymentStatusUnknown.
+type PaymentState struct {
+	// Status is the latest accepted status.
+	Status PaymentStatus
+	// History holds every accepted status, oldest first.
+	History []PaymentStatus
+}
+
+// Apply moves the state to next. A repeated status is accepted without changes; a stale or
+// impossible update returns a *TransitionError and leaves the state as it was.
+func (p *PaymentState) Apply(next PaymentStatus) error {
+	if next == p.Status {
+		return nil
+	}
+
+	if err := p.Status.CheckTransition(next); err != nil {
+		return err
+	}
+
+	p.Status = next
+	p.History = append(p.History, next)
+
+	return nil
+}
+
+// Allows reports whether the payment currently accepts op.
+func (p *PaymentState) Allows(op Operation) bool {
+	return p.Status.Allows(op)
+}
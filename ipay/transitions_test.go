@@ -0,0 +1,91 @@
+package ipay
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPaymentStatus_CheckTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to PaymentStatus
+		want     error
+	}{
+		{name: "first status", from: PaymentStatusUnknown, to: PaymentStatusSuccess},
+		{name: "repeated", from: PaymentStatusSuccess, to: PaymentStatusSuccess},
+		{name: "capture", from: PaymentStatusPreAuthorized, to: PaymentStatusSuccess},
+		{name: "void", from: PaymentStatusPreAuthorized, to: PaymentStatusCanceled},
+		{name: "reversal", from: PaymentStatusSuccess, to: PaymentStatusCanceled},
+		{name: "manual review", from: PaymentStatusManualProcessing, to: PaymentStatusFailed},
+		{name: "late registered", from: PaymentStatusSuccess, to: PaymentStatusRegistered, want: ErrStaleStatus},
+		{name: "late success", from: PaymentStatusCanceled, to: PaymentStatusSuccess, want: ErrStaleStatus},
+		{name: "late hold", from: PaymentStatusSuccess, to: PaymentStatusPreAuthorized, want: ErrStaleStatus},
+		{name: "unknown", from: PaymentStatusRegistered, to: PaymentStatusUnknown, want: ErrStaleStatus},
+		{name: "failed to success", from: PaymentStatusFailed, to: PaymentStatusSuccess, want: ErrIllegalTransition},
+		{name: "refused to hold", from: PaymentStatusSecurityRefusal, to: PaymentStatusPreAuthorized, want: ErrIllegalTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.from.CheckTransition(tt.to)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CheckTransition() = %v, want %v", err, tt.want)
+			}
+
+			var terr *TransitionError
+			if tt.want != nil && (!errors.As(err, &terr) || terr.From != tt.from || terr.To != tt.to) {
+				t.Fatalf("CheckTransition() = %#v, want a *TransitionError", err)
+			}
+		})
+	}
+}
+
+func TestPaymentStatus_AllowedOperations(t *testing.T) {
+	tests := []struct {
+		status PaymentStatus
+		want   []Operation
+	}{
+		{status: PaymentStatusPreAuthorized, want: []Operation{OperationCapture, OperationRefund}},
+		{status: PaymentStatusSuccess, want: []Operation{OperationRefund}},
+		{status: PaymentStatusSuccessWithoutClaim, want: []Operation{OperationRefund}},
+		{status: PaymentStatusRegistered},
+		{status: PaymentStatusCanceled},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.AllowedOperations(); !slices.Equal(got, tt.want) {
+			t.Fatalf("%s.AllowedOperations() = %v, want %v", tt.status.String(), got, tt.want)
+		}
+	}
+
+	status := PaymentStatusSuccess
+	if status.Allows(OperationCapture) || !status.Allows(OperationRefund) {
+		t.Fatal("Success must allow Refund only")
+	}
+}
+
+func TestPaymentState_Apply(t *testing.T) {
+	var state PaymentState
+
+	for _, status := range []PaymentStatus{PaymentStatusRegistered, PaymentStatusPreAuthorized, PaymentStatusPreAuthorized} {
+		if err := state.Apply(status); err != nil {
+			t.Fatalf("Apply(%s) error: %v", status.String(), err)
+		}
+	}
+	if !state.Allows(OperationCapture) {
+		t.Fatal("a hold must allow Capture")
+	}
+
+	if err := state.Apply(PaymentStatusRegistered); !errors.Is(err, ErrStaleStatus) {
+		t.Fatalf("Apply(Registered) error = %v, want %v", err, ErrStaleStatus)
+	}
+	if err := state.Apply(PaymentStatusSuccess); err != nil {
+		t.Fatalf("Apply(Success) error: %v", err)
+	}
+
+	want := []PaymentStatus{PaymentStatusRegistered, PaymentStatusPreAuthorized, PaymentStatusSuccess}
+	if state.Status != PaymentStatusSuccess || !slices.Equal(state.History, want) {
+		t.Fatalf("state = %+v, want history %v", state, want)
+	}
+}
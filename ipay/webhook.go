@@ -28,6 +28,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+
+	"github.com/stremovskyy/go-ipay/currency"
 )
 
 // Payment represents the root element of the notification with an ID.
@@ -141,6 +143,27 @@ func (p *Payment) IsValid() bool {
 	return p.ID > 0 && p.Amount >= 0 && p.Currency != "" && len(p.Transactions.Transaction) > 0
 }
 
+// CurrencyCode resolves Currency, which iPay sends either as an alphabetic or a numeric code. An
+// unrecognized value is returned as is.
+func (p *Payment) CurrencyCode() currency.Code {
+	code, err := currency.ParseCode(p.Currency)
+	if err != nil {
+		return currency.Code(p.Currency)
+	}
+
+	return code
+}
+
+// AmountMoney returns Amount, which iPay reports in major units, converted to minor units.
+func (p *Payment) AmountMoney() (currency.Money, error) {
+	return currency.FromFloat(p.Amount, p.CurrencyCode())
+}
+
+// InvoiceMoney returns the invoice amount, which is already in minor units.
+func (p *Payment) InvoiceMoney() currency.Money {
+	return currency.New(int64(p.Invoice), p.CurrencyCode())
+}
+
 // GetTransactionByID returns a transaction by its ID.
 func (p *Payment) GetTransactionByID(id int64) *Transaction {
 	for _, transaction := range p.Transactions.Transaction {
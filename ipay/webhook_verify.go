@@ -0,0 +1,258 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipay
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/internal/ipay"
+)
+
+var (
+	// ErrWebhookSignatureMissing is returned when the notification has no salt or sign.
+	ErrWebhookSignatureMissing = errors.New("webhook signature is missing")
+	// ErrWebhookSignatureInvalid is returned when the sign does not match any of the merchant keys.
+	ErrWebhookSignatureInvalid = errors.New("webhook signature is invalid")
+	// ErrWebhookStale is returned when the notification is older than the configured max age.
+	ErrWebhookStale = errors.New("webhook notification is stale")
+	// ErrWebhookTimestampMissing is returned when a max age is configured and the notification
+	// has no timestamp.
+	ErrWebhookTimestampMissing = errors.New("webhook timestamp is missing")
+	// ErrWebhookSaltReplayed is returned when the salt has already been seen by the replay cache.
+	ErrWebhookSaltReplayed = errors.New("webhook salt has already been used")
+	// ErrWebhookNoKeys is returned when the verifier has no merchant keys configured.
+	ErrWebhookNoKeys = errors.New("webhook verifier has no merchant keys")
+)
+
+// WebhookVerificationError is returned by WebhookVerifier when a notification is rejected.
+// Use errors.Is with the ErrWebhook* values to find out why.
+type WebhookVerificationError struct {
+	PaymentID int64
+	Err       error
+}
+
+func (e *WebhookVerificationError) Error() string {
+	return fmt.Sprintf("webhook verification failed for payment %d: %v", e.PaymentID, e.Err)
+}
+
+func (e *WebhookVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// ReplayCache remembers salts of already accepted notifications.
+type ReplayCache interface {
+	// Seen records salt and reports whether it had already been recorded.
+	Seen(salt string) bool
+	// Forget removes salt, so that a notification that could not be handled is accepted when
+	// iPay delivers it again.
+	Forget(salt string)
+}
+
+// WebhookVerifier checks the salt/sign pair of iPay notifications.
+// The sign is the HMAC-SHA512 of the salt keyed with the merchant key, the same scheme
+// the client uses to sign its own requests.
+type WebhookVerifier struct {
+	keys   []string
+	maxAge time.Duration
+	replay ReplayCache
+	now    func() time.Time
+}
+
+// WebhookVerifierOption configures a WebhookVerifier.
+type WebhookVerifierOption func(*WebhookVerifier)
+
+// WithWebhookMaxAge rejects notifications whose timestamp is older than maxAge, and those
+// without a timestamp.
+//
+// The timestamp is the authorization or completion date of the payment, not the time the
+// notification was sent, and the sign does not cover it. The check keeps late deliveries of old
+// notifications out; it does not stop a forged or replayed one, since the timestamp can be
+// edited without breaking the sign. Use WithWebhookReplayCache against replays, and choose a
+// maxAge longer than the time between an authorization and the last notification you expect for
+// it, such as the one of a refund.
+func WithWebhookMaxAge(maxAge time.Duration) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.maxAge = maxAge
+	}
+}
+
+// WithWebhookReplayCache rejects notifications whose salt has already been accepted.
+// iPay re-delivers a notification with the same salt when the endpoint does not acknowledge
+// it, so call Release for a notification that was verified but could not be handled.
+func WithWebhookReplayCache(cache ReplayCache) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.replay = cache
+	}
+}
+
+// WithWebhookClock overrides the clock used for the max age check.
+func WithWebhookClock(now func() time.Time) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		if now != nil {
+			v.now = now
+		}
+	}
+}
+
+// NewWebhookVerifier creates a verifier that accepts notifications signed with any of keys.
+// Pass both the current and the previous merchant key while rotating keys.
+func NewWebhookVerifier(keys []string, opts ...WebhookVerifierOption) *WebhookVerifier {
+	v := &WebhookVerifier{now: time.Now}
+
+	for _, key := range keys {
+		if key != "" {
+			v.keys = append(v.keys, key)
+		}
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(v)
+		}
+	}
+
+	return v
+}
+
+// Verify checks the signature, age and salt uniqueness of a parsed notification.
+func (v *WebhookVerifier) Verify(payment *Payment) error {
+	if payment == nil {
+		return &WebhookVerificationError{Err: ErrWebhookSignatureMissing}
+	}
+
+	fail := func(err error) error {
+		return &WebhookVerificationError{PaymentID: payment.ID, Err: err}
+	}
+
+	if len(v.keys) == 0 {
+		return fail(ErrWebhookNoKeys)
+	}
+
+	if payment.Salt == "" || payment.Sign == "" {
+		return fail(ErrWebhookSignatureMissing)
+	}
+
+	valid := false
+	for _, key := range v.keys {
+		// Check every key so the time taken does not reveal which one matched.
+		if ipay.VerifySign(payment.Salt, payment.Sign, key) {
+			valid = true
+		}
+	}
+	if !valid {
+		return fail(ErrWebhookSignatureInvalid)
+	}
+
+	if v.maxAge > 0 {
+		if payment.Timestamp <= 0 {
+			return fail(ErrWebhookTimestampMissing)
+		}
+		if v.now().Sub(time.Unix(payment.Timestamp, 0)) > v.maxAge {
+			return fail(ErrWebhookStale)
+		}
+	}
+
+	if v.replay != nil && v.replay.Seen(payment.Salt) {
+		return fail(ErrWebhookSaltReplayed)
+	}
+
+	return nil
+}
+
+// Release forgets the salt of a verified notification that could not be handled, so that its
+// re-delivery is not rejected as a replay.
+func (v *WebhookVerifier) Release(payment *Payment) {
+	if v.replay != nil && payment != nil && payment.Salt != "" {
+		v.replay.Forget(payment.Salt)
+	}
+}
+
+// ParseAndVerifyPaymentXML parses a notification body and verifies it.
+func (v *WebhookVerifier) ParseAndVerifyPaymentXML(data []byte) (*Payment, error) {
+	payment, err := ParsePaymentXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.Verify(payment); err != nil {
+		return payment, err
+	}
+
+	return payment, nil
+}
+
+// MemoryReplayCache is an in-process ReplayCache that forgets salts after ttl.
+type MemoryReplayCache struct {
+	ttl   time.Duration
+	now   func() time.Time
+	mu    sync.Mutex
+	salts map[string]time.Time
+}
+
+// NewMemoryReplayCache creates a MemoryReplayCache. A zero ttl keeps salts for 24 hours.
+func NewMemoryReplayCache(ttl time.Duration) *MemoryReplayCache {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return &MemoryReplayCache{
+		ttl:   ttl,
+		now:   time.Now,
+		salts: make(map[string]time.Time),
+	}
+}
+
+// Seen implements ReplayCache.
+func (c *MemoryReplayCache) Seen(salt string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	for s, expires := range c.salts {
+		if now.After(expires) {
+			delete(c.salts, s)
+		}
+	}
+
+	if _, ok := c.salts[salt]; ok {
+		return true
+	}
+
+	c.salts[salt] = now.Add(c.ttl)
+
+	return false
+}
+
+// Forget implements ReplayCache.
+func (c *MemoryReplayCache) Forget(salt string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.salts, salt)
+}
@@ -0,0 +1,124 @@
+package ipay
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// HMAC-SHA512("hello", "key")
+const testWebhookSign = "ff06ab36757777815c008d32c8e14a705b4e7bf310351a06a23b612dc4c7433e7757d20525a5593b71020ea2ee162d2311b247e9855862b270122419652c0c92"
+
+func TestWebhookVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		keys    []string
+		opts    []WebhookVerifierOption
+		payment *Payment
+		wantErr error
+	}{
+		{
+			name:    "valid signature",
+			keys:    []string{"key"},
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign},
+		},
+		{
+			name:    "valid signature with rotated key",
+			keys:    []string{"new-key", "key"},
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign},
+		},
+		{
+			name:    "wrong key",
+			keys:    []string{"other"},
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign},
+			wantErr: ErrWebhookSignatureInvalid,
+		},
+		{
+			name:    "missing sign",
+			keys:    []string{"key"},
+			payment: &Payment{ID: 1, Salt: "hello"},
+			wantErr: ErrWebhookSignatureMissing,
+		},
+		{
+			name:    "no keys",
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign},
+			wantErr: ErrWebhookNoKeys,
+		},
+		{
+			name: "stale notification",
+			keys: []string{"key"},
+			opts: []WebhookVerifierOption{
+				WithWebhookMaxAge(time.Hour),
+				WithWebhookClock(func() time.Time { return now }),
+			},
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign, Timestamp: now.Add(-2 * time.Hour).Unix()},
+			wantErr: ErrWebhookStale,
+		},
+		{
+			name: "missing timestamp",
+			keys: []string{"key"},
+			opts: []WebhookVerifierOption{
+				WithWebhookMaxAge(time.Hour),
+				WithWebhookClock(func() time.Time { return now }),
+			},
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign},
+			wantErr: ErrWebhookTimestampMissing,
+		},
+		{
+			name: "fresh notification",
+			keys: []string{"key"},
+			opts: []WebhookVerifierOption{
+				WithWebhookMaxAge(time.Hour),
+				WithWebhookClock(func() time.Time { return now }),
+			},
+			payment: &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign, Timestamp: now.Add(-time.Minute).Unix()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewWebhookVerifier(tt.keys, tt.opts...).Verify(tt.payment)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Verify() error = %v, want nil", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var verr *WebhookVerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Verify() error type = %T, want *WebhookVerificationError", err)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifier_ReplayCache(t *testing.T) {
+	v := NewWebhookVerifier([]string{"key"}, WithWebhookReplayCache(NewMemoryReplayCache(time.Minute)))
+	payment := &Payment{ID: 1, Salt: "hello", Sign: testWebhookSign}
+
+	if err := v.Verify(payment); err != nil {
+		t.Fatalf("first Verify() error = %v", err)
+	}
+	if err := v.Verify(payment); !errors.Is(err, ErrWebhookSaltReplayed) {
+		t.Fatalf("second Verify() error = %v, want %v", err, ErrWebhookSaltReplayed)
+	}
+}
+
+func TestWebhookVerifier_ParseAndVerifyPaymentXML(t *testing.T) {
+	body := []byte(`<payment id="42"><status>5</status><salt>hello</salt><sign>` + testWebhookSign + `</sign></payment>`)
+
+	payment, err := NewWebhookVerifier([]string{"key"}).ParseAndVerifyPaymentXML(body)
+	if err != nil {
+		t.Fatalf("ParseAndVerifyPaymentXML() error = %v", err)
+	}
+	if payment.ID != 42 || payment.Status != PaymentStatusSuccess {
+		t.Fatalf("payment = %+v, want ID 42 with status Success", payment)
+	}
+}
@@ -105,6 +105,13 @@ func (p *XmlPayment) AddCardToken(token *string) {
 	}
 }
 
+// AddCardData adds encrypted card data to the XmlPayment, see EncryptCardData.
+func (p *XmlPayment) AddCardData(cdata *string) {
+	p.Card = &XmlCard{
+		Cdata: cdata,
+	}
+}
+
 func (p *XmlPayment) SetAuth(auth Auth) {
 	p.Auth = auth
 }
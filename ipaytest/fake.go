@@ -0,0 +1,304 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package ipaytest provides an in-memory fake of the iPay APIs for tests that must run offline.
+//
+// The fake understands the JSON API actions, the XML /api302 flow and the Repayment API. It keeps
+// payments in memory, decides outcomes with the sandbox test-card table and, when a notify_url is
+// present, posts signed notifications the way iPay does.
+//
+//	fake := ipaytest.New(ipaytest.WithMerchantKey("key"))
+//	client := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+//
+// or, to exercise a real HTTP stack:
+//
+//	srv := fake.NewServer()
+//	defer srv.Close()
+//	client := go_ipay.NewClient(go_ipay.WithBaseURL(srv.URL))
+package ipaytest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/internal/ipay/sandbox"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// DefaultPan is charged when a card token is not known to the fake. It always succeeds.
+const DefaultPan = "3333333333333331"
+
+// Payment is a snapshot of a payment held by the fake.
+type Payment struct {
+	ID             int64
+	ExtID          string
+	Action         ipay.Action
+	Status         ipay.PaymentStatus
+	Invoice        int
+	Amount         int
+	Refunded       int
+	Currency       string
+	Description    string
+	CardToken      string
+	CardMask       string
+	RecurrentToken string
+	Preauth        bool
+	NotifyURL      string
+	BankErrorNote  string
+	ResAuthCode    int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Repayment is a snapshot of a repayment held by the fake.
+type Repayment struct {
+	GUID         string
+	ExtID        string
+	MchID        int64
+	Status       int
+	Transactions [][2]string
+	CreatedAt    time.Time
+}
+
+// Repayment statuses used by the fake.
+const (
+	RepaymentStatusCreated  = 1
+	RepaymentStatusCanceled = 3
+)
+
+// Webhook is a notification the fake posted.
+type Webhook struct {
+	URL        string
+	Body       []byte
+	StatusCode int
+	Err        error
+}
+
+// Fake is an in-memory iPay. It is both an http.RoundTripper and an http.Handler.
+type Fake struct {
+	sandbox       sandbox.Sandbox
+	merchantKey   string
+	webhookClient *http.Client
+	now           func() time.Time
+	baseURL       string
+
+	mu              sync.Mutex
+	nextID          int64
+	payments        map[int64]*Payment
+	byExtID         map[string]int64
+	cards           map[string]string
+	repayments      map[string]*Repayment
+	repaymentsByExt map[string]string
+	webhooks        []Webhook
+}
+
+// Option configures a Fake.
+type Option func(*Fake)
+
+// WithMerchantKey sets the key used to sign notifications and to decrypt card data.
+func WithMerchantKey(key string) Option {
+	return func(f *Fake) {
+		f.merchantKey = key
+	}
+}
+
+// WithWebhookClient sets the client used to deliver notifications. http.DefaultClient is used otherwise.
+func WithWebhookClient(cl *http.Client) Option {
+	return func(f *Fake) {
+		if cl != nil {
+			f.webhookClient = cl
+		}
+	}
+}
+
+// WithClock overrides the clock used for timestamps.
+func WithClock(now func() time.Time) Option {
+	return func(f *Fake) {
+		if now != nil {
+			f.now = now
+		}
+	}
+}
+
+// WithBaseURL sets the host of the payment page links the fake hands out.
+func WithBaseURL(baseURL string) Option {
+	return func(f *Fake) {
+		f.baseURL = baseURL
+	}
+}
+
+// New creates an empty Fake.
+func New(opts ...Option) *Fake {
+	f := &Fake{
+		sandbox:         sandbox.NewSandboxSimulator(),
+		webhookClient:   http.DefaultClient,
+		now:             time.Now,
+		baseURL:         "https://ipaytest.invalid",
+		nextID:          1000,
+		payments:        make(map[int64]*Payment),
+		byExtID:         make(map[string]int64),
+		cards:           make(map[string]string),
+		repayments:      make(map[string]*Repayment),
+		repaymentsByExt: make(map[string]string),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(f)
+		}
+	}
+
+	return f
+}
+
+// RoundTrip implements http.RoundTripper; every request is answered by the fake regardless of its host.
+func (f *Fake) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	f.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	resp.Request = req
+
+	return resp, nil
+}
+
+// Client returns an http.Client that talks to the fake without opening sockets.
+func (f *Fake) Client() *http.Client {
+	return &http.Client{Transport: f}
+}
+
+// NewServer starts an httptest.Server backed by the fake. Point the client at it with WithBaseURL(srv.URL).
+func (f *Fake) NewServer() *httptest.Server {
+	srv := httptest.NewServer(f)
+
+	f.mu.Lock()
+	f.baseURL = srv.URL
+	f.mu.Unlock()
+
+	return srv
+}
+
+// RegisterCard maps a card or recurrent token to a sandbox PAN, so payments with the token get that card's outcome.
+func (f *Fake) RegisterCard(token, pan string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cards[token] = pan
+}
+
+// Payment returns the payment with the given iPay ID.
+func (f *Fake) Payment(id int64) (Payment, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[id]
+	if !ok {
+		return Payment{}, false
+	}
+
+	return *p, true
+}
+
+// PaymentByExtID returns the latest payment with the given ext_id.
+func (f *Fake) PaymentByExtID(extID string) (Payment, bool) {
+	f.mu.Lock()
+	id, ok := f.byExtID[extID]
+	f.mu.Unlock()
+
+	if !ok {
+		return Payment{}, false
+	}
+
+	return f.Payment(id)
+}
+
+// Repayment returns the repayment with the given GUID.
+func (f *Fake) Repayment(guid string) (Repayment, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r, ok := f.repayments[guid]
+	if !ok {
+		return Repayment{}, false
+	}
+
+	return *r, true
+}
+
+// Webhooks returns the notifications posted so far.
+func (f *Fake) Webhooks() []Webhook {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Webhook(nil), f.webhooks...)
+}
+
+// SetStatus forces a payment into status, e.g. to simulate an asynchronous bank decision, and notifies.
+func (f *Fake) SetStatus(id int64, status ipay.PaymentStatus) error {
+	f.mu.Lock()
+	p, ok := f.payments[id]
+	if !ok {
+		f.mu.Unlock()
+		return errPaymentNotFound
+	}
+
+	p.Status = status
+	p.UpdatedAt = f.now()
+	n := f.notificationLocked(p)
+	f.mu.Unlock()
+
+	f.deliver(n)
+
+	return nil
+}
+
+// Complete finishes a payment page or 3DS verification (PaymentURL, VerificationLink) as if the
+// customer entered pan. The sandbox decides the outcome; the card token is returned in the notification.
+func (f *Fake) Complete(id int64, pan string) (Payment, error) {
+	f.mu.Lock()
+	p, ok := f.payments[id]
+	if !ok {
+		f.mu.Unlock()
+		return Payment{}, errPaymentNotFound
+	}
+	if p.Status != ipay.PaymentStatusRegistered {
+		f.mu.Unlock()
+		return Payment{}, errInvalidState
+	}
+
+	f.chargeLocked(p, pan)
+	snapshot := *p
+	n := f.notificationLocked(p)
+	f.mu.Unlock()
+
+	f.deliver(n)
+
+	return snapshot, nil
+}
@@ -0,0 +1,334 @@
+package ipaytest_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/ipaytest"
+	"github.com/stremovskyy/go-ipay/webhook"
+)
+
+var merchant = &go_ipay.Merchant{
+	MerchantID:   "1",
+	MerchantKey:  "key",
+	Login:        "login",
+	RepaymentKey: "repayment-key",
+}
+
+func cardRequest(extID, token string, amount int) *go_ipay.Request {
+	return &go_ipay.Request{
+		Merchant:      merchant,
+		PaymentData:   &go_ipay.PaymentData{Amount: amount, PaymentID: utils.Ref(extID), Currency: "UAH"},
+		PaymentMethod: &go_ipay.PaymentMethod{Card: &go_ipay.Card{Token: utils.Ref(token)}},
+	}
+}
+
+func TestFake_PaymentOutcomes(t *testing.T) {
+	fake := ipaytest.New()
+	fake.RegisterCard("declined", "3333333333333349")
+	fake.RegisterCard("small-only", "3333333333333430")
+
+	tests := []struct {
+		name       string
+		token      string
+		amount     int
+		wantStatus ipay.PaymentStatus
+		wantErr    bool
+	}{
+		{name: "default card", token: "unknown", amount: 500000, wantStatus: ipay.PaymentStatusSuccess},
+		{name: "declined card", token: "declined", amount: 100, wantStatus: ipay.PaymentStatusFailed, wantErr: true},
+		{name: "under limit", token: "small-only", amount: 5000, wantStatus: ipay.PaymentStatusSuccess},
+		{name: "over limit", token: "small-only", amount: 50000, wantStatus: ipay.PaymentStatusFailed, wantErr: true},
+	}
+
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cl.Payment(cardRequest(tt.name, tt.token, tt.amount))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Payment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			p, ok := fake.PaymentByExtID(tt.name)
+			if !ok {
+				t.Fatalf("payment %q not stored", tt.name)
+			}
+			if p.Status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", p.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFake_HoldCaptureRefundOverHTTP(t *testing.T) {
+	fake := ipaytest.New()
+	srv := fake.NewServer()
+	defer srv.Close()
+
+	cl := go_ipay.NewClient(go_ipay.WithBaseURL(srv.URL))
+
+	hold, err := cl.Hold(cardRequest("order-1", "tok", 1000))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	if got := hold.GetPaymentStatus(); got != ipay.PaymentStatusPreAuthorized {
+		t.Fatalf("hold status = %d, want %d", got, ipay.PaymentStatusPreAuthorized)
+	}
+
+	pmtID := hold.PmtIdInt64()
+	capture := &go_ipay.Request{
+		Merchant:    merchant,
+		PaymentData: &go_ipay.PaymentData{IpayPaymentID: &pmtID, Amount: 600},
+	}
+	if _, err := cl.Capture(capture); err != nil {
+		t.Fatalf("Capture() error: %v", err)
+	}
+
+	status, err := cl.Status(&go_ipay.Request{Merchant: merchant, PaymentData: &go_ipay.PaymentData{PaymentID: utils.Ref("order-1")}})
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if got := status.GetPaymentStatus(); got != ipay.PaymentStatusSuccess || status.AmountInt64() != 600 {
+		t.Fatalf("status = %d amount = %d, want %d and 600", got, status.AmountInt64(), ipay.PaymentStatusSuccess)
+	}
+
+	if _, err := cl.Refund(&go_ipay.Request{Merchant: merchant, PaymentData: &go_ipay.PaymentData{IpayPaymentID: &pmtID}}); err != nil {
+		t.Fatalf("Refund() error: %v", err)
+	}
+	if p, _ := fake.Payment(pmtID); p.Status != ipay.PaymentStatusCanceled || p.Refunded != 600 {
+		t.Fatalf("after refund status = %d refunded = %d, want %d and 600", p.Status, p.Refunded, ipay.PaymentStatusCanceled)
+	}
+}
+
+func TestFake_PartialRefunds(t *testing.T) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	payment, err := cl.Payment(cardRequest("order-refund", "tok", 1000))
+	if err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+	pmtID := payment.PmtIdInt64()
+
+	refunded := 0
+	refund := func(amount int) (*ipay.RefundResult, error) {
+		result, err := cl.RefundResult(&go_ipay.Request{
+			Merchant:    merchant,
+			PaymentData: &go_ipay.PaymentData{IpayPaymentID: &pmtID, Amount: amount},
+		}, go_ipay.RefundedBefore(refunded))
+		if err == nil {
+			refunded += int(result.Refunded.Amount)
+		}
+
+		return result, err
+	}
+
+	first, err := refund(300)
+	if err != nil {
+		t.Fatalf("RefundResult(300) error: %v", err)
+	}
+	if first.Kind != ipay.RefundKindRefund || first.Refunded.Amount != 300 || first.Remaining.Amount != 700 {
+		t.Fatalf("RefundResult(300) = %v refunded %v remaining %v", first.Kind, first.Refunded, first.Remaining)
+	}
+
+	if _, err := refund(800); !errors.Is(err, go_ipay.ErrRefundExceedsBalance) {
+		t.Fatalf("RefundResult(800) error = %v, want %v", err, go_ipay.ErrRefundExceedsBalance)
+	}
+
+	rest, err := refund(0)
+	if err != nil {
+		t.Fatalf("RefundResult(0) error: %v", err)
+	}
+	if rest.Refunded.Amount != 700 || !rest.Remaining.IsZero() || rest.Status != ipay.PaymentStatusCanceled {
+		t.Fatalf("RefundResult(0) refunded %v remaining %v status %d", rest.Refunded, rest.Remaining, rest.Status)
+	}
+
+	if _, err := refund(100); !errors.Is(err, go_ipay.ErrNotRefundable) {
+		t.Fatalf("RefundResult() after full refund error = %v, want %v", err, go_ipay.ErrNotRefundable)
+	}
+}
+
+func TestFake_RefundVoidsHold(t *testing.T) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	hold, err := cl.Hold(cardRequest("order-void", "tok", 1000))
+	if err != nil {
+		t.Fatalf("Hold() error: %v", err)
+	}
+	pmtID := hold.PmtIdInt64()
+
+	void, err := cl.RefundResult(&go_ipay.Request{Merchant: merchant, PaymentData: &go_ipay.PaymentData{IpayPaymentID: &pmtID}})
+	if err != nil {
+		t.Fatalf("RefundResult() error: %v", err)
+	}
+	if void.Kind != ipay.RefundKindVoid || void.Refunded.Amount != 1000 {
+		t.Fatalf("RefundResult() = %v refunded %v, want a void of 1000", void.Kind, void.Refunded)
+	}
+}
+
+func TestFake_CreditAndStatus(t *testing.T) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	credit := &go_ipay.Request{
+		Merchant:      merchant,
+		PaymentData:   &go_ipay.PaymentData{Amount: 2500, PaymentID: utils.Ref("payout-1")},
+		PaymentMethod: &go_ipay.PaymentMethod{Card: &go_ipay.Card{Pan: utils.Ref("3333333333332705")}},
+	}
+	if _, err := cl.Credit(credit); err != nil {
+		t.Fatalf("Credit() error: %v", err)
+	}
+
+	status, err := cl.A2CPaymentStatus(&go_ipay.Request{Merchant: merchant, PaymentData: &go_ipay.PaymentData{PaymentID: utils.Ref("payout-1")}})
+	if err != nil {
+		t.Fatalf("A2CPaymentStatus() error: %v", err)
+	}
+	if got := status.GetPaymentStatus(); got != ipay.PaymentStatusSuccess {
+		t.Fatalf("status = %d, want %d", got, ipay.PaymentStatusSuccess)
+	}
+}
+
+func TestFake_PaymentURLAndVerification(t *testing.T) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	page, err := cl.PaymentURL(&go_ipay.Request{
+		Merchant:    merchant,
+		PaymentData: &go_ipay.PaymentData{Amount: 100, Currency: "UAH", Description: "order"},
+	})
+	if err != nil {
+		t.Fatalf("PaymentURL() error: %v", err)
+	}
+	if page.URL == "" || page.Status != int(ipay.PaymentStatusRegistered) {
+		t.Fatalf("page = %+v, want registered payment with URL", page)
+	}
+
+	link, err := cl.VerificationLink(&go_ipay.Request{
+		Merchant:    merchant,
+		PaymentData: &go_ipay.PaymentData{PaymentID: utils.Ref("verify-1")},
+	})
+	if err != nil {
+		t.Fatalf("VerificationLink() error: %v", err)
+	}
+	if link.String() == "" {
+		t.Fatalf("empty verification link")
+	}
+
+	p, _ := fake.PaymentByExtID("verify-1")
+	done, err := fake.Complete(p.ID, "3333333333333331")
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+	if done.Status != ipay.PaymentStatusSuccess || done.CardToken == "" {
+		t.Fatalf("completed = %+v, want success with card token", done)
+	}
+}
+
+func TestFake_SignedWebhook(t *testing.T) {
+	var got *webhook.Event
+	handler := webhook.NewHandler(ipay.NewWebhookVerifier([]string{merchant.MerchantKey})).
+		OnAny(func(_ context.Context, e *webhook.Event) error {
+			got = e
+			return nil
+		})
+	receiver := httptest.NewServer(handler)
+	defer receiver.Close()
+
+	fake := ipaytest.New(ipaytest.WithMerchantKey(merchant.MerchantKey))
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	request := cardRequest("order-webhook", "tok", 100)
+	request.PaymentData.WebhookURL = utils.Ref(receiver.URL)
+
+	if _, err := cl.Payment(request); err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+
+	hooks := fake.Webhooks()
+	if len(hooks) != 1 || hooks[0].StatusCode != 200 {
+		t.Fatalf("webhooks = %+v, want one accepted notification", hooks)
+	}
+	if got == nil || got.Type != webhook.EventPaymentSucceeded {
+		t.Fatalf("event = %+v, want %q", got, webhook.EventPaymentSucceeded)
+	}
+}
+
+func TestFake_Repayment(t *testing.T) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	created, err := cl.CreateRepayment(&go_ipay.CreateRepaymentRequest{
+		Merchant:     merchant,
+		ExtID:        "repayment-1",
+		Transactions: []go_ipay.RepaymentTransaction{{PmtID: 1, ExtID: "a"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateRepayment() error: %v", err)
+	}
+
+	if _, err := cl.CancelRepayment(&go_ipay.CancelRepaymentRequest{Merchant: merchant, RepaymentGUID: created.RepaymentGUID}); err != nil {
+		t.Fatalf("CancelRepayment() error: %v", err)
+	}
+
+	status, err := cl.GetRepaymentStatus(&go_ipay.GetRepaymentStatusRequest{Merchant: merchant, ExtID: utils.Ref("repayment-1")})
+	if err != nil {
+		t.Fatalf("GetRepaymentStatus() error: %v", err)
+	}
+	if status.Status == nil || *status.Status != ipaytest.RepaymentStatusCanceled {
+		t.Fatalf("status = %v, want %d", status.Status, ipaytest.RepaymentStatusCanceled)
+	}
+}
+
+func TestFake_CardDataPayment(t *testing.T) {
+	fake := ipaytest.New(ipaytest.WithMerchantKey(merchant.MerchantKey))
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	request := cardRequest("cdata", "", 100)
+	request.PaymentMethod = &go_ipay.PaymentMethod{
+		CardData: &go_ipay.CardData{Pan: "3333333333333349", ExpMonth: 12, ExpYear: 2029, Cvv: "123"},
+	}
+
+	if _, err := cl.Payment(request); err == nil {
+		t.Fatal("Payment() error = nil, want a decline for the card data PAN")
+	}
+
+	p, ok := fake.PaymentByExtID("cdata")
+	if !ok {
+		t.Fatal("payment not stored")
+	}
+	if p.CardMask != "333333******3349" || p.Status != ipay.PaymentStatusFailed {
+		t.Fatalf("payment = %+v, want the declined card data PAN", p)
+	}
+}
+
+func TestFake_MobilePayment(t *testing.T) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	token := base64.StdEncoding.EncodeToString([]byte(`{"paymentMethodData":{"tokenizationData":{"token":"google-token"}}}`))
+	request := &go_ipay.Request{
+		Merchant:      &go_ipay.Merchant{MerchantID: "1", Login: "login", SystemKey: "system-key"},
+		PaymentData:   &go_ipay.PaymentData{Amount: 100, PaymentID: utils.Ref("google-1"), Currency: "UAH"},
+		PaymentMethod: &go_ipay.PaymentMethod{GoogleToken: &token},
+	}
+
+	if _, err := cl.Payment(request); err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+
+	p, ok := fake.PaymentByExtID("google-1")
+	if !ok {
+		t.Fatal("payment not stored")
+	}
+	if p.Invoice != 100 || p.Amount != 100 || p.Status != ipay.PaymentStatusSuccess {
+		t.Fatalf("payment = %+v, want a successful payment of 100", p)
+	}
+}
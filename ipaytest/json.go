@@ -0,0 +1,435 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipaytest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/stremovskyy/go-ipay/internal/ipay/sandbox"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/repayment"
+)
+
+var (
+	errPaymentNotFound = errors.New("payment not found")
+	errInvalidState    = errors.New("operation is not allowed in the current payment status")
+)
+
+type envelope struct {
+	Request struct {
+		Action string          `json:"action"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"request"`
+}
+
+// ServeHTTP implements http.Handler. Requests are routed by their content rather than their URL, so
+// the fake works with the production endpoints, CustomEnvironment paths and any proxy prefix.
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		f.serveCreateRepayment(w, r)
+		return
+	case "application/x-www-form-urlencoded":
+		f.serveXML(w, r)
+		return
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		writeJSON(w, errorResponse(fmt.Sprintf("malformed request: %v", err)))
+		return
+	}
+
+	switch repayment.Action(env.Request.Action) {
+	case repayment.ActionCancelRepayment, repayment.ActionGetRepaymentStatus, repayment.ActionGetRepaymentProcessingFile:
+		f.serveRepayment(w, repayment.Action(env.Request.Action), env.Request.Body)
+		return
+	}
+
+	var body ipay.Body
+	if len(env.Request.Body) > 0 {
+		if err := json.Unmarshal(env.Request.Body, &body); err != nil {
+			writeJSON(w, errorResponse(fmt.Sprintf("malformed body: %v", err)))
+			return
+		}
+	}
+
+	writeJSON(w, f.handleAction(ipay.Action(env.Request.Action), &body))
+}
+
+func (f *Fake) handleAction(action ipay.Action, body *ipay.Body) map[string]any {
+	f.mu.Lock()
+
+	var (
+		resp map[string]any
+		n    *notification
+	)
+
+	switch action {
+	case ipay.ActionDebiting:
+		p := f.newPaymentLocked(action, body)
+		f.chargeLocked(p, f.panLocked(body))
+		resp, n = paymentResponse(p), f.notificationLocked(p)
+	case ipay.MobilePaymentCreate:
+		p := f.newPaymentLocked(action, body)
+		f.chargeLocked(p, f.panLocked(body))
+		resp, n = mobileResponse(p), f.notificationLocked(p)
+	case ipay.ActionCompletion:
+		resp, n = f.completeLocked(body)
+	case ipay.ActionReversal:
+		resp, n = f.reverseLocked(body)
+	case ipay.ActionCredit:
+		p := f.newPaymentLocked(action, body)
+		f.creditLocked(p, f.panLocked(body))
+		resp, n = creditResponse(p), f.notificationLocked(p)
+	case ipay.ActionGetPaymentStatus:
+		if p := f.lookupLocked(body); p != nil {
+			resp = paymentResponse(p)
+		} else {
+			resp = errorResponse(errPaymentNotFound.Error())
+		}
+	case ipay.ActionA2CPaymentStatus:
+		if p := f.lookupLocked(body); p != nil {
+			resp = creditResponse(p)
+		} else {
+			resp = errorResponse(errPaymentNotFound.Error())
+		}
+	case ipay.ActionCreateToken, ipay.ActionCreateToken3DS:
+		p := f.newPaymentLocked(action, body)
+		p.Status = ipay.PaymentStatusRegistered
+		resp = map[string]any{"pmt_id": p.ID, "url": fmt.Sprintf("%s/verify/%d", f.baseURL, p.ID)}
+	default:
+		resp = errorResponse(fmt.Sprintf("unsupported action %q", action))
+	}
+
+	f.mu.Unlock()
+	f.deliver(n)
+
+	return resp
+}
+
+func (f *Fake) newPaymentLocked(action ipay.Action, body *ipay.Body) *Payment {
+	f.nextID++
+	now := f.now()
+
+	p := &Payment{
+		ID:        f.nextID,
+		Action:    action,
+		Status:    ipay.PaymentStatusRegistered,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if body.ExtId != nil {
+		p.ExtID = *body.ExtId
+	}
+	if body.Invoice != nil {
+		p.Invoice = *body.Invoice
+	}
+	if body.PmtDesc != nil {
+		p.Description = *body.PmtDesc
+	}
+
+	// Mobile payments send the total both as invoice and in the transactions; only requests
+	// without a top-level invoice are summed up from their transactions.
+	infos := []*ipay.Info{body.Info}
+	for _, tx := range body.Transactions {
+		if body.Invoice == nil {
+			p.Invoice += tx.Invoice
+		}
+		if p.Currency == "" {
+			p.Currency = string(tx.Currency)
+		}
+		if p.Description == "" {
+			p.Description = tx.Desc
+		}
+		infos = append(infos, tx.Info)
+	}
+
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		if info.Preauth != nil && *info.Preauth == 1 {
+			p.Preauth = true
+		}
+		if info.NotifyUrl != nil && p.NotifyURL == "" {
+			p.NotifyURL = *info.NotifyUrl
+		}
+		if info.ExtId != nil && p.ExtID == "" {
+			p.ExtID = *info.ExtId
+		}
+	}
+
+	if p.Currency == "" {
+		p.Currency = "UAH"
+	}
+
+	f.payments[p.ID] = p
+	if p.ExtID != "" {
+		f.byExtID[p.ExtID] = p.ID
+	}
+
+	return p
+}
+
+// panLocked resolves the card of a request: a PAN is used as is, card data is decrypted with the
+// merchant key and tokens go through RegisterCard.
+func (f *Fake) panLocked(body *ipay.Body) string {
+	if body.Cdata != nil {
+		if card, err := ipay.DecryptCardData(f.merchantKey, *body.Cdata); err == nil {
+			return card.Pan
+		}
+	}
+
+	token := body.RecurrentToken
+	if body.Card != nil {
+		if body.Card.Pan != nil && *body.Card.Pan != "" {
+			return *body.Card.Pan
+		}
+		if body.Card.Token != nil {
+			token = body.Card.Token
+		}
+	}
+
+	if token != nil {
+		if pan, ok := f.cards[*token]; ok {
+			return pan
+		}
+	}
+
+	return DefaultPan
+}
+
+func (f *Fake) chargeLocked(p *Payment, pan string) {
+	outcome, err := f.sandbox.SimulatePayment(pan, float64(p.Invoice)/100)
+
+	p.CardMask = maskPan(pan)
+	p.UpdatedAt = f.now()
+
+	switch outcome {
+	case sandbox.PaymentSuccess, sandbox.PaymentPreAuthorized:
+		p.Amount = p.Invoice
+		p.Status = ipay.PaymentStatusSuccess
+		if p.Preauth {
+			p.Status = ipay.PaymentStatusPreAuthorized
+		}
+
+		if p.CardToken == "" {
+			p.CardToken = fmt.Sprintf("ipaytest-card-%d", p.ID)
+			f.cards[p.CardToken] = pan
+		}
+		if p.RecurrentToken == "" {
+			p.RecurrentToken = fmt.Sprintf("ipaytest-recurrent-%d", p.ID)
+			f.cards[p.RecurrentToken] = pan
+		}
+	default:
+		p.Status = ipay.PaymentStatusFailed
+		p.BankErrorNote = "41-eminent_decline"
+		if err != nil && err.Error() == "insufficient_balance" {
+			p.BankErrorNote = "42-insufficient_funds"
+		}
+	}
+}
+
+func (f *Fake) creditLocked(p *Payment, pan string) {
+	outcome, err := f.sandbox.SimulatePayment(pan, float64(p.Invoice)/100)
+
+	p.CardMask = maskPan(pan)
+	p.UpdatedAt = f.now()
+
+	switch outcome {
+	case sandbox.PaymentSuccess, sandbox.PaymentPreAuthorized:
+		p.Amount = p.Invoice
+		p.Status = ipay.PaymentStatusSuccess
+	case sandbox.PaymentInvalid:
+		p.Status = ipay.PaymentStatusFailed
+		p.ResAuthCode = 111
+	default:
+		p.Status = ipay.PaymentStatusFailed
+		p.ResAuthCode = 100
+		if err != nil && err.Error() == "insufficient_balance" {
+			p.ResAuthCode = 611
+		}
+	}
+}
+
+func (f *Fake) completeLocked(body *ipay.Body) (map[string]any, *notification) {
+	p := f.lookupLocked(body)
+	if p == nil {
+		return errorResponse(errPaymentNotFound.Error()), nil
+	}
+	if p.Status != ipay.PaymentStatusPreAuthorized {
+		return errorResponse(errInvalidState.Error()), nil
+	}
+
+	amount := requestedAmount(body)
+	if amount == 0 || amount > p.Invoice {
+		amount = p.Invoice
+	}
+
+	p.Amount = amount
+	p.Status = ipay.PaymentStatusSuccess
+	p.UpdatedAt = f.now()
+
+	return paymentResponse(p), f.notificationLocked(p)
+}
+
+func (f *Fake) reverseLocked(body *ipay.Body) (map[string]any, *notification) {
+	p := f.lookupLocked(body)
+	if p == nil {
+		return errorResponse(errPaymentNotFound.Error()), nil
+	}
+
+	switch p.Status {
+	case ipay.PaymentStatusPreAuthorized:
+		p.Status = ipay.PaymentStatusCanceled
+	case ipay.PaymentStatusSuccess, ipay.PaymentStatusSuccessWithoutClaim:
+		remaining := p.Amount - p.Refunded
+		amount := requestedAmount(body)
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			return errorResponse("refund amount exceeds the remaining balance"), nil
+		}
+
+		p.Refunded += amount
+		if p.Refunded == p.Amount {
+			p.Status = ipay.PaymentStatusCanceled
+		}
+	default:
+		return errorResponse(errInvalidState.Error()), nil
+	}
+
+	p.UpdatedAt = f.now()
+
+	return paymentResponse(p), f.notificationLocked(p)
+}
+
+func (f *Fake) lookupLocked(body *ipay.Body) *Payment {
+	if body.PmtId != nil && *body.PmtId != 0 {
+		return f.payments[*body.PmtId]
+	}
+
+	if body.ExtId != nil {
+		if id, ok := f.byExtID[*body.ExtId]; ok {
+			return f.payments[id]
+		}
+	}
+
+	return nil
+}
+
+func requestedAmount(body *ipay.Body) int {
+	if body.Invoice != nil {
+		return *body.Invoice
+	}
+
+	amount := 0
+	for _, tx := range body.Transactions {
+		amount += tx.Amount
+	}
+
+	return amount
+}
+
+func paymentResponse(p *Payment) map[string]any {
+	resp := map[string]any{
+		"pmt_id":    p.ID,
+		"status":    int(p.Status),
+		"invoice":   p.Invoice,
+		"amount":    p.Amount,
+		"card_mask": p.CardMask,
+	}
+
+	if p.ExtID != "" {
+		resp["ext_id"] = p.ExtID
+	}
+	if p.BankErrorNote != "" {
+		resp["bnk_error_note"] = p.BankErrorNote
+	}
+
+	return resp
+}
+
+func mobileResponse(p *Payment) map[string]any {
+	resp := paymentResponse(p)
+	delete(resp, "status")
+	resp["pmt_status"] = strconv.Itoa(int(p.Status))
+
+	return resp
+}
+
+func creditResponse(p *Payment) map[string]any {
+	resp := map[string]any{
+		"pmt_id":        p.ID,
+		"status":        int(p.Status),
+		"invoice":       p.Invoice,
+		"amount":        p.Amount,
+		"res_auth_code": p.ResAuthCode,
+	}
+
+	if p.ExtID != "" {
+		resp["ext_id"] = p.ExtID
+	}
+
+	return resp
+}
+
+func errorResponse(message string) map[string]any {
+	return map[string]any{"error": message}
+}
+
+func writeJSON(w http.ResponseWriter, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"response": response})
+}
+
+func maskPan(pan string) string {
+	if len(pan) < 10 {
+		return pan
+	}
+
+	return pan[:6] + "******" + pan[len(pan)-4:]
+}
@@ -0,0 +1,123 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipaytest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+type notification struct {
+	url  string
+	body []byte
+}
+
+// notificationLocked builds the notification for p, or returns nil when p has no notify_url.
+func (f *Fake) notificationLocked(p *Payment) *notification {
+	if p.NotifyURL == "" {
+		return nil
+	}
+
+	now := f.now()
+	salt, sign := f.sign(now.UnixNano())
+
+	msg := ipay.Payment{
+		ID:        p.ID,
+		Ident:     p.ExtID,
+		Status:    p.Status,
+		Amount:    float64(p.Amount) / 100,
+		Currency:  p.Currency,
+		Timestamp: now.Unix(),
+		Salt:      salt,
+		Sign:      sign,
+		PmtId:     int(p.ID),
+		Invoice:   p.Invoice,
+	}
+	if p.ExtID != "" {
+		msg.ExtID = &p.ExtID
+	}
+	if p.CardMask != "" {
+		msg.CardMask = &p.CardMask
+	}
+	if p.CardToken != "" {
+		msg.CardToken = &p.CardToken
+	}
+	if p.RecurrentToken != "" {
+		msg.RecurrentToken = &p.RecurrentToken
+	}
+	if p.BankErrorNote != "" {
+		msg.BnkErrorNote = p.BankErrorNote
+	}
+
+	body, err := xml.Marshal(msg)
+	if err != nil {
+		f.webhooks = append(f.webhooks, Webhook{URL: p.NotifyURL, Err: err})
+		return nil
+	}
+
+	return &notification{url: p.NotifyURL, body: body}
+}
+
+// sign produces a salt and signature the way iPay signs notifications.
+func (f *Fake) sign(seed int64) (string, string) {
+	sum := sha1.Sum([]byte(strconv.FormatInt(seed, 10)))
+	salt := hex.EncodeToString(sum[:])
+
+	mac := hmac.New(sha512.New, []byte(f.merchantKey))
+	mac.Write([]byte(salt))
+
+	return salt, hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts n as the "xml" form field. It must be called without holding f.mu, since the
+// receiver may call back into the fake.
+func (f *Fake) deliver(n *notification) {
+	if n == nil {
+		return
+	}
+
+	hook := Webhook{URL: n.url, Body: n.body}
+
+	form := url.Values{"xml": {string(n.body)}}
+	resp, err := f.webhookClient.Post(n.url, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		hook.Err = err
+	} else {
+		hook.StatusCode = resp.StatusCode
+		_ = resp.Body.Close()
+	}
+
+	f.mu.Lock()
+	f.webhooks = append(f.webhooks, hook)
+	f.mu.Unlock()
+}
@@ -0,0 +1,176 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipaytest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/stremovskyy/go-ipay/repayment"
+)
+
+// serveCreateRepayment handles the multipart CreateRepayment request: a "request" JSON field
+// and a semicolon separated "file" with pmt_id;ext_id rows.
+func (f *Fake) serveCreateRepayment(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeJSON(w, errorResponse(fmt.Sprintf("malformed multipart request: %v", err)))
+		return
+	}
+
+	var req repayment.RequestWrapper
+	if err := json.Unmarshal([]byte(r.FormValue("request")), &req); err != nil {
+		writeJSON(w, errorResponse(fmt.Sprintf("malformed request field: %v", err)))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, errorResponse("file is required"))
+		return
+	}
+	defer file.Close()
+
+	raw, err := io.ReadAll(file)
+	if err != nil {
+		writeJSON(w, errorResponse(err.Error()))
+		return
+	}
+
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		writeJSON(w, errorResponse(fmt.Sprintf("malformed file: %v", err)))
+		return
+	}
+
+	body := req.Request.Body
+	if body.ExtID == nil || *body.ExtID == "" {
+		writeJSON(w, errorResponse("ext_id is required"))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.repaymentsByExt[*body.ExtID]; exists {
+		writeJSON(w, errorResponse("repayment with this ext_id already exists"))
+		return
+	}
+
+	f.nextID++
+	rp := &Repayment{
+		GUID:      fmt.Sprintf("ipaytest-repayment-%d", f.nextID),
+		ExtID:     *body.ExtID,
+		Status:    RepaymentStatusCreated,
+		CreatedAt: f.now(),
+	}
+	if body.MchID != nil {
+		rp.MchID = *body.MchID
+	}
+	for _, row := range rows {
+		if len(row) >= 2 {
+			rp.Transactions = append(rp.Transactions, [2]string{row[0], row[1]})
+		}
+	}
+
+	f.repayments[rp.GUID] = rp
+	f.repaymentsByExt[rp.ExtID] = rp.GUID
+
+	writeJSON(w, repaymentResponse(rp))
+}
+
+func (f *Fake) serveRepayment(w http.ResponseWriter, action repayment.Action, rawBody json.RawMessage) {
+	var body repayment.Body
+	if len(rawBody) > 0 {
+		if err := json.Unmarshal(rawBody, &body); err != nil {
+			writeJSON(w, errorResponse(fmt.Sprintf("malformed body: %v", err)))
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rp := f.lookupRepaymentLocked(&body)
+	if rp == nil {
+		writeJSON(w, errorResponse("repayment not found"))
+		return
+	}
+
+	switch action {
+	case repayment.ActionCancelRepayment:
+		rp.Status = RepaymentStatusCanceled
+		writeJSON(w, repaymentResponse(rp))
+	case repayment.ActionGetRepaymentStatus:
+		writeJSON(w, repaymentResponse(rp))
+	case repayment.ActionGetRepaymentProcessingFile:
+		var buf bytes.Buffer
+		out := csv.NewWriter(&buf)
+		out.Comma = ';'
+		_ = out.Write([]string{"pmt_id", "ext_id", "status"})
+		for _, tx := range rp.Transactions {
+			_ = out.Write([]string{tx[0], tx[1], fmt.Sprint(rp.Status)})
+		}
+		out.Flush()
+
+		w.Header().Set("Content-Type", "text/csv")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+func (f *Fake) lookupRepaymentLocked(body *repayment.Body) *Repayment {
+	if body.RepaymentGUID != nil {
+		return f.repayments[*body.RepaymentGUID]
+	}
+
+	if body.ExtID != nil {
+		return f.repayments[f.repaymentsByExt[*body.ExtID]]
+	}
+
+	return nil
+}
+
+func repaymentResponse(rp *Repayment) map[string]any {
+	resp := map[string]any{
+		"repayment_guid":   rp.GUID,
+		"ext_id":           rp.ExtID,
+		"status":           rp.Status,
+		"success_payments": 0,
+		"failed_payments":  0,
+	}
+
+	if rp.MchID != 0 {
+		resp["mch_id"] = rp.MchID
+	}
+
+	return resp
+}
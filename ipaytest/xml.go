@@ -0,0 +1,89 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package ipaytest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// ActionPaymentPage marks payments created through the XML /api302 flow (PaymentURL).
+const ActionPaymentPage ipay.Action = "api302"
+
+// serveXML handles the /api302 payment page flow: the payment is registered and its page URL returned.
+// Finish it with Complete.
+func (f *Fake) serveXML(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req ipay.XmlPayment
+	if err := xml.Unmarshal([]byte(r.PostForm.Get("data")), &req); err != nil {
+		http.Error(w, fmt.Sprintf("malformed XML: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	body := &ipay.Body{}
+	for _, tx := range req.Transactions.Transaction {
+		rtx := ipay.RequestTransaction{Invoice: tx.Amount, Currency: tx.Currency, Desc: tx.Desc}
+		if tx.Info != "" {
+			var info ipay.Info
+			if err := json.Unmarshal([]byte(tx.Info), &info); err == nil {
+				rtx.Info = &info
+			}
+		}
+		body.Transactions = append(body.Transactions, rtx)
+	}
+
+	f.mu.Lock()
+	p := f.newPaymentLocked(ActionPaymentPage, body)
+	if req.Card != nil && req.Card.Token != nil {
+		p.CardToken = *req.Card.Token
+	}
+	salt, sign := f.sign(p.CreatedAt.UnixNano())
+	resp := ipay.PaymentResponse{
+		PID:    strconv.FormatInt(p.ID, 10),
+		Status: int(p.Status),
+		Salt:   salt,
+		Sign:   sign,
+		URL:    fmt.Sprintf("%s/pay/%d", f.baseURL, p.ID),
+	}
+	f.mu.Unlock()
+
+	out, err := xml.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write(append([]byte(xml.Header), out...))
+}
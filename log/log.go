@@ -25,8 +25,12 @@
 package log
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -50,42 +54,195 @@ var (
 		LevelWarning: "[warn ]",
 		LevelError:   "[error]",
 	}
+	defaultSink = &Sink{out: os.Stderr}
 )
 
+// slogLevel maps a Level to the slog level of its records.
+func (l Level) slogLevel() slog.Level {
+	switch l {
+	case LevelError:
+		return slog.LevelError
+	case LevelWarning:
+		return slog.LevelWarn
+	case LevelInfo:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// Sink is the destination shared by a group of loggers, typically all loggers of one client.
+// It owns the level, so two clients never affect each other.
+type Sink struct {
+	handler slog.Handler
+	out     io.Writer
+
+	mu       sync.RWMutex
+	level    Level
+	levelSet bool
+}
+
+// NewSink creates a Sink writing to l. A nil l writes the classic text lines to stderr and
+// follows the deprecated global level until SetLevel is called. A slog destination starts at
+// LevelDebug, leaving filtering to its handler.
+func NewSink(l *slog.Logger) *Sink {
+	if l == nil {
+		return &Sink{out: os.Stderr}
+	}
+
+	return &Sink{handler: l.Handler(), level: LevelDebug, levelSet: true}
+}
+
+// SetLogger routes the sink to l; nil restores stderr output. The level is kept unless it was
+// never set, in which case a slog destination gets LevelDebug.
+func (s *Sink) SetLogger(l *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l == nil {
+		s.handler, s.out = nil, os.Stderr
+		return
+	}
+
+	s.handler = l.Handler()
+	if !s.levelSet {
+		s.level, s.levelSet = LevelDebug, true
+	}
+}
+
+// SetLevel sets the most verbose level the sink lets through.
+func (s *Sink) SetLevel(level Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.level = level
+	s.levelSet = true
+}
+
+// Level returns the current level of the sink.
+func (s *Sink) Level() Level {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.levelSet {
+		return getLogLevel()
+	}
+
+	return s.level
+}
+
+// Logger returns a logger writing to the sink with the given prefix.
+func (s *Sink) Logger(prefix string) *Logger {
+	return &Logger{prefix: prefix, sink: s}
+}
+
+// Logger writes printf-style messages with optional structured attributes.
 type Logger struct {
 	prefix string
+	sink   *Sink
+	attrs  []any
 }
 
+// NewLogger returns a logger writing to stderr at the level set with SetLevel.
+//
+// Deprecated: such a logger ignores the logger and level of every client; take loggers from a
+// Sink, e.g. the client's LogSink.
 func NewLogger(prefix string) *Logger {
-	return &Logger{prefix: prefix}
+	return defaultSink.Logger(prefix)
 }
 
+// SetLevel sets the level of loggers created with NewLogger.
+//
+// Deprecated: levels are per client now; use the client's SetLogLevel or a Sink.
 func SetLevel(level Level) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 	globalLogLevel = level
 }
 
+func getLogLevel() Level {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	return globalLogLevel
+}
+
+// With returns a logger that adds the given key/value pairs to every message.
+func (l *Logger) With(args ...any) *Logger {
+	if l == nil {
+		return nil
+	}
+
+	attrs := make([]any, 0, len(l.attrs)+len(args))
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, args...)
+
+	return &Logger{prefix: l.prefix, sink: l.sink, attrs: attrs}
+}
+
+// Enabled reports whether a message at level would be written.
+func (l *Logger) Enabled(level Level) bool {
+	if l == nil || level == LevelNone {
+		return false
+	}
+
+	sink := l.sink
+	if sink == nil {
+		sink = defaultSink
+	}
+
+	if level > sink.Level() {
+		return false
+	}
+
+	if handler, _ := sink.destination(); handler != nil {
+		return handler.Enabled(context.Background(), level.slogLevel())
+	}
+
+	return true
+}
+
+func (s *Sink) destination() (slog.Handler, io.Writer) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.handler, s.out
+}
+
 func (l *Logger) log(level Level, format string, a ...interface{}) {
-	if level > getLogLevel() {
+	if !l.Enabled(level) {
+		return
+	}
+
+	sink := l.sink
+	if sink == nil {
+		sink = defaultSink
+	}
+
+	msg := fmt.Sprintf(format, a...)
+	handler, out := sink.destination()
+
+	if handler != nil {
+		record := slog.NewRecord(time.Now(), level.slogLevel(), msg, 0)
+		if l.prefix != "" {
+			record.AddAttrs(slog.String("logger", strings.TrimSuffix(l.prefix, ":")))
+		}
+		record.Add(l.attrs...)
+		_ = handler.Handle(context.Background(), record)
 		return
 	}
 
 	prefix := "iPay: "
-	if l != nil && l.prefix != "" {
+	if l.prefix != "" {
 		prefix = l.prefix
 	}
 
-	msg := fmt.Sprintf("%s %s %s", time.Now().Format(time.RFC3339), labels[level], prefix)
-	msg += fmt.Sprintf(format, a...)
-	fmt.Fprintln(os.Stderr, msg)
-}
-
-func getLogLevel() Level {
-	logMutex.Lock()
-	defer logMutex.Unlock()
+	line := fmt.Sprintf("%s %s %s %s", time.Now().Format(time.RFC3339), labels[level], prefix, msg)
+	for i := 0; i+1 < len(l.attrs); i += 2 {
+		line += fmt.Sprintf(" %v=%v", l.attrs[i], l.attrs[i+1])
+	}
 
-	return globalLogLevel
+	fmt.Fprintln(out, line)
 }
 
 func (l *Logger) Debug(format string, a ...interface{}) {
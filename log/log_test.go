@@ -0,0 +1,80 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSink_WritesStructuredRecords(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSink(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	sink.Logger("iPay HTTP:").With("request_id", "abc", "http_status", 200).Debug("Response status: %v", 200)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("cannot decode %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"msg":         "Response status: 200",
+		"level":       "DEBUG",
+		"logger":      "iPay HTTP",
+		"request_id":  "abc",
+		"http_status": float64(200),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Fatalf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+}
+
+func TestSink_LevelsAreIndependent(t *testing.T) {
+	var quiet, verbose bytes.Buffer
+	quietSink := NewSink(slog.New(slog.NewTextHandler(&quiet, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	verboseSink := NewSink(slog.New(slog.NewTextHandler(&verbose, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	quietSink.SetLevel(LevelError)
+
+	for _, sink := range []*Sink{quietSink, verboseSink} {
+		logger := sink.Logger("test:")
+		logger.Debug("debug line")
+		logger.Error("error line")
+	}
+
+	if strings.Contains(quiet.String(), "debug line") || !strings.Contains(quiet.String(), "error line") {
+		t.Fatalf("quiet sink wrote %q, want the error line only", quiet.String())
+	}
+	if !strings.Contains(verbose.String(), "debug line") {
+		t.Fatalf("verbose sink wrote %q, want the debug line", verbose.String())
+	}
+}
+
+func TestSink_SetLoggerKeepsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewSink(nil)
+	sink.SetLevel(LevelWarning)
+	sink.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	sink.Logger("test:").Info("info line")
+
+	if buf.Len() != 0 {
+		t.Fatalf("sink wrote %q, want nothing below LevelWarning", buf.String())
+	}
+}
+
+func TestLogger_LegacyLineCarriesAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	sink := &Sink{out: &buf, level: LevelDebug, levelSet: true}
+
+	sink.Logger("iPay HTTP:").With("request_id", "abc").Info("hello %s", "world")
+
+	line := buf.String()
+	if !strings.Contains(line, "[info ] iPay HTTP: hello world request_id=abc") {
+		t.Fatalf("line = %q", line)
+	}
+}
@@ -0,0 +1,141 @@
+package go_ipay
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/log"
+	"github.com/stremovskyy/go-ipay/redact"
+	"github.com/stremovskyy/go-ipay/webhook"
+)
+
+func TestWithLogger_AddsRequestAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5}}`)), nil
+	})
+
+	cl := NewClient(
+		WithClient(&http.Client{Transport: rt}),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	if _, err := cl.Status(paymentRequest()); err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+
+	var sawStatus bool
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("cannot decode %q: %v", line, err)
+		}
+
+		for _, key := range []string{"request_id", "endpoint", "operation", "pmt_id"} {
+			if record[key] == nil || record[key] == "" {
+				t.Fatalf("record %s has no %s", line, key)
+			}
+		}
+
+		if record["http_status"] != nil {
+			sawStatus = true
+			if record["http_status"] != float64(200) || record["duration"] == nil {
+				t.Fatalf("record %s, want http_status 200 and a duration", line)
+			}
+		}
+	}
+
+	if !sawStatus {
+		t.Fatalf("no record carries http_status: %s", buf.String())
+	}
+}
+
+func TestSetLogLevel_IsPerClient(t *testing.T) {
+	var quiet, verbose bytes.Buffer
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5}}`)), nil
+	})
+
+	quietClient := NewClient(WithClient(&http.Client{Transport: rt}), WithLogger(slog.New(slog.NewTextHandler(&quiet, nil))))
+	verboseClient := NewClient(
+		WithClient(&http.Client{Transport: rt}),
+		WithLogger(slog.New(slog.NewTextHandler(&verbose, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	quietClient.SetLogLevel(log.LevelNone)
+
+	for _, cl := range []Ipay{quietClient, verboseClient} {
+		if _, err := cl.Status(paymentRequest()); err != nil {
+			t.Fatalf("Status() error: %v", err)
+		}
+	}
+
+	if quiet.Len() != 0 {
+		t.Fatalf("quiet client logged %q", quiet.String())
+	}
+	if verbose.Len() == 0 {
+		t.Fatal("verbose client logged nothing")
+	}
+}
+
+func TestLogs_AreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5,"card_token":"card-token-123456"}}`)), nil
+	})
+
+	request := paymentRequest()
+	request.PaymentMethod.Card.Token = utils.Ref("secret-card-token")
+
+	cl := NewClient(
+		WithClient(&http.Client{Transport: rt}),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	if _, err := cl.Payment(request); err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+	if _, err := cl.Payment(request, DryRun()); err != nil {
+		t.Fatalf("Payment(DryRun) error: %v", err)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"secret-card-token", "card-token-123456"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("logs leak %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, redact.Mask) {
+		t.Fatalf("logs have no redacted sign:\n%s", out)
+	}
+}
+
+func TestLogSink_SharedByComponents(t *testing.T) {
+	var buf bytes.Buffer
+	cl := NewClient(
+		WithClient(unreachable(t)),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+
+	request := paymentRequest()
+	request.PaymentMethod = &PaymentMethod{CardData: &CardData{Pan: "4111111111111111", ExpMonth: 3, ExpYear: 2031, Cvv: "123"}}
+	if _, err := cl.Payment(request, DryRun()); err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+
+	handler := webhook.NewHandler(ipay.NewWebhookVerifier([]string{"key"}), webhook.WithLogSink(cl.LogSink()))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not json")))
+
+	for _, logger := range []string{"logger=cipher", `logger="iPay DryRun"`, `logger="iPay Webhook"`} {
+		if !strings.Contains(buf.String(), logger) {
+			t.Fatalf("client log has no record of %s: %s", logger, buf.String())
+		}
+	}
+}
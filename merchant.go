@@ -27,6 +27,7 @@ package go_ipay
 import (
 	"strconv"
 
+	"github.com/stremovskyy/go-ipay/currency"
 	"github.com/stremovskyy/go-ipay/internal/ipay"
 )
 
@@ -53,6 +54,9 @@ type Merchant struct {
 	// FailRedirect
 	FailRedirect string
 
+	// Currencies restricts the currencies the merchant may charge in; empty allows any
+	Currencies currency.Set
+
 	signer ipay.Signer
 }
 
@@ -25,8 +25,12 @@
 package go_ipay
 
 import (
+	"log/slog"
 	"net/http"
 
+	"github.com/stremovskyy/go-ipay/log"
+	"github.com/stremovskyy/go-ipay/redact"
+	"github.com/stremovskyy/go-ipay/telemetry"
 	"github.com/stremovskyy/recorder"
 )
 
@@ -43,3 +47,69 @@ func WithRecorder(r recorder.Recorder) Option {
 		c.ipayClient.SetRecorder(r)
 	}
 }
+
+// WithEnvironment points the client at the endpoints of env. Empty URLs in env keep the production value.
+func WithEnvironment(env Environment) Option {
+	return func(c *client) {
+		c.ipayClient.SetEndpoints(env.endpoints())
+	}
+}
+
+// WithBaseURL is a shortcut for WithEnvironment(CustomEnvironment(baseURL)).
+func WithBaseURL(baseURL string) Option {
+	return WithEnvironment(CustomEnvironment(baseURL))
+}
+
+// WithLogger sends the client's logs to l as structured records. Every record of an API call carries
+// request_id, operation, endpoint and, once the response arrives, http_status and duration.
+// The level defaults to LevelDebug and can be narrowed with WithLogLevel or SetLogLevel.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *client) {
+		c.ipayClient.SetLogger(l)
+	}
+}
+
+// WithLogLevel sets the log level of this client without touching other clients.
+func WithLogLevel(level log.Level) Option {
+	return func(c *client) {
+		c.ipayClient.SetLogLevel(level)
+	}
+}
+
+// WithRedactor replaces the redactor that masks card data and secrets in logs, recorded payloads
+// and dry runs. Use redact.New with redact.WithRule to adjust single fields; nil turns redaction off.
+func WithRedactor(r *redact.Redactor) Option {
+	return func(c *client) {
+		c.ipayClient.SetRedactor(r)
+	}
+}
+
+// WithTracer traces every API call: one span per operation (Payment, Hold, CreateRepayment…)
+// with a child span per HTTP attempt. See the telemetry package for the attributes.
+func WithTracer(tracer telemetry.Tracer) Option {
+	return func(c *client) {
+		c.ipayClient.SetTracer(tracer)
+	}
+}
+
+// WithMetrics reports every HTTP attempt to metrics.
+func WithMetrics(metrics telemetry.Metrics) Option {
+	return func(c *client) {
+		c.ipayClient.SetMetrics(metrics)
+	}
+}
+
+// WithAmountLimits makes client-side validation reject amounts below min or above max, in minor
+// units. A zero max means no upper limit.
+func WithAmountLimits(min, max int) Option {
+	return func(c *client) {
+		c.amountLimits = AmountLimits{Min: min, Max: max}
+	}
+}
+
+// WithoutValidation turns client-side request validation off, leaving every check to iPay.
+func WithoutValidation() Option {
+	return func(c *client) {
+		c.skipValidation = true
+	}
+}
@@ -50,4 +50,43 @@ type PaymentData struct {
 	Metadata map[string]string
 	// Recurrent uses for request recurrent token
 	GetRecurrent bool
+	// VerifyWithAmount makes VerificationLink and the CreateToken calls check the card by
+	// charging Amount (verify_type with_amount) instead of without a charge. The charge has to be
+	// reversed afterwards; the verification package does that.
+	VerifyWithAmount bool
+	// Splits divides Payment, Hold, Capture and Refund between sub-merchants, one transaction
+	// each. The invoices of the splits must add up to Amount.
+	Splits []Split
+}
+
+// Split is the part of a payment that goes to one sub-merchant.
+type Split struct {
+	// SubMerchantID is the sub-merchant receiving this part.
+	SubMerchantID int
+	// Invoice is the part in the smallest unit of the currency.
+	Invoice int
+	// Amount is the part charged to the customer including commission; 0 means Invoice.
+	Amount int
+	// Description overrides PaymentData.Description for this part.
+	Description string
+	// Metadata replaces PaymentData.Metadata in the info of this part.
+	Metadata map[string]string
+}
+
+// Money returns Amount and Currency as a currency.Money.
+func (p *PaymentData) Money() currency.Money {
+	return currency.New(int64(p.Amount), p.Currency)
+}
+
+// SetMoney sets Amount and Currency from m.
+func (p *PaymentData) SetMoney(m currency.Money) error {
+	amount, err := m.Int()
+	if err != nil {
+		return err
+	}
+
+	p.Amount = amount
+	p.Currency = m.Currency
+
+	return nil
 }
@@ -26,6 +26,8 @@ package go_ipay
 
 type PaymentMethod struct {
 	Card *Card
+	// CardData is raw card data, sent encrypted instead of a card token
+	CardData *CardData
 
 	// AppleContainer is string which generated by Apple and encoded in base64
 	AppleContainer *string
@@ -46,3 +48,16 @@ type Card struct {
 	// Pan is the primary account number of the card.
 	Pan *string
 }
+
+// CardData is raw card data, for merchants allowed to handle card numbers. It is encrypted with
+// the merchant key and sent in the cdata field, so the PAN and CVV never travel or get logged in
+// clear text.
+type CardData struct {
+	Pan      string
+	ExpMonth int
+	// ExpYear is the expiry year, either two or four digits.
+	ExpYear int
+	Cvv     string
+	// Holder is the cardholder name as printed on the card.
+	Holder string
+}
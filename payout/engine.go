@@ -0,0 +1,408 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+const (
+	// DefaultConcurrency is the number of transfers in flight at once.
+	DefaultConcurrency = 4
+	// DefaultMaxAttempts is how many times a transfer is tried, including the first time.
+	DefaultMaxAttempts = 3
+	// DefaultBackoff is the delay before the second attempt; it doubles after every attempt.
+	DefaultBackoff = time.Second
+)
+
+// Engine runs payout batches for one merchant.
+type Engine struct {
+	client      go_ipay.Ipay
+	merchant    *go_ipay.Merchant
+	concurrency int
+	limit       *limiter
+	maxAttempts int
+	backoff     time.Duration
+	onResult    func(Result)
+}
+
+// Option configures an Engine.
+type Option func(*Engine)
+
+// WithConcurrency sets how many transfers are in flight at once.
+func WithConcurrency(n int) Option {
+	return func(e *Engine) {
+		e.concurrency = n
+	}
+}
+
+// WithRateLimit allows at most n Credit calls per period, e.g. WithRateLimit(10, time.Second).
+// Retries count against the limit; status checks do not.
+func WithRateLimit(n int, per time.Duration) Option {
+	return func(e *Engine) {
+		if n > 0 && per > 0 {
+			e.limit = &limiter{interval: per / time.Duration(n)}
+		}
+	}
+}
+
+// WithRetries sets how many times a transfer is tried and the delay before the second attempt,
+// which doubles after every attempt.
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(e *Engine) {
+		e.maxAttempts = maxAttempts
+		e.backoff = backoff
+	}
+}
+
+// WithResultHandler receives every result as soon as its transfer is done, e.g. to store it.
+// Calls are not concurrent.
+func WithResultHandler(handler func(Result)) Option {
+	return func(e *Engine) {
+		e.onResult = handler
+	}
+}
+
+// NewEngine creates an Engine paying out on behalf of merchant.
+func NewEngine(client go_ipay.Ipay, merchant *go_ipay.Merchant, opts ...Option) *Engine {
+	e := &Engine{
+		client:      client,
+		merchant:    merchant,
+		concurrency: DefaultConcurrency,
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		onResult:    func(Result) {},
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.concurrency < 1 {
+		e.concurrency = 1
+	}
+	if e.maxAttempts < 1 {
+		e.maxAttempts = 1
+	}
+
+	return e
+}
+
+// Run pays the instructions and reports every one of them. When ctx ends, the transfers in flight
+// are finished, the rest are reported as skipped and ctx.Err() is returned with the report.
+func (e *Engine) Run(ctx context.Context, batchID string, instructions []Instruction) (*Report, error) {
+	in := make(chan Instruction)
+	go func() {
+		defer close(in)
+
+		for _, instruction := range instructions {
+			select {
+			case in <- instruction:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	report, err := e.Stream(ctx, batchID, in)
+
+	for i := len(report.Results); i < len(instructions); i++ {
+		report.add(Result{Index: i, ExtID: extID(batchID, i, instructions[i]), Amount: instructions[i].Amount, Outcome: OutcomeSkipped, Err: err})
+	}
+
+	return report, err
+}
+
+// Stream pays the instructions received from in until it is closed or ctx ends. Instructions are
+// indexed in the order they are received.
+func (e *Engine) Stream(ctx context.Context, batchID string, in <-chan Instruction) (*Report, error) {
+	report := &Report{BatchID: batchID, StartedAt: time.Now()}
+
+	type job struct {
+		index       int
+		instruction Instruction
+	}
+
+	var (
+		jobs = make(chan job)
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	for range e.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				res := e.pay(ctx, extID(batchID, j.index, j.instruction), j.index, j.instruction)
+
+				mu.Lock()
+				report.add(res)
+				e.onResult(res)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	index := 0
+feed:
+	for {
+		select {
+		case instruction, ok := <-in:
+			if !ok {
+				break feed
+			}
+
+			select {
+			case jobs <- job{index: index, instruction: instruction}:
+				index++
+			case <-ctx.Done():
+				break feed
+			}
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	report.sort()
+	report.FinishedAt = time.Now()
+
+	return report, ctx.Err()
+}
+
+func extID(batchID string, index int, instruction Instruction) string {
+	if instruction.ExtID != "" {
+		return instruction.ExtID
+	}
+
+	return fmt.Sprintf("%s-%d", batchID, index)
+}
+
+// pay sends one transfer until it succeeds, is declined or runs out of attempts. Every attempt
+// uses the same ext_id, so a transfer that went through unnoticed is not paid twice.
+func (e *Engine) pay(ctx context.Context, extID string, index int, instruction Instruction) Result {
+	res := Result{Index: index, ExtID: extID, Amount: instruction.Amount}
+
+	request, err := e.request(extID, instruction)
+	if err != nil {
+		res.Outcome, res.Err = OutcomeFailed, err
+		return res
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := e.limit.wait(ctx); err != nil {
+			res.Outcome, res.Err = OutcomeSkipped, err
+			if attempt > 1 {
+				res.Outcome = OutcomePending
+			}
+			return res
+		}
+
+		res.Attempts = attempt
+		credit, err := e.client.CreditResultContext(ctx, request)
+		if credit != nil {
+			res.record(credit.Result)
+		}
+		res.Err = err
+
+		outcome, retry := classify(credit, err)
+		if outcome == OutcomePending {
+			outcome, retry = e.confirm(ctx, &res)
+		}
+
+		if !retry || attempt >= e.maxAttempts {
+			res.Outcome = outcome
+			if outcome == OutcomeSucceeded {
+				res.Err = nil
+			}
+			return res
+		}
+
+		timer := time.NewTimer(e.backoff << (attempt - 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			res.Outcome = OutcomePending
+			if outcome == OutcomeFailed {
+				res.Outcome = OutcomeFailed
+			}
+			return res
+		case <-timer.C:
+		}
+	}
+}
+
+// classify decides what a Credit answer means. OutcomePending asks for a status check.
+func classify(credit *ipay.CreditResult, err error) (Outcome, bool) {
+	var (
+		ierr *ipay.IpayError
+		verr *go_ipay.ValidationError
+	)
+
+	switch {
+	case credit != nil && err == nil && credit.Status == ipay.PaymentStatusSuccess:
+		return OutcomeSucceeded, false
+	case credit != nil && ipay.IsTransientCode(credit.Bank.AuthCode),
+		errors.As(err, &ierr) && ierr.IsTransient():
+		return OutcomeFailed, true
+	case credit != nil && credit.Status.IsFinal(),
+		errors.As(err, &verr),
+		ierr != nil && credit != nil:
+		// Declined, or rejected by iPay or the validation before it was sent.
+		return OutcomeFailed, false
+	default:
+		return OutcomePending, false
+	}
+}
+
+// confirm looks up a transfer whose Credit answer was lost or not final. Only a transfer that iPay
+// answers it does not know is sent again; when the lookup fails the outcome stays unknown.
+func (e *Engine) confirm(ctx context.Context, res *Result) (Outcome, bool) {
+	response, err := e.client.A2CPaymentStatusContext(ctx, &go_ipay.Request{
+		Merchant:    e.merchant,
+		PaymentData: &go_ipay.PaymentData{PaymentID: &res.ExtID},
+	})
+
+	var ierr *ipay.IpayError
+	if errors.As(err, &ierr) && ierr.IsNotFound() {
+		// The transfer was never made.
+		return OutcomeFailed, true
+	}
+
+	var status ipay.Result
+	if response != nil {
+		status = response.Result()
+	}
+	if status.PaymentID == 0 {
+		// iPay could not be asked, or answered without the transfer.
+		if err != nil {
+			res.Err = err
+		}
+		return OutcomePending, false
+	}
+
+	res.record(status)
+	res.Err = err
+
+	switch {
+	case status.Status == ipay.PaymentStatusSuccess:
+		return OutcomeSucceeded, false
+	case status.Status.IsFinal() && ipay.IsTransientCode(status.Bank.AuthCode):
+		return OutcomeFailed, true
+	case status.Status.IsFinal():
+		return OutcomeFailed, false
+	default:
+		return OutcomePending, false
+	}
+}
+
+func (res *Result) record(r ipay.Result) {
+	if r.PaymentID != 0 {
+		res.PaymentID = r.PaymentID
+	}
+
+	res.Status = r.Status
+	res.AuthCode = r.Bank.AuthCode
+	res.Message = ""
+	if res.AuthCode != 0 {
+		res.Message = ipay.A2CPayMessage(res.AuthCode)
+	}
+}
+
+func (e *Engine) request(extID string, instruction Instruction) (*go_ipay.Request, error) {
+	data := &go_ipay.PaymentData{PaymentID: &extID, Description: instruction.Description, Metadata: instruction.Metadata}
+	if err := data.SetMoney(instruction.Amount); err != nil {
+		return nil, fmt.Errorf("payout %s: %w", extID, err)
+	}
+	if instruction.WebhookURL != "" {
+		data.WebhookURL = &instruction.WebhookURL
+	}
+
+	card := &go_ipay.Card{}
+	switch {
+	case instruction.CardToken != "":
+		card.Token = &instruction.CardToken
+	case instruction.CardPan != "":
+		card.Pan = &instruction.CardPan
+	default:
+		return nil, fmt.Errorf("payout %s: neither CardToken nor CardPan is set", extID)
+	}
+
+	return &go_ipay.Request{
+		Merchant:      e.merchant,
+		Sender:        instruction.Sender,
+		Receiver:      instruction.Receiver,
+		PaymentData:   data,
+		PaymentMethod: &go_ipay.PaymentMethod{Card: card},
+	}, nil
+}
+
+// limiter spaces calls at least interval apart. A nil limiter does not wait.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
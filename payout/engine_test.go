@@ -0,0 +1,237 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/ipaytest"
+)
+
+const declinePan = "3333333333333349"
+
+var merchant = &go_ipay.Merchant{MerchantID: "1", MerchantKey: "key"}
+
+// faulty passes requests to the fake, except for the calls replaced by fault, counted from 1.
+func faulty(fake *ipaytest.Fake, fault func(call int, req *http.Request) (*http.Response, error)) *http.Client {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+
+	return &http.Client{Transport: teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		calls++
+		call := calls
+		mu.Unlock()
+
+		if fault != nil {
+			if resp, err := fault(call, req); resp != nil || err != nil {
+				return resp, err
+			}
+		}
+
+		return fake.RoundTrip(req)
+	})}
+}
+
+func newEngine(cl *http.Client, opts ...Option) *Engine {
+	client := go_ipay.NewClient(go_ipay.WithClient(cl), go_ipay.WithRetryPolicy(go_ipay.RetryPolicy{MaxAttempts: 1}))
+
+	return NewEngine(client, merchant, append([]Option{WithRetries(3, time.Millisecond)}, opts...)...)
+}
+
+func uah(amount int64) currency.Money {
+	return currency.New(amount, "UAH")
+}
+
+func TestEngine_Run(t *testing.T) {
+	fake := ipaytest.New()
+
+	var handled []Result
+	e := newEngine(fake.Client(), WithConcurrency(2), WithRateLimit(100, time.Second), WithResultHandler(func(r Result) {
+		handled = append(handled, r)
+	}))
+
+	report, err := e.Run(t.Context(), "batch", []Instruction{
+		{Amount: uah(100), CardPan: ipaytest.DefaultPan},
+		{Amount: uah(200), CardPan: declinePan},
+		{Amount: uah(300)},
+		{ExtID: "own-id", Amount: uah(400), CardPan: ipaytest.DefaultPan},
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if report.Succeeded != 2 || report.Failed != 2 || report.Pending != 0 || report.Skipped != 0 || report.Paid != 500 {
+		t.Fatalf("Run() = %+v", report)
+	}
+	if len(handled) != 4 {
+		t.Fatalf("handled %d results, want 4", len(handled))
+	}
+	if elapsed := report.FinishedAt.Sub(report.StartedAt); elapsed < 20*time.Millisecond {
+		t.Fatalf("3 calls at 100/s took %v, want at least 20ms", elapsed)
+	}
+
+	for i, want := range []struct {
+		extID   string
+		outcome Outcome
+	}{{"batch-0", OutcomeSucceeded}, {"batch-1", OutcomeFailed}, {"batch-2", OutcomeFailed}, {"own-id", OutcomeSucceeded}} {
+		if r := report.Results[i]; r.Index != i || r.ExtID != want.extID || r.Outcome != want.outcome {
+			t.Fatalf("Results[%d] = %+v, want %s %s", i, r, want.extID, want.outcome)
+		}
+	}
+
+	if p, ok := fake.PaymentByExtID("batch-0"); !ok || p.Status != ipay.PaymentStatusSuccess || report.Results[0].PaymentID != p.ID {
+		t.Fatalf("batch-0 = %+v, result %+v", p, report.Results[0])
+	}
+
+	declined := report.Results[1]
+	if declined.AuthCode == 0 || declined.Message == "" || declined.Err == nil || declined.Attempts != 1 {
+		t.Fatalf("declined = %+v", declined)
+	}
+	if report.Declines[declined.AuthCode] != 1 {
+		t.Fatalf("Declines = %v", report.Declines)
+	}
+	if r := report.Results[2]; r.Attempts != 0 || r.Err == nil {
+		t.Fatalf("no card = %+v", r)
+	}
+
+	summary := report.Summary()
+	if !strings.HasPrefix(summary, "batch batch: 4 transfers, 2 succeeded (5.00 paid), 2 failed") || !strings.Contains(summary, declined.Message) {
+		t.Fatalf("Summary() = %q", summary)
+	}
+}
+
+func TestEngine_RetriesTransientCode(t *testing.T) {
+	fake := ipaytest.New()
+	e := newEngine(faulty(fake, func(call int, _ *http.Request) (*http.Response, error) {
+		if call == 1 {
+			return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":4,"res_auth_code":907}}`)), nil
+		}
+		return nil, nil
+	}))
+
+	report, _ := e.Run(t.Context(), "batch", []Instruction{{Amount: uah(100), CardPan: ipaytest.DefaultPan}})
+
+	if r := report.Results[0]; r.Outcome != OutcomeSucceeded || r.Attempts != 2 || r.AuthCode != 0 || r.Err != nil {
+		t.Fatalf("Results[0] = %+v", r)
+	}
+}
+
+func TestEngine_ConfirmsLostResponse(t *testing.T) {
+	fake := ipaytest.New()
+	e := newEngine(faulty(fake, func(call int, req *http.Request) (*http.Response, error) {
+		if call == 1 {
+			// The transfer is made, but its answer is lost.
+			_, _ = fake.RoundTrip(req)
+			return nil, errors.New("connection reset")
+		}
+		return nil, nil
+	}))
+
+	report, _ := e.Run(t.Context(), "batch", []Instruction{{Amount: uah(100), CardPan: ipaytest.DefaultPan}})
+
+	r := report.Results[0]
+	if r.Outcome != OutcomeSucceeded || r.Attempts != 1 || r.Status != ipay.PaymentStatusSuccess {
+		t.Fatalf("Results[0] = %+v", r)
+	}
+	if _, ok := fake.Payment(r.PaymentID + 1); ok {
+		t.Fatal("transfer was paid twice")
+	}
+}
+
+func TestEngine_ResendsUnknownTransfer(t *testing.T) {
+	fake := ipaytest.New()
+	e := newEngine(faulty(fake, func(call int, _ *http.Request) (*http.Response, error) {
+		if call == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return nil, nil
+	}))
+
+	report, _ := e.Run(t.Context(), "batch", []Instruction{{Amount: uah(100), CardPan: ipaytest.DefaultPan}})
+
+	if r := report.Results[0]; r.Outcome != OutcomeSucceeded || r.Attempts != 2 {
+		t.Fatalf("Results[0] = %+v", r)
+	}
+}
+
+func TestEngine_UnreachableIsPending(t *testing.T) {
+	fake := ipaytest.New()
+	e := newEngine(faulty(fake, func(int, *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}), WithRetries(2, time.Millisecond))
+
+	report, _ := e.Run(t.Context(), "batch", []Instruction{{Amount: uah(100), CardPan: ipaytest.DefaultPan}})
+
+	if r := report.Results[0]; r.Outcome != OutcomePending || r.Attempts != 1 || r.Err == nil {
+		t.Fatalf("Results[0] = %+v", r)
+	}
+	if report.Pending != 1 || report.Paid != 0 {
+		t.Fatalf("Run() = %+v", report)
+	}
+}
+
+func TestEngine_FailedLookupIsPending(t *testing.T) {
+	for name, status := range map[string]string{
+		"transient code": `{"response":{"res_auth_code":907}}`,
+		"auth error":     `{"response":{"error":"Public key not found","error_code":"601"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var credits int
+
+			fake := ipaytest.New()
+			e := newEngine(faulty(fake, func(call int, req *http.Request) (*http.Response, error) {
+				if call == 1 {
+					credits++
+					_, _ = fake.RoundTrip(req)
+					return nil, errors.New("connection reset")
+				}
+				if call == 2 {
+					return teststand.Response(200, "application/json", []byte(status)), nil
+				}
+
+				credits++
+				return nil, nil
+			}))
+
+			report, _ := e.Run(t.Context(), "batch", []Instruction{{Amount: uah(100), CardPan: ipaytest.DefaultPan}})
+
+			if r := report.Results[0]; r.Outcome != OutcomePending || r.Attempts != 1 || r.Err == nil {
+				t.Fatalf("Results[0] = %+v", r)
+			}
+			if credits != 1 {
+				t.Fatalf("Credit sent %d times, want 1", credits)
+			}
+		})
+	}
+}
+
+func TestEngine_CanceledRunSkips(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	fake := ipaytest.New()
+	report, err := newEngine(fake.Client()).Run(ctx, "batch", []Instruction{
+		{Amount: uah(100), CardPan: ipaytest.DefaultPan},
+		{Amount: uah(200), CardPan: ipaytest.DefaultPan},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want %v", err, context.Canceled)
+	}
+	if report.Skipped != 2 || len(report.Results) != 2 || report.Results[1].ExtID != "batch-1" {
+		t.Fatalf("Run() = %+v", report)
+	}
+	if _, ok := fake.PaymentByExtID("batch-0"); ok {
+		t.Fatal("transfer was sent after cancellation")
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package payout sends batches of Credit (A2CPay) transfers with bounded concurrency and a rate
+// limit, retries temporary failures, confirms transfers with an unknown outcome and reports the
+// result of every transfer.
+package payout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// Instruction is one transfer of a batch.
+type Instruction struct {
+	// ExtID identifies the transfer at iPay. When empty it is "<batch ID>-<index>", so running a
+	// batch again does not pay anyone twice.
+	ExtID  string
+	Amount currency.Money
+	// CardToken or CardPan is the card receiving the transfer.
+	CardToken string
+	CardPan   string
+	// Receiver and Sender are sent for financial monitoring, see go_ipay.Request.
+	Receiver    *go_ipay.Party
+	Sender      *go_ipay.Party
+	Description string
+	Metadata    map[string]string
+	WebhookURL  string
+}
+
+// Outcome is the final state of a transfer within a run.
+type Outcome string
+
+const (
+	// OutcomeSucceeded means the money was sent.
+	OutcomeSucceeded Outcome = "succeeded"
+	// OutcomeFailed means the transfer was declined or rejected and nothing was sent.
+	OutcomeFailed Outcome = "failed"
+	// OutcomePending means the outcome is not known yet: iPay is still processing the transfer, or
+	// it could not be confirmed. Check it later with A2CPaymentStatus and the ExtID.
+	OutcomePending Outcome = "pending"
+	// OutcomeSkipped means the transfer was not sent because the run was canceled.
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// Result is the outcome of one instruction.
+type Result struct {
+	// Index is the position of the instruction in the batch.
+	Index     int
+	ExtID     string
+	Amount    currency.Money
+	Outcome   Outcome
+	PaymentID int64
+	Status    ipay.PaymentStatus
+	// AuthCode is the res_auth_code of the transfer, 0 when iPay sent none.
+	AuthCode int
+	// Message describes AuthCode, see ipay.A2CPayMessage.
+	Message string
+	// Attempts is the number of Credit calls made.
+	Attempts int
+	// Err is the last error of a transfer that did not succeed.
+	Err error
+}
+
+// Report summarizes a run.
+type Report struct {
+	BatchID string
+	// Results holds one result per instruction, by Index.
+	Results   []Result
+	Succeeded int
+	Failed    int
+	Pending   int
+	Skipped   int
+	// Paid is the sum of the succeeded transfers in minor units.
+	Paid int64
+	// Declines counts the failed transfers by res_auth_code.
+	Declines   map[int]int
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+func (r *Report) add(res Result) {
+	r.Results = append(r.Results, res)
+
+	switch res.Outcome {
+	case OutcomeSucceeded:
+		r.Succeeded++
+		r.Paid += res.Amount.Amount
+	case OutcomeFailed:
+		r.Failed++
+		if res.AuthCode != 0 {
+			if r.Declines == nil {
+				r.Declines = make(map[int]int)
+			}
+			r.Declines[res.AuthCode]++
+		}
+	case OutcomePending:
+		r.Pending++
+	case OutcomeSkipped:
+		r.Skipped++
+	}
+}
+
+func (r *Report) sort() {
+	sort.Slice(r.Results, func(i, j int) bool { return r.Results[i].Index < r.Results[j].Index })
+}
+
+// Summary returns a one-line overview followed by a line per decline reason.
+func (r *Report) Summary() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "batch %s: %d transfers, %d succeeded (%s paid), %d failed, %d pending, %d skipped in %s",
+		r.BatchID, len(r.Results), r.Succeeded, currency.New(r.Paid, "").Major(), r.Failed, r.Pending, r.Skipped,
+		r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond))
+
+	codes := make([]int, 0, len(r.Declines))
+	for code := range r.Declines {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+		fmt.Fprintf(&b, "\n  %d x %d: %s", r.Declines[code], code, ipay.A2CPayMessage(code))
+	}
+
+	return b.String()
+}
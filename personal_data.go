@@ -24,6 +24,8 @@
 
 package go_ipay
 
+import "github.com/stremovskyy/go-ipay/ipay"
+
 // PersonalData represents the personal information of a user.
 type PersonalData struct {
 	// UserID is the unique identifier for the user.
@@ -39,3 +41,46 @@ type PersonalData struct {
 	// TrackingCardToken is the token of the tracking card.
 	TrackingCardToken *string
 }
+
+// Party is the sender or the receiver of a transfer, sent to iPay for financial monitoring (AML).
+type Party struct {
+	FirstName  *string
+	LastName   *string
+	MiddleName *string
+	// TaxID is the RNOKPP of the party.
+	TaxID *string
+	// Document is the series and number of a passport or ID card.
+	Document *string
+	Address  *string
+	// AccountNumber is the IBAN or account number of the party.
+	AccountNumber *string
+	// Phone is sent as the phone_number of the card holder.
+	Phone *string
+	// ToklyToken is the tokly token of the receiver's card. It is not sent for a sender.
+	ToklyToken *string
+}
+
+// party describes the user as a Party.
+func (p *PersonalData) party() *Party {
+	if p == nil {
+		return nil
+	}
+
+	return &Party{
+		FirstName:  p.FirstName,
+		LastName:   p.LastName,
+		MiddleName: p.MiddleName,
+		TaxID:      p.TaxID,
+		ToklyToken: p.TrackingCardToken,
+	}
+}
+
+func (p *Party) cvd() *ipay.Cvd {
+	return &ipay.Cvd{
+		Firstname:   p.FirstName,
+		Lastname:    p.LastName,
+		Middlename:  p.MiddleName,
+		TaxID:       p.TaxID,
+		PhoneNumber: p.Phone,
+	}
+}
@@ -0,0 +1,351 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package redact masks card data, secrets and personal data in request and response payloads
+// before they are logged, recorded or printed by a dry run.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Rule says how the value of a field is masked. A rule set on an object or element applies to
+// everything inside it, unless a nested field has a rule of its own.
+type Rule int
+
+const (
+	Keep  Rule = iota // Leaves the value as it is.
+	Full              // Replaces the value with Mask.
+	Last4             // Keeps the last four characters: ****1234.
+	PAN               // Keeps the first six and the last four digits: 444433******1111.
+)
+
+// Mask replaces values redacted with Full.
+const Mask = "[REDACTED]"
+
+// Unredactable replaces payloads that Bytes cannot parse, so that nothing it cannot see into
+// reaches a log or a recorder.
+const Unredactable = "[unredactable payload]"
+
+// DefaultRules are the field rules of a Redactor created by New. Keys are JSON keys and XML
+// element names of iPay requests, responses and notifications.
+func DefaultRules() map[string]Rule {
+	return map[string]Rule{
+		"pan":                     PAN,
+		"cdata":                   Full,
+		"apple_data":              Full,
+		"sign":                    Full,
+		"salt":                    Full,
+		"cvd":                     Full,
+		"external_cvd":            Full,
+		"aml":                     Full,
+		"receiver":                Full,
+		"sender":                  Full,
+		"token":                   Last4,
+		"card_token":              Last4,
+		"recurrent_token":         Last4,
+		"tokly_token":             Last4,
+		"receiver_tokly_token":    Last4,
+		"receiver_account_number": Last4,
+	}
+}
+
+// Option configures a Redactor.
+type Option func(*Redactor)
+
+// WithRule sets the rule of a field, overriding the default. WithRule(field, Keep) turns
+// redaction of that field off.
+func WithRule(field string, rule Rule) Option {
+	return func(r *Redactor) {
+		r.rules[strings.ToLower(field)] = rule
+	}
+}
+
+// Redactor masks fields of JSON, XML and form-encoded payloads by name.
+type Redactor struct {
+	rules map[string]Rule
+}
+
+// New creates a Redactor with DefaultRules and the given overrides.
+func New(opts ...Option) *Redactor {
+	r := &Redactor{rules: DefaultRules()}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+
+	return r
+}
+
+// Rule returns the rule of field; fields without a rule are kept.
+func (r *Redactor) Rule(field string) Rule {
+	if r == nil {
+		return Keep
+	}
+
+	return r.rules[strings.ToLower(field)]
+}
+
+// Bytes redacts a JSON, XML or form-encoded payload. Anything else, including payloads that
+// fail to parse, is replaced with Unredactable. Empty payloads are returned as they are, and so
+// is everything passed to a nil Redactor.
+func (r *Redactor) Bytes(raw []byte) []byte {
+	if r == nil {
+		return raw
+	}
+
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return raw
+	}
+
+	var (
+		out []byte
+		err error
+	)
+
+	switch trimmed[0] {
+	case '{', '[':
+		out, err = r.JSON(trimmed)
+	case '<':
+		out, err = r.XML(trimmed)
+	default:
+		if !bytes.ContainsRune(trimmed, '=') || bytes.ContainsAny(trimmed, " \t\r\n") {
+			return []byte(Unredactable)
+		}
+		out, err = r.Form(trimmed)
+	}
+
+	if err != nil {
+		return []byte(Unredactable)
+	}
+
+	return out
+}
+
+// String is Bytes for strings.
+func (r *Redactor) String(raw string) string {
+	return string(r.Bytes([]byte(raw)))
+}
+
+// JSON redacts a JSON document.
+func (r *Redactor) JSON(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(r.walk(v, Keep)); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (r *Redactor) walk(v any, rule Rule) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, field := range value {
+			fieldRule := rule
+			if own, ok := r.rules[strings.ToLower(key)]; ok {
+				fieldRule = own
+			}
+			value[key] = r.walk(field, fieldRule)
+		}
+
+		return value
+	case []any:
+		for i := range value {
+			value[i] = r.walk(value[i], rule)
+		}
+
+		return value
+	case string:
+		return apply(rule, value)
+	case json.Number:
+		if rule == Keep {
+			return value
+		}
+
+		return apply(rule, value.String())
+	case bool:
+		if rule == Keep {
+			return value
+		}
+
+		return Mask
+	default:
+		return value
+	}
+}
+
+// XML redacts an XML document. Element text is masked by the rule of the innermost element
+// that has one. Text of other elements that holds a JSON document, such as the <info> of an XML
+// API transaction, is redacted with JSON.
+func (r *Redactor) XML(raw []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+
+	rules := []Rule{Keep}
+
+	for {
+		token, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			rule := rules[len(rules)-1]
+			if own, ok := r.rules[strings.ToLower(t.Name.Local)]; ok {
+				rule = own
+			}
+			rules = append(rules, rule)
+		case xml.EndElement:
+			if len(rules) > 1 {
+				rules = rules[:len(rules)-1]
+			}
+		case xml.CharData:
+			text := bytes.TrimSpace(t)
+			if len(text) == 0 {
+				break
+			}
+			if rule := rules[len(rules)-1]; rule != Keep {
+				token = xml.CharData(apply(rule, string(t)))
+			} else if text[0] == '{' || text[0] == '[' {
+				token = xml.CharData(r.embedded(text))
+			}
+		}
+
+		if err := enc.EncodeToken(token); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// embedded redacts a JSON document found in XML text. Text that does not parse is masked.
+func (r *Redactor) embedded(text []byte) []byte {
+	out, err := r.JSON(text)
+	if err != nil {
+		return []byte(Mask)
+	}
+
+	return out
+}
+
+// Form redacts a form-encoded body. Values of fields with a rule are masked; other values that
+// hold a JSON or XML document, such as the "data" field of the XML API, are redacted as such,
+// and plain values are kept.
+func (r *Redactor) Form(raw []byte) ([]byte, error) {
+	values, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, list := range values {
+		for i, value := range list {
+			if rule := r.Rule(key); rule != Keep {
+				list[i] = apply(rule, value)
+				continue
+			}
+			if isDocument(value) {
+				list[i] = r.String(value)
+			}
+		}
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+// isDocument reports whether value looks like a JSON or XML document.
+func isDocument(value string) bool {
+	value = strings.TrimSpace(value)
+
+	return value != "" && strings.ContainsRune("{[<", rune(value[0]))
+}
+
+func apply(rule Rule, value string) string {
+	switch rule {
+	case Full:
+		return Mask
+	case Last4:
+		return last4(value)
+	case PAN:
+		return maskPAN(value)
+	default:
+		return value
+	}
+}
+
+func last4(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) <= 4 {
+		return "****"
+	}
+
+	return "****" + value[len(value)-4:]
+}
+
+// maskPAN keeps the first six and the last four digits of a card number, as PCI DSS allows.
+// Shorter values only keep the last four.
+func maskPAN(value string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+
+	if len(digits) < 13 {
+		return last4(digits)
+	}
+
+	return digits[:6] + strings.Repeat("*", len(digits)-10) + digits[len(digits)-4:]
+}
@@ -0,0 +1,147 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+func TestRedactor_Bytes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "json auth and card",
+			in:   `{"request":{"auth":{"mch_id":1,"salt":"abc","sign":"deadbeef"},"body":{"card":{"pan":"4444333322221111","token":"tok_1234567890"}}}}`,
+			want: `{"request":{"auth":{"mch_id":1,"salt":"[REDACTED]","sign":"[REDACTED]"},"body":{"card":{"pan":"444433******1111","token":"****7890"}}}}`,
+		},
+		{
+			name: "json personal data is masked leaf by leaf",
+			in:   `{"receiver":{"firstname":"Ivan","tokly_token":"abcdef12"},"invoice":100}`,
+			want: `{"invoice":100,"receiver":{"firstname":"[REDACTED]","tokly_token":"****ef12"}}`,
+		},
+		{
+			name: "xml",
+			in:   `<payment><auth><sign>deadbeef</sign></auth><card><token>tok_1234567890</token></card><lang>ua</lang></payment>`,
+			want: `<payment><auth><sign>[REDACTED]</sign></auth><card><token>****7890</token></card><lang>ua</lang></payment>`,
+		},
+		{
+			name: "form with xml data",
+			in:   `data=%3Cpayment%3E%3Csalt%3Eabc%3C%2Fsalt%3E%3C%2Fpayment%3E`,
+			want: `data=%3Cpayment%3E%3Csalt%3E%5BREDACTED%5D%3C%2Fsalt%3E%3C%2Fpayment%3E`,
+		},
+		{
+			name: "form plain values are kept",
+			in:   `amount=100&sign=deadbeef`,
+			want: `amount=100&sign=%5BREDACTED%5D`,
+		},
+		{
+			name: "plain text is unredactable",
+			in:   "bad gateway",
+			want: Unredactable,
+		},
+		{
+			name: "malformed json is unredactable",
+			in:   `{"card":{"pan":"4444333322221111"`,
+			want: Unredactable,
+		},
+		{
+			name: "malformed xml is unredactable",
+			in:   `<payment><pan>4444333322221111</card></payment>`,
+			want: Unredactable,
+		},
+		{
+			name: "empty is kept",
+			in:   "",
+			want: "",
+		},
+	}
+
+	r := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.in); got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactor_XMLInfo(t *testing.T) {
+	payment := ipay.CreateXMLPaymentCreateRequest()
+	payment.AddTransaction(100, currency.UAH, "Order 42")
+	payment.SetPersonalData(&ipay.Info{
+		OrderId: utils.Ref("order-42"),
+		Cvd: &ipay.Cvd{
+			TaxID:       utils.Ref("3184710691"),
+			Firstname:   utils.Ref("Ivan"),
+			PhoneNumber: utils.Ref("380501234567"),
+		},
+	})
+
+	raw, err := payment.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(raw), "3184710691") {
+		t.Fatalf("payload has no tax_id to redact:\n%s", raw)
+	}
+
+	got := New().String(string(raw))
+
+	for _, secret := range []string{"3184710691", "380501234567", "Ivan"} {
+		if strings.Contains(got, secret) {
+			t.Fatalf("redacted payload leaks %q:\n%s", secret, got)
+		}
+	}
+	for _, kept := range []string{"order-42", "Order 42", "<amount>100</amount>"} {
+		if !strings.Contains(got, kept) {
+			t.Fatalf("redacted payload lost %q:\n%s", kept, got)
+		}
+	}
+}
+
+func TestRedactor_XMLInfoMalformed(t *testing.T) {
+	got := New().String(`<transaction><info>{"cvd":{"tax_id":"3184710691"</info></transaction>`)
+
+	if want := `<transaction><info>[REDACTED]</info></transaction>`; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestWithRule(t *testing.T) {
+	r := New(WithRule("sign", Keep), WithRule("desc", Full))
+
+	got := r.String(`{"sign":"deadbeef","desc":"Order 42"}`)
+	if want := `{"desc":"[REDACTED]","sign":"deadbeef"}`; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestMaskPAN(t *testing.T) {
+	tests := map[string]string{
+		"4444333322221111":    "444433******1111",
+		"4444 3333 2222 1111": "444433******1111",
+		"5168755512345678901": "516875*********8901",
+		"12345":               "****2345",
+	}
+
+	for in, want := range tests {
+		if got := maskPAN(in); got != want {
+			t.Fatalf("maskPAN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+
+	if got := r.String(`{"sign":"x"}`); got != `{"sign":"x"}` {
+		t.Fatalf("String() = %q", got)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// RefundedBefore tells Refund how much of a settled payment the caller has already refunded.
+// iPay does not report it, so without this option the balance of a settled payment is its whole
+// amount.
+func RefundedBefore(refunded int) RunOption {
+	return func(o *runOptions) {
+		o.refunded = refunded
+	}
+}
+
+// SkipRefundBalanceCheck makes Refund send the reversal without rejecting it locally: amounts
+// above the balance and payments that are neither held nor settled are left for iPay to decline,
+// and a failed Status lookup does not stop the refund.
+func SkipRefundBalanceCheck() RunOption {
+	return func(o *runOptions) {
+		o.skipRefundCheck = true
+	}
+}
+
+func (o *runOptions) checksRefundBalance() bool {
+	return o == nil || !o.skipRefundCheck
+}
+
+func (o *runOptions) refundedBefore() int {
+	if o == nil {
+		return 0
+	}
+
+	return o.refunded
+}
+
+// refundBalance is what a payment has left to reverse before a refund.
+type refundBalance struct {
+	status    ipay.PaymentStatus
+	kind      ipay.RefundKind
+	available currency.Money
+}
+
+// refundBalance looks the payment up with Status. The amount of a settled payment is what was
+// charged, so refunded is subtracted from it; a hold that reports no amount yet falls back to
+// its invoice. The kind of any other status is unknown and nothing is available.
+func (c *client) refundBalance(ctx context.Context, request *Request, refunded int) (*refundBalance, error) {
+	response, err := c.StatusContext(ctx, &Request{
+		Merchant:    request.Merchant,
+		PaymentData: &PaymentData{IpayPaymentID: utils.Ref(request.GetIpayPaymentID())},
+	})
+	var status ipay.Result
+	if response != nil {
+		status = response.Result()
+	}
+	// A failed payment comes back with its status and an error describing the decline.
+	if err != nil && status.Status == ipay.PaymentStatusUnknown {
+		return nil, fmt.Errorf("checking refundable balance: %w", err)
+	}
+
+	balance := &refundBalance{status: status.Status, available: status.Amount}
+	switch status.Status {
+	case ipay.PaymentStatusPreAuthorized:
+		balance.kind = ipay.RefundKindVoid
+		if balance.available.IsZero() {
+			balance.available = status.Invoice
+		}
+	case ipay.PaymentStatusSuccess, ipay.PaymentStatusSuccessWithoutClaim:
+		balance.kind = ipay.RefundKindRefund
+		balance.available.Amount = max(balance.available.Amount-int64(refunded), 0)
+	default:
+		balance.available.Amount = 0
+	}
+	if balance.available.Currency == "" {
+		balance.available.Currency = request.GetCurrency()
+	}
+
+	return balance, nil
+}
+
+// check rejects a refund of amount that iPay would not accept.
+func (b *refundBalance) check(amount int) error {
+	if b.kind == ipay.RefundKindUnknown {
+		return fmt.Errorf("%w: %s", ErrNotRefundable, b.status.String())
+	}
+	if amount > 0 && int64(amount) > b.available.Amount {
+		return fmt.Errorf("%w: %d requested, %d left", ErrRefundExceedsBalance, amount, b.available.Amount)
+	}
+
+	return nil
+}
+
+// refundResult completes the typed result of a refund. Kind and Remaining are only known when
+// the payment could be looked up.
+func refundResult(result ipay.Result, balance *refundBalance, request *Request, err error) *ipay.RefundResult {
+	refund := &ipay.RefundResult{Result: result}
+
+	requested := request.GetAmount()
+	if balance == nil {
+		if err == nil && requested > 0 {
+			refund.Refunded = currency.New(int64(requested), request.GetCurrency())
+		}
+
+		return refund
+	}
+
+	refund.Kind = balance.kind
+	refund.Remaining = balance.available
+
+	if err == nil {
+		refund.Refunded = balance.available
+		if requested > 0 {
+			refund.Refunded = currency.New(int64(requested), balance.available.Currency)
+		}
+		refund.Remaining.Amount = max(refund.Remaining.Amount-refund.Refunded.Amount, 0)
+	}
+
+	return refund
+}
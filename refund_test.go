@@ -0,0 +1,107 @@
+package go_ipay
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+func TestRefund_SkipRefundBalanceCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   func() (*http.Response, error)
+		wantKind ipay.RefundKind
+	}{
+		{
+			name: "over the balance",
+			status: func() (*http.Response, error) {
+				return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5,"amount":50}}`)), nil
+			},
+			wantKind: ipay.RefundKindRefund,
+		},
+		{
+			name:   "lookup failed",
+			status: func() (*http.Response, error) { return nil, errors.New("connection reset") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actions []string
+			rt := actionTransport(t, &actions, func(action string, _ int) (*http.Response, error) {
+				if action == string(ipay.ActionGetPaymentStatus) {
+					return tt.status()
+				}
+
+				return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":9}}`)), nil
+			})
+			cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			result, err := cl.RefundResult(paymentRequest(), SkipRefundBalanceCheck())
+			if err != nil {
+				t.Fatalf("RefundResult() error: %v", err)
+			}
+
+			if want := []string{string(ipay.ActionGetPaymentStatus), string(ipay.ActionReversal)}; !reflect.DeepEqual(actions, want) {
+				t.Fatalf("actions = %v, want %v", actions, want)
+			}
+			if result.Kind != tt.wantKind || result.Refunded.Amount != 100 {
+				t.Fatalf("RefundResult() = %v refunded %v", result.Kind, result.Refunded)
+			}
+		})
+	}
+}
+
+func TestRefund_BalanceCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		refunded      int
+		wantErr       error
+		wantKind      ipay.RefundKind
+		wantRemaining int64
+	}{
+		{name: "settled", status: `{"pmt_id":1,"status":5,"amount":1000}`, refunded: 300, wantKind: ipay.RefundKindRefund, wantRemaining: 600},
+		{name: "settled without claim", status: `{"pmt_id":1,"status":13,"amount":1000}`, wantKind: ipay.RefundKindRefund, wantRemaining: 900},
+		{name: "held", status: `{"pmt_id":1,"status":3,"invoice":1000}`, wantKind: ipay.RefundKindVoid, wantRemaining: 900},
+		{name: "over the balance", status: `{"pmt_id":1,"status":5,"amount":1000}`, refunded: 950, wantErr: ErrRefundExceedsBalance},
+		{name: "canceled", status: `{"pmt_id":1,"status":9,"amount":1000}`, wantErr: ErrNotRefundable},
+		{name: "failed", status: `{"pmt_id":1,"status":4}`, wantErr: ErrNotRefundable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actions []string
+			rt := actionTransport(t, &actions, func(action string, _ int) (*http.Response, error) {
+				if action == string(ipay.ActionGetPaymentStatus) {
+					return teststand.Response(200, "application/json", []byte(`{"response":`+tt.status+`}`)), nil
+				}
+
+				return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":9}}`)), nil
+			})
+			cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+			result, err := cl.RefundResult(paymentRequest(), RefundedBefore(tt.refunded))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RefundResult() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(actions) != 1 {
+					t.Fatalf("actions = %v, want only the status lookup", actions)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RefundResult() error: %v", err)
+			}
+
+			if result.Kind != tt.wantKind || result.Refunded.Amount != 100 || result.Remaining.Amount != tt.wantRemaining {
+				t.Fatalf("RefundResult() = %v refunded %v remaining %v", result.Kind, result.Refunded, result.Remaining)
+			}
+		})
+	}
+}
@@ -24,13 +24,26 @@
 
 package repayment
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // APIError is returned when Repayment API responds with an error message.
 type APIError struct {
 	Message string
+	// StatusCode is the HTTP status when the API answered with a non-JSON body, 0 otherwise.
+	StatusCode int
 }
 
 func (e *APIError) Error() string {
 	return fmt.Sprintf("repayment API error: %s", e.Message)
 }
+
+// IsNotFound reports whether the API answered that it has no repayment with the requested ext_id
+// or GUID.
+func (e *APIError) IsNotFound() bool {
+	message := strings.ToLower(e.Message)
+
+	return e.StatusCode == 0 && strings.Contains(message, "repayment") && strings.Contains(message, "not found")
+}
@@ -26,6 +26,7 @@ package go_ipay
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -94,6 +95,11 @@ type GetRepaymentProcessingFileRequest struct {
 
 // CreateRepayment creates a repayment request via Repayment API.
 func (c *client) CreateRepayment(request *CreateRepaymentRequest, runOpts ...RunOption) (*repayment.Response, error) {
+	return c.CreateRepaymentContext(context.Background(), request, runOpts...)
+}
+
+// CreateRepaymentContext is like CreateRepayment but uses ctx for the request.
+func (c *client) CreateRepaymentContext(ctx context.Context, request *CreateRepaymentRequest, runOpts ...RunOption) (*repayment.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
@@ -101,7 +107,17 @@ func (c *client) CreateRepayment(request *CreateRepaymentRequest, runOpts ...Run
 		return nil, ErrMerchantIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	err := c.validate(consts.CreateRepayment, func(v *validator) {
+		v.repayment(request.Merchant, &request.ExtID)
+		if request.ExtID == "" {
+			v.add("ExtID", "is empty")
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	if request.Merchant.Login == "" {
 		return nil, fmt.Errorf("create repayment: merchant login is empty")
@@ -209,7 +225,7 @@ func (c *client) CreateRepayment(request *CreateRepaymentRequest, runOpts ...Run
 			AuthSign:          sign,
 		}
 
-		opts.handleDryRun(consts.RepaymentUrl, payload)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Repayment, payload)
 		return nil, nil
 	}
 
@@ -218,7 +234,13 @@ func (c *client) CreateRepayment(request *CreateRepaymentRequest, runOpts ...Run
 		apiFileReader = file
 	}
 
-	resp, err := c.ipayClient.RepaymentApi(repaymentRequest, fileName, apiFileReader)
+	resp, err := guarded(
+		ctx, c, consts.CreateRepayment, request.Merchant, &request.ExtID,
+		func(ctx context.Context) (*repayment.Response, error) {
+			return c.ipayClient.RepaymentApi(ctx, repaymentRequest, fileName, apiFileReader)
+		},
+		c.reconcileRepayment(request),
+	)
 	if err != nil {
 		return resp, fmt.Errorf("create repayment API call: %w", err)
 	}
@@ -231,6 +253,11 @@ func (c *client) CreateRepayment(request *CreateRepaymentRequest, runOpts ...Run
 
 // CancelRepayment cancels a repayment (allowed only on the creation day, per API docs).
 func (c *client) CancelRepayment(request *CancelRepaymentRequest, runOpts ...RunOption) (*repayment.Response, error) {
+	return c.CancelRepaymentContext(context.Background(), request, runOpts...)
+}
+
+// CancelRepaymentContext is like CancelRepayment but uses ctx for the request.
+func (c *client) CancelRepaymentContext(ctx context.Context, request *CancelRepaymentRequest, runOpts ...RunOption) (*repayment.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
@@ -238,7 +265,11 @@ func (c *client) CancelRepayment(request *CancelRepaymentRequest, runOpts ...Run
 		return nil, ErrMerchantIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.CancelRepayment, func(v *validator) { v.repayment(request.Merchant, request.ExtID) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	auth, err := repaymentAuth(request.Merchant)
 	if err != nil {
@@ -263,11 +294,11 @@ func (c *client) CancelRepayment(request *CancelRepaymentRequest, runOpts ...Run
 	}
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.RepaymentUrl, wrapper)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Repayment, wrapper)
 		return nil, nil
 	}
 
-	resp, err := c.ipayClient.RepaymentJSONApi(wrapper)
+	resp, err := c.ipayClient.RepaymentJSONApi(c.retryContext(ctx, opts, false), wrapper)
 	if err != nil {
 		return resp, fmt.Errorf("cancel repayment API call: %w", err)
 	}
@@ -280,6 +311,11 @@ func (c *client) CancelRepayment(request *CancelRepaymentRequest, runOpts ...Run
 
 // GetRepaymentStatus returns the current repayment status.
 func (c *client) GetRepaymentStatus(request *GetRepaymentStatusRequest, runOpts ...RunOption) (*repayment.Response, error) {
+	return c.GetRepaymentStatusContext(context.Background(), request, runOpts...)
+}
+
+// GetRepaymentStatusContext is like GetRepaymentStatus but uses ctx for the request.
+func (c *client) GetRepaymentStatusContext(ctx context.Context, request *GetRepaymentStatusRequest, runOpts ...RunOption) (*repayment.Response, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
@@ -287,7 +323,11 @@ func (c *client) GetRepaymentStatus(request *GetRepaymentStatusRequest, runOpts
 		return nil, ErrMerchantIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.GetRepaymentStatus, func(v *validator) { v.repayment(request.Merchant, request.ExtID) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	auth, err := repaymentAuth(request.Merchant)
 	if err != nil {
@@ -312,11 +352,11 @@ func (c *client) GetRepaymentStatus(request *GetRepaymentStatusRequest, runOpts
 	}
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.RepaymentUrl, wrapper)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Repayment, wrapper)
 		return nil, nil
 	}
 
-	resp, err := c.ipayClient.RepaymentJSONApi(wrapper)
+	resp, err := c.ipayClient.RepaymentJSONApi(c.retryContext(ctx, opts, true), wrapper)
 	if err != nil {
 		return resp, fmt.Errorf("get repayment status API call: %w", err)
 	}
@@ -329,6 +369,11 @@ func (c *client) GetRepaymentStatus(request *GetRepaymentStatusRequest, runOpts
 
 // GetRepaymentProcessingFile returns the CSV processing results for a repayment.
 func (c *client) GetRepaymentProcessingFile(request *GetRepaymentProcessingFileRequest, runOpts ...RunOption) ([]byte, error) {
+	return c.GetRepaymentProcessingFileContext(context.Background(), request, runOpts...)
+}
+
+// GetRepaymentProcessingFileContext is like GetRepaymentProcessingFile but uses ctx for the request.
+func (c *client) GetRepaymentProcessingFileContext(ctx context.Context, request *GetRepaymentProcessingFileRequest, runOpts ...RunOption) ([]byte, error) {
 	if request == nil {
 		return nil, ErrRequestIsNil
 	}
@@ -336,7 +381,11 @@ func (c *client) GetRepaymentProcessingFile(request *GetRepaymentProcessingFileR
 		return nil, ErrMerchantIsNil
 	}
 
-	opts := collectRunOptions(runOpts)
+	if err := c.validate(consts.GetRepaymentProcessingFile, func(v *validator) { v.repayment(request.Merchant, request.ExtID) }); err != nil {
+		return nil, err
+	}
+
+	opts := c.collectRunOptions(runOpts)
 
 	auth, err := repaymentAuth(request.Merchant)
 	if err != nil {
@@ -361,11 +410,11 @@ func (c *client) GetRepaymentProcessingFile(request *GetRepaymentProcessingFileR
 	}
 
 	if opts.isDryRun() {
-		opts.handleDryRun(consts.RepaymentUrl, wrapper)
+		opts.handleDryRun(ctx, c.ipayClient.Endpoints().Repayment, wrapper)
 		return nil, nil
 	}
 
-	raw, err := c.ipayClient.RepaymentProcessingFileApi(wrapper)
+	raw, err := c.ipayClient.RepaymentProcessingFileApi(c.retryContext(ctx, opts, true), wrapper)
 	if err != nil {
 		return raw, fmt.Errorf("get repayment processing file API call: %w", err)
 	}
@@ -29,15 +29,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/stremovskyy/go-ipay/currency"
 	"github.com/stremovskyy/go-ipay/internal/utils"
 	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/log"
 )
 
 type Request struct {
-	Merchant      *Merchant
-	PersonalData  *PersonalData
+	Merchant     *Merchant
+	PersonalData *PersonalData
+	// Sender is who sends the money: the card holder of a payment, or the client on whose behalf
+	// a Credit transfer is made. It is sent for financial monitoring.
+	Sender *Party
+	// Receiver is who receives the money. When nil, PersonalData describes the receiver.
+	Receiver      *Party
 	PaymentData   *PaymentData
 	PaymentMethod *PaymentMethod
 }
@@ -83,53 +90,91 @@ func (r *Request) GetRedirects() (string, string) {
 }
 
 func (r *Request) GetPersonalData() *ipay.Info {
-	if r.PersonalData == nil {
+	if r.PersonalData == nil && r.Sender == nil {
 		return &ipay.Info{}
 	}
 
 	info := &ipay.Info{}
 
-	if r.PersonalData.UserID != nil {
+	if r.PersonalData != nil && r.PersonalData.UserID != nil {
 		info.UserID = utils.Ref(strconv.Itoa(*r.PersonalData.UserID))
 	}
 
-	info.Cvd = &ipay.Cvd{
-		Firstname:  r.PersonalData.FirstName,
-		Lastname:   r.PersonalData.LastName,
-		Middlename: r.PersonalData.MiddleName,
-		TaxID:      r.PersonalData.TaxID,
-	}
+	info.Cvd = r.holder().cvd()
 
 	return info
 }
 
+// holder is the card holder of a payment: the Sender, or the user when no Sender is set.
+func (r *Request) holder() *Party {
+	if r.Sender != nil {
+		return r.Sender
+	}
+
+	return r.PersonalData.party()
+}
+
+// receiver is the Receiver, or the user when no Receiver is set.
+func (r *Request) receiver() *Party {
+	if r.Receiver != nil {
+		return r.Receiver
+	}
+
+	return r.PersonalData.party()
+}
+
+// GetReceiverCvd returns the receiver as the card holder of a Credit transfer, or nil.
+func (r *Request) GetReceiverCvd() *ipay.Cvd {
+	receiver := r.receiver()
+	if receiver == nil {
+		return nil
+	}
+
+	return receiver.cvd()
+}
+
 func (r *Request) GetAML() *ipay.Aml {
-	if r.PersonalData == nil {
-		return &ipay.Aml{}
+	info := &ipay.Aml{}
+
+	if sender := r.Sender; sender != nil {
+		info.SenderFirstname = sender.FirstName
+		info.SenderMiddlename = sender.MiddleName
+		info.SenderLastname = sender.LastName
+		info.SenderIdentificationNumber = sender.TaxID
+		info.SenderDocument = sender.Document
+		info.SenderAddress = sender.Address
+		info.SenderAccountNumber = sender.AccountNumber
 	}
 
-	info := &ipay.Aml{
-		ReceiverFirstname:            r.PersonalData.FirstName,
-		ReceiverMiddlename:           r.PersonalData.MiddleName,
-		ReceiverLastname:             r.PersonalData.LastName,
-		ReceiverIdentificationNumber: r.PersonalData.TaxID,
-		ReceiverToklyToken:           r.PersonalData.TrackingCardToken,
+	if receiver := r.receiver(); receiver != nil {
+		info.ReceiverFirstname = receiver.FirstName
+		info.ReceiverMiddlename = receiver.MiddleName
+		info.ReceiverLastname = receiver.LastName
+		info.ReceiverIdentificationNumber = receiver.TaxID
+		info.ReceiverDocument = receiver.Document
+		info.ReceiverAddress = receiver.Address
+		info.ReceiverAccountNumber = receiver.AccountNumber
+		info.ReceiverToklyToken = receiver.ToklyToken
 	}
 
 	return info
 }
 
 func (r *Request) GetReceiver() *ipay.Receiver {
-	if r.PersonalData == nil {
+	receiver := r.receiver()
+	if receiver == nil {
 		return &ipay.Receiver{}
 	}
 
 	info := &ipay.Receiver{
-		Lastname:             r.PersonalData.LastName,
-		Firstname:            r.PersonalData.FirstName,
-		Middlename:           r.PersonalData.MiddleName,
-		IdentificationNumber: r.PersonalData.TaxID,
-		ToklyToken:           r.PersonalData.TrackingCardToken,
+		Lastname:             receiver.LastName,
+		Firstname:            receiver.FirstName,
+		Middlename:           receiver.MiddleName,
+		Document:             receiver.Document,
+		Address:              receiver.Address,
+		IdentificationNumber: receiver.TaxID,
+		AccountNumber:        receiver.AccountNumber,
+		ToklyToken:           receiver.ToklyToken,
 	}
 
 	return info
@@ -143,16 +188,20 @@ func (r *Request) GetRecurrentToken() *string {
 	return r.PaymentMethod.RecurrentToken
 }
 
+// GetSender returns the Sender, or nil if none is set.
 func (r *Request) GetSender() *ipay.Sender {
-	if r.PersonalData == nil {
-		return &ipay.Sender{}
+	if r.Sender == nil {
+		return nil
 	}
 
 	info := &ipay.Sender{
-		Lastname:             r.PersonalData.LastName,
-		Firstname:            r.PersonalData.FirstName,
-		Middlename:           r.PersonalData.MiddleName,
-		IdentificationNumber: r.PersonalData.TaxID,
+		Lastname:             r.Sender.LastName,
+		Firstname:            r.Sender.FirstName,
+		Middlename:           r.Sender.MiddleName,
+		Document:             r.Sender.Document,
+		Address:              r.Sender.Address,
+		IdentificationNumber: r.Sender.TaxID,
+		AccountNumber:        r.Sender.AccountNumber,
 	}
 
 	return info
@@ -190,6 +239,60 @@ func (r *Request) GetCardPan() *string {
 	return r.PaymentMethod.Card.Pan
 }
 
+// HasCardData reports whether the request pays with raw card data.
+func (r *Request) HasCardData() bool {
+	return r.PaymentMethod != nil && r.PaymentMethod.CardData != nil
+}
+
+// GetCardData returns the card data encrypted with the merchant key for the cdata field, or nil
+// if the request has no card data.
+func (r *Request) GetCardData() (*string, error) {
+	return r.cardData(nil)
+}
+
+// cardData is GetCardData with the debug output of the cipher going to logger.
+func (r *Request) cardData(logger *log.Logger) (*string, error) {
+	if !r.HasCardData() {
+		return nil, nil
+	}
+
+	if r.Merchant == nil {
+		return nil, fmt.Errorf("card data: %w", ErrMerchantIsNil)
+	}
+
+	if r.Merchant.MerchantKey == "" {
+		return nil, fmt.Errorf("card data: merchant key is empty")
+	}
+
+	card := r.PaymentMethod.CardData
+	if card.Pan == "" {
+		return nil, ErrCardDataInvalid
+	}
+
+	if card.ExpMonth < 1 || card.ExpMonth > 12 || card.ExpYear < 0 {
+		return nil, fmt.Errorf("%w: expiry %02d/%d", ErrCardDataInvalid, card.ExpMonth, card.ExpYear)
+	}
+
+	cdata, err := ipay.EncryptCardDataWithLogger(r.Merchant.MerchantKey, ipay.CardData{
+		Pan:      card.Pan,
+		ExpMonth: fmt.Sprintf("%02d", card.ExpMonth),
+		ExpYear:  fmt.Sprintf("%02d", card.ExpYear%100),
+		Cvv:      card.Cvv,
+		Holder:   card.Holder,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cdata, nil
+}
+
+// VerifiesWithAmount reports whether the card is verified by charging the amount, see
+// PaymentData.VerifyWithAmount.
+func (r *Request) VerifiesWithAmount() bool {
+	return r.PaymentData != nil && r.PaymentData.VerifyWithAmount
+}
+
 func (r *Request) GetPaymentID() *string {
 	if r.PaymentData == nil {
 		return nil
@@ -232,6 +335,55 @@ func (r *Request) GetAmount() int {
 
 }
 
+func (r *Request) GetMoney() currency.Money {
+	if r.PaymentData == nil {
+		return currency.Money{}
+	}
+
+	return r.PaymentData.Money()
+}
+
+// HasSplits reports whether the payment is split between sub-merchants.
+func (r *Request) HasSplits() bool {
+	return r.PaymentData != nil && len(r.PaymentData.Splits) > 0
+}
+
+// GetSplitTransactions returns one transaction per split, with the invoice and the charged amount
+// as the action needs them, or nil if the payment is not split.
+func (r *Request) GetSplitTransactions(invoice, amount bool) []ipay.RequestTransaction {
+	if !r.HasSplits() {
+		return nil
+	}
+
+	txs := make([]ipay.RequestTransaction, len(r.PaymentData.Splits))
+	for i, split := range r.PaymentData.Splits {
+		tx := ipay.RequestTransaction{
+			SmchId: utils.Ref(split.SubMerchantID),
+			Desc:   split.Description,
+		}
+
+		if invoice {
+			tx.Invoice = split.Invoice
+		}
+
+		if amount {
+			tx.Amount = split.Amount
+			if tx.Amount == 0 {
+				tx.Amount = split.Invoice
+			}
+		}
+
+		if len(split.Metadata) > 0 {
+			metadata := strings.Join(utils.MapToStringSlice(split.Metadata), ";")
+			tx.Info = &ipay.Info{Metadata: &metadata}
+		}
+
+		txs[i] = tx
+	}
+
+	return txs
+}
+
 func (r *Request) GetDescription() string {
 	if r.PaymentData == nil {
 		return ""
@@ -258,7 +410,7 @@ func (r *Request) GetSubMerchantID() *int {
 }
 
 func (r *Request) HasRecurrent() bool {
-	if r.PaymentData == nil {
+	if r.PaymentData == nil || r.PaymentMethod == nil {
 		return false
 	}
 
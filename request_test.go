@@ -1,6 +1,17 @@
 package go_ipay
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
 
 func TestRequestIsMobile(t *testing.T) {
 	applePayload := "apple"
@@ -56,3 +67,141 @@ func TestRequestIsMobile(t *testing.T) {
 		})
 	}
 }
+
+func TestRequest_GetCardData(t *testing.T) {
+	request := &Request{
+		Merchant:      &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentMethod: &PaymentMethod{CardData: &CardData{Pan: "4111111111111111", ExpMonth: 3, ExpYear: 2031, Cvv: "123"}},
+	}
+
+	cdata, err := request.GetCardData()
+	if err != nil {
+		t.Fatalf("GetCardData() error = %v", err)
+	}
+
+	card, err := ipay.DecryptCardData("key", *cdata)
+	if err != nil {
+		t.Fatalf("DecryptCardData() error = %v", err)
+	}
+	if card.Pan != "4111111111111111" || card.ExpMonth != "03" || card.ExpYear != "31" || card.Cvv != "123" {
+		t.Fatalf("card = %+v", card)
+	}
+
+	request.PaymentMethod.CardData.ExpMonth = 13
+	if _, err := request.GetCardData(); !errors.Is(err, ErrCardDataInvalid) {
+		t.Fatalf("GetCardData() error = %v, want ErrCardDataInvalid", err)
+	}
+}
+
+func TestPayment_RejectsCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency currency.Code
+		allowed  currency.Set
+		wantErr  error
+	}{
+		{name: "unknown code", currency: "UAX", wantErr: currency.ErrUnknown},
+		{name: "not allowed for merchant", currency: currency.USD, allowed: currency.Set{currency.UAH}, wantErr: ErrCurrencyNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := teststand.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				t.Fatal("request was sent")
+				return nil, nil
+			})
+			cl := NewClient(WithClient(&http.Client{Transport: rt}))
+
+			request := paymentRequest()
+			request.PaymentData.Currency = tt.currency
+			request.Merchant.Currencies = tt.allowed
+
+			if _, err := cl.Payment(request); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Payment() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := cl.PaymentURL(request); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PaymentURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredit_SendsParties(t *testing.T) {
+	var sent map[string]any
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5}}`)), nil
+	})
+	cl := NewClient(WithClient(&http.Client{Transport: rt}))
+
+	request := paymentRequest()
+	request.Sender = &Party{
+		FirstName: utils.Ref("Ivan"), LastName: utils.Ref("Petrenko"), Document: utils.Ref("AA123456"),
+		Address: utils.Ref("Kyiv"), AccountNumber: utils.Ref("UA213223130000026007233566001"), ToklyToken: utils.Ref("ignored"),
+	}
+	request.Receiver = &Party{
+		FirstName: utils.Ref("Olena"), LastName: utils.Ref("Shevchenko"), TaxID: utils.Ref("1234567899"),
+		Phone: utils.Ref("+380501234567"), ToklyToken: utils.Ref("tokly"),
+	}
+
+	if _, err := cl.Credit(request); err != nil {
+		t.Fatalf("Credit() error: %v", err)
+	}
+
+	body := sent["request"].(map[string]any)["body"].(map[string]any)
+	want := map[string]map[string]any{
+		"sender": {
+			"firstname": "Ivan", "lastname": "Petrenko", "document": "AA123456", "address": "Kyiv",
+			"accountNumber": "UA213223130000026007233566001",
+		},
+		"receiver": {"firstname": "Olena", "lastname": "Shevchenko", "identification_number": "1234567899", "tokly_token": "tokly"},
+		"aml": {
+			"sender_firstname": "Ivan", "sender_lastname": "Petrenko", "sender_document": "AA123456",
+			"sender_address": "Kyiv", "sender_account_number": "UA213223130000026007233566001",
+			"receiver_firstname": "Olena", "receiver_lastname": "Shevchenko",
+			"receiver_identification_number": "1234567899", "receiver_tokly_token": "tokly",
+		},
+	}
+	for key, fields := range want {
+		got, _ := body[key].(map[string]any)
+		if len(got) != len(fields) {
+			t.Fatalf("%s = %v, want %v", key, got, fields)
+		}
+		for field, value := range fields {
+			if got[field] != value {
+				t.Fatalf("%s.%s = %v, want %v", key, field, got[field], value)
+			}
+		}
+	}
+
+	cvd := body["info"].(map[string]any)["cvd"].(map[string]any)
+	if cvd["tax_id"] != "1234567899" || cvd["phone_number"] != "+380501234567" || cvd["firstname"] != "Olena" {
+		t.Fatalf("cvd = %v, want the receiver", cvd)
+	}
+}
+
+func TestRequest_PersonalDataIsReceiver(t *testing.T) {
+	request := &Request{
+		PersonalData: &PersonalData{FirstName: utils.Ref("Olena"), TrackingCardToken: utils.Ref("tokly")},
+		Sender:       &Party{FirstName: utils.Ref("Ivan"), Phone: utils.Ref("+380501234567")},
+	}
+
+	if aml := request.GetAML(); *aml.ReceiverFirstname != "Olena" || *aml.ReceiverToklyToken != "tokly" || *aml.SenderFirstname != "Ivan" {
+		t.Fatalf("GetAML() = %+v", aml)
+	}
+	if cvd := request.GetPersonalData().Cvd.(*ipay.Cvd); *cvd.Firstname != "Ivan" || *cvd.PhoneNumber != "+380501234567" {
+		t.Fatalf("GetPersonalData().Cvd = %+v, want the sender as card holder", cvd)
+	}
+
+	request.Sender = nil
+	if sender := request.GetSender(); sender != nil {
+		t.Fatalf("GetSender() = %+v, want nil", sender)
+	}
+	if cvd := request.GetPersonalData().Cvd.(*ipay.Cvd); *cvd.Firstname != "Olena" {
+		t.Fatalf("GetPersonalData().Cvd = %+v, want the user", cvd)
+	}
+}
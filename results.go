@@ -0,0 +1,114 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// typedResult converts the response of a call into its typed result, keeping the error.
+func typedResult[T any](response *ipay.Response, err error, wrap func(ipay.Result) *T) (*T, error) {
+	if response == nil {
+		return nil, err
+	}
+
+	return wrap(response.Result()), err
+}
+
+func (c *client) PaymentResult(request *Request, runOpts ...RunOption) (*ipay.PaymentResult, error) {
+	return c.PaymentResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) PaymentResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.PaymentResult, error) {
+	response, err := c.PaymentContext(ctx, request, runOpts...)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.PaymentResult { return &ipay.PaymentResult{Result: r} })
+}
+
+func (c *client) HoldResult(request *Request, runOpts ...RunOption) (*ipay.HoldResult, error) {
+	return c.HoldResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) HoldResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.HoldResult, error) {
+	response, err := c.HoldContext(ctx, request, runOpts...)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.HoldResult { return &ipay.HoldResult{Result: r} })
+}
+
+func (c *client) CaptureResult(request *Request, runOpts ...RunOption) (*ipay.CaptureResult, error) {
+	return c.CaptureResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) CaptureResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.CaptureResult, error) {
+	response, err := c.CaptureContext(ctx, request, runOpts...)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.CaptureResult { return &ipay.CaptureResult{Result: r} })
+}
+
+func (c *client) RefundResult(request *Request, runOpts ...RunOption) (*ipay.RefundResult, error) {
+	return c.RefundResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) RefundResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.RefundResult, error) {
+	response, balance, err := c.refund(ctx, request, runOpts)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.RefundResult {
+		return refundResult(r, balance, request, err)
+	})
+}
+
+func (c *client) CreditResult(request *Request, runOpts ...RunOption) (*ipay.CreditResult, error) {
+	return c.CreditResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) CreditResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.CreditResult, error) {
+	response, err := c.CreditContext(ctx, request, runOpts...)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.CreditResult { return &ipay.CreditResult{Result: r} })
+}
+
+func (c *client) StatusResult(request *Request, runOpts ...RunOption) (*ipay.StatusResult, error) {
+	return c.StatusResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) StatusResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.StatusResult, error) {
+	response, err := c.StatusContext(ctx, request, runOpts...)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.StatusResult { return &ipay.StatusResult{Result: r} })
+}
+
+func (c *client) VerificationResult(request *Request, runOpts ...RunOption) (*ipay.VerificationResult, error) {
+	return c.VerificationResultContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) VerificationResultContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.VerificationResult, error) {
+	response, err := c.verification(ctx, request, runOpts)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.VerificationResult {
+		return &ipay.VerificationResult{Result: r, URL: response.Url}
+	})
+}
@@ -0,0 +1,91 @@
+package go_ipay
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+func jsonTransport(body string) *http.Client {
+	return &http.Client{Transport: teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return teststand.Response(200, "application/json", []byte(body)), nil
+	})}
+}
+
+func TestPaymentResult(t *testing.T) {
+	cl := NewClient(WithClient(jsonTransport(`{"response":{"pmt_id":10,"ext_id":"ext","status":5,"invoice":100,"amount":102,"card_mask":"444433******1111"}}`)))
+
+	result, err := cl.PaymentResult(paymentRequest())
+	if err != nil {
+		t.Fatalf("PaymentResult() error: %v", err)
+	}
+	if result.PaymentID != 10 || result.Status != ipay.PaymentStatusSuccess || result.Amount.Amount != 102 || result.CardMask != "444433******1111" {
+		t.Fatalf("PaymentResult() = %+v", result)
+	}
+	if len(result.Raw) == 0 {
+		t.Fatal("PaymentResult() has no raw body")
+	}
+}
+
+func TestPaymentResult_DeclineKeepsResult(t *testing.T) {
+	cl := NewClient(WithClient(jsonTransport(`{"response":{"pmt_id":11,"status":4,"bnk_error_note":"42-insufficient_funds"}}`)))
+
+	result, err := cl.PaymentResult(paymentRequest())
+	if err == nil {
+		t.Fatal("PaymentResult() error = nil, want the decline")
+	}
+	if result == nil || result.Status != ipay.PaymentStatusFailed || result.Bank.ErrorCode != "42-insufficient_funds" {
+		t.Fatalf("PaymentResult() = %+v, want the declined payment", result)
+	}
+}
+
+func TestVerificationResult(t *testing.T) {
+	cl := NewClient(WithClient(jsonTransport(`{"response":{"pmt_id":12,"status":1,"url":"https://ipay.example/verify/12"}}`)))
+
+	result, err := cl.VerificationResult(paymentRequest())
+	if err != nil {
+		t.Fatalf("VerificationResult() error: %v", err)
+	}
+	if result.URL != "https://ipay.example/verify/12" || result.PaymentID != 12 {
+		t.Fatalf("VerificationResult() = %+v", result)
+	}
+
+	if result, err := cl.VerificationResult(paymentRequest(), DryRun(func(string, any) {})); result != nil || err != nil {
+		t.Fatalf("VerificationResult(DryRun) = %+v, %v, want nil, nil", result, err)
+	}
+}
+
+func TestCreateToken(t *testing.T) {
+	var actions []string
+	cl := NewClient(WithClient(&http.Client{Transport: teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var body struct {
+			Request struct {
+				Action string `json:"action"`
+			} `json:"request"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		actions = append(actions, body.Request.Action)
+
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":13,"url":"https://ipay.example/token/13"}}`)), nil
+	})}))
+
+	result, err := cl.CreateToken(paymentRequest())
+	if err != nil {
+		t.Fatalf("CreateToken() error: %v", err)
+	}
+	if result.URL != "https://ipay.example/token/13" || result.PaymentID != 13 || result.Requested3DS {
+		t.Fatalf("CreateToken() = %+v", result)
+	}
+
+	if result, err = cl.CreateToken3DS(paymentRequest()); err != nil || !result.Requested3DS {
+		t.Fatalf("CreateToken3DS() = %+v, %v", result, err)
+	}
+
+	if want := []string{string(ipay.ActionCreateToken), string(ipay.ActionCreateToken3DS)}; !slices.Equal(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/internal/http"
+)
+
+// RetryPolicy controls how a failed call is repeated. Transport errors, HTTP 5xx responses and
+// transient iPay errors (see ipay.IpayError.IsTransient) are retried; everything else is returned at once.
+//
+// Read-only operations (Status, A2CPaymentStatus, GetRepaymentStatus, GetRepaymentProcessingFile) use the
+// client policy automatically. Operations that move money or create state only retry when the call
+// opts in with the Retry run option.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt.
+	Multiplier float64
+	// Jitter is the fraction (0..1) of every delay that is randomized.
+	Jitter float64
+}
+
+// DefaultRetryPolicy makes up to 3 attempts, waiting about 200ms and then 400ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+func (p RetryPolicy) internal() *http.RetryPolicy {
+	return &http.RetryPolicy{
+		MaxAttempts:    p.MaxAttempts,
+		InitialBackoff: p.InitialBackoff,
+		MaxBackoff:     p.MaxBackoff,
+		Multiplier:     p.Multiplier,
+		Jitter:         p.Jitter,
+	}
+}
+
+// WithRetryPolicy replaces the client retry policy. Use RetryPolicy{MaxAttempts: 1} to turn retries off.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *client) {
+		c.retryPolicy = policy
+	}
+}
+
+type retryMode int
+
+const (
+	retryModeDefault retryMode = iota
+	retryModeClient
+	retryModeCustom
+	retryModeOff
+)
+
+// Retry enables retries for a single call. Without an argument the client policy is used.
+// Only opt in for money-moving operations when the request carries a stable ext_id, so that
+// iPay can recognise a repeated attempt.
+func Retry(policy ...RetryPolicy) RunOption {
+	return func(o *runOptions) {
+		if len(policy) > 0 {
+			o.retryMode = retryModeCustom
+			o.retryPolicy = policy[0]
+			return
+		}
+
+		o.retryMode = retryModeClient
+	}
+}
+
+// NoRetry disables retries for a single call.
+func NoRetry() RunOption {
+	return func(o *runOptions) {
+		o.retryMode = retryModeOff
+	}
+}
+
+// retryContext returns ctx carrying the retry policy for a call. Idempotent calls fall back to
+// the client policy; others only retry when the caller asked for it.
+func (c *client) retryContext(ctx context.Context, opts *runOptions, idempotent bool) context.Context {
+	mode := retryModeDefault
+	if opts != nil {
+		mode = opts.retryMode
+	}
+
+	var policy RetryPolicy
+	switch {
+	case mode == retryModeCustom:
+		policy = opts.retryPolicy
+	case mode == retryModeClient, mode == retryModeDefault && idempotent:
+		policy = c.retryPolicy
+	default:
+		return ctx
+	}
+
+	return http.ContextWithRetryPolicy(ctx, policy.internal())
+}
@@ -0,0 +1,122 @@
+package go_ipay
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2}
+
+// flakyTransport answers with failures first and then with ok, recording request IDs.
+func flakyTransport(failures []*http.Response, ok []byte, requestIDs *[]string) teststand.RoundTripperFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		*requestIDs = append(*requestIDs, req.Header.Get("X-Request-ID"))
+		if n := len(*requestIDs); n <= len(failures) {
+			return failures[n-1], nil
+		}
+
+		return teststand.Response(200, "application/json", ok), nil
+	}
+}
+
+func paymentRequest() *Request {
+	return &Request{
+		Merchant:      &Merchant{MerchantID: "1", MerchantKey: "key"},
+		PaymentData:   &PaymentData{Amount: 100, PaymentID: utils.Ref("ext"), IpayPaymentID: utils.Ref(int64(1))},
+		PaymentMethod: &PaymentMethod{Card: &Card{Token: utils.Ref("token")}},
+	}
+}
+
+func TestStatus_RetriesAutomatically(t *testing.T) {
+	var requestIDs []string
+	rt := flakyTransport(
+		[]*http.Response{
+			teststand.Response(502, "text/html", []byte("bad gateway")),
+			teststand.Response(200, "application/json", []byte(`{"response":{"res_auth_code":908}}`)),
+		},
+		[]byte(`{"response":{"pmt_id":1,"status":5}}`),
+		&requestIDs,
+	)
+
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(testRetryPolicy))
+
+	if _, err := cl.Status(paymentRequest()); err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+	if len(requestIDs) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(requestIDs))
+	}
+	for _, id := range requestIDs {
+		if id == "" || id != requestIDs[0] {
+			t.Fatalf("request IDs = %v, want one shared ID", requestIDs)
+		}
+	}
+}
+
+func TestStatus_StopsOnPermanentError(t *testing.T) {
+	var requestIDs []string
+	rt := flakyTransport(
+		[]*http.Response{teststand.Response(200, "application/json", []byte(`{"response":{"res_auth_code":101}}`))},
+		[]byte(`{"response":{"pmt_id":1,"status":5}}`),
+		&requestIDs,
+	)
+
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(testRetryPolicy))
+
+	if _, err := cl.Status(paymentRequest()); err == nil {
+		t.Fatalf("Status() error = nil, want card expired error")
+	}
+	if len(requestIDs) != 1 {
+		t.Fatalf("attempts = %d, want 1", len(requestIDs))
+	}
+}
+
+func TestPayment_RetriesOnlyWhenOptedIn(t *testing.T) {
+	tests := []struct {
+		name    string
+		runOpts []RunOption
+		want    int
+	}{
+		{name: "default", want: 1},
+		{name: "client policy", runOpts: []RunOption{Retry()}, want: 2},
+		{name: "custom policy", runOpts: []RunOption{Retry(RetryPolicy{MaxAttempts: 2})}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestIDs []string
+			rt := flakyTransport(
+				[]*http.Response{teststand.Response(503, "text/plain", []byte("unavailable"))},
+				[]byte(`{"response":{"pmt_id":1,"status":5}}`),
+				&requestIDs,
+			)
+
+			cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(testRetryPolicy))
+			_, _ = cl.Payment(paymentRequest(), tt.runOpts...)
+
+			if len(requestIDs) != tt.want {
+				t.Fatalf("attempts = %d, want %d", len(requestIDs), tt.want)
+			}
+		})
+	}
+}
+
+func TestStatus_NoRetry(t *testing.T) {
+	var requestIDs []string
+	rt := flakyTransport(
+		[]*http.Response{teststand.Response(500, "text/plain", []byte("oops"))},
+		[]byte(`{"response":{"pmt_id":1,"status":5}}`),
+		&requestIDs,
+	)
+
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(testRetryPolicy))
+	_, _ = cl.Status(paymentRequest(), NoRetry())
+
+	if len(requestIDs) != 1 {
+		t.Fatalf("attempts = %d, want 1", len(requestIDs))
+	}
+}
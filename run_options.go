@@ -25,11 +25,15 @@
 package go_ipay
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 
+	"github.com/stremovskyy/go-ipay/internal/http"
 	"github.com/stremovskyy/go-ipay/ipay"
 	"github.com/stremovskyy/go-ipay/log"
+	"github.com/stremovskyy/go-ipay/redact"
 	"github.com/stremovskyy/go-ipay/repayment"
 )
 
@@ -39,13 +43,21 @@ type RunOption func(*runOptions)
 // DryRunHandler receives information about a skipped request.
 type DryRunHandler func(endpoint string, payload any)
 
+// DryRunContextHandler receives information about a skipped request together with the caller's context.
+type DryRunContextHandler func(ctx context.Context, endpoint string, payload any)
+
 type runOptions struct {
-	dryRun       bool
-	dryRunHandle DryRunHandler
+	dryRun          bool
+	dryRunHandle    DryRunHandler
+	dryRunCtxHandle DryRunContextHandler
+	retryMode       retryMode
+	retryPolicy     RetryPolicy
+	logger          *log.Logger
+	redactor        *redact.Redactor
+	skipRefundCheck bool
+	refunded        int
 }
 
-var dryRunLogger = log.NewLogger("iPay DryRun:")
-
 // DryRun skips the underlying HTTP call. An optional handler can be provided to inspect the request payload.
 func DryRun(handler ...DryRunHandler) RunOption {
 	return func(o *runOptions) {
@@ -53,13 +65,28 @@ func DryRun(handler ...DryRunHandler) RunOption {
 
 		if len(handler) > 0 && handler[0] != nil {
 			o.dryRunHandle = handler[0]
-			return
 		}
+	}
+}
+
+// DryRunContext is like DryRun, but the handler also receives the context passed to the *Context method.
+// The context carries the request ID (see RequestIDFromContext) that the real call would have used.
+func DryRunContext(handler DryRunContextHandler) RunOption {
+	return func(o *runOptions) {
+		o.dryRun = true
 
-		o.dryRunHandle = defaultDryRunHandler
+		if handler != nil {
+			o.dryRunCtxHandle = handler
+		}
 	}
 }
 
+// IsDryRun reports whether opts contain DryRun or DryRunContext. Helpers that record a payment
+// before sending it use it to leave a dry run unrecorded.
+func IsDryRun(opts ...RunOption) bool {
+	return collectRunOptions(opts).isDryRun()
+}
+
 func collectRunOptions(opts []RunOption) *runOptions {
 	if len(opts) == 0 {
 		return nil
@@ -76,25 +103,53 @@ func collectRunOptions(opts []RunOption) *runOptions {
 	return r
 }
 
+// collectRunOptions binds the collected options to the client, so that the default dry run
+// output goes to the client's log destination and is redacted like its other logs.
+func (c *client) collectRunOptions(opts []RunOption) *runOptions {
+	r := collectRunOptions(opts)
+	if r != nil {
+		r.logger = c.ipayClient.Logger("iPay DryRun:")
+		r.redactor = c.ipayClient.Redactor()
+	}
+
+	return r
+}
+
 func (o *runOptions) isDryRun() bool {
 	return o != nil && o.dryRun
 }
 
-func (o *runOptions) handleDryRun(endpoint string, payload any) {
+func (o *runOptions) handleDryRun(ctx context.Context, endpoint string, payload any) {
 	if o == nil || !o.dryRun {
 		return
 	}
 
+	if o.dryRunCtxHandle != nil {
+		ctx, _ = http.EnsureRequestID(ctx)
+		o.dryRunCtxHandle(ctx, endpoint, payload)
+		return
+	}
+
 	if o.dryRunHandle != nil {
 		o.dryRunHandle(endpoint, payload)
+		return
 	}
+
+	logDryRun(o.logger, o.redactor, endpoint, payload)
 }
 
-func defaultDryRunHandler(endpoint string, payload any) {
-	dryRunLogger.Info("Dry run: skipping request to %s", endpoint)
+// logDryRun is the default dry run handler: it logs the endpoint and the redacted payload at
+// info level.
+func logDryRun(logger *log.Logger, redactor *redact.Redactor, endpoint string, payload any) {
+	if logger == nil {
+		// Only options bound to a client, see (*client).collectRunOptions, are handled.
+		return
+	}
+
+	logger.Info("Dry run: skipping request to %s", endpoint)
 
 	if payload == nil {
-		dryRunLogger.Info("Dry run payload: <nil>")
+		logger.Info("Dry run payload: <nil>")
 		return
 	}
 
@@ -108,7 +163,7 @@ func defaultDryRunHandler(endpoint string, payload any) {
 			Request:   req.Request,
 		}
 
-		dryRunLogger.Info("Dry run payload:\n%s", marshalIndent(body))
+		logger.Info("Dry run payload:\n%s", marshalIndent(redactor, body))
 	case *repayment.RequestWrapper:
 		body := struct {
 			Operation string            `json:"operation"`
@@ -118,28 +173,33 @@ func defaultDryRunHandler(endpoint string, payload any) {
 			Request:   req.Request,
 		}
 
-		dryRunLogger.Info("Dry run payload:\n%s", marshalIndent(body))
+		logger.Info("Dry run payload:\n%s", marshalIndent(redactor, body))
 	case interface{ Marshal() ([]byte, error) }:
 		raw, err := req.Marshal()
 		if err != nil {
-			dryRunLogger.Info("Dry run payload marshal error for %T: %v", req, err)
+			logger.Info("Dry run payload marshal error for %T: %v", req, err)
 			return
 		}
-		dryRunLogger.Info("Dry run payload:\n%s", string(raw))
+		logger.Info("Dry run payload:\n%s", redactor.Bytes(raw))
 	default:
-		dryRunLogger.Info("Dry run payload:\n%s", marshalIndent(req))
+		logger.Info("Dry run payload:\n%s", marshalIndent(redactor, req))
 	}
 }
 
-func marshalIndent(v any) string {
+func marshalIndent(redactor *redact.Redactor, v any) string {
 	if v == nil {
 		return "<nil>"
 	}
 
-	out, err := json.MarshalIndent(v, "", "  ")
+	raw, err := json.Marshal(v)
 	if err != nil {
 		return fmt.Sprintf("unable to marshal %T: %v", v, err)
 	}
 
-	return string(out)
+	var out bytes.Buffer
+	if err := json.Indent(&out, redactor.Bytes(raw), "", "  "); err != nil {
+		return fmt.Sprintf("unable to marshal %T: %v", v, err)
+	}
+
+	return out.String()
 }
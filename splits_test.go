@@ -0,0 +1,124 @@
+package go_ipay
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+func splitRequest() *Request {
+	request := paymentRequest()
+	request.PaymentData.Amount = 1000
+	request.PaymentData.Currency = currency.UAH
+	request.PaymentData.Description = "order"
+	request.PaymentData.Splits = []Split{
+		{SubMerchantID: 10, Invoice: 700, Amount: 721},
+		{SubMerchantID: 20, Invoice: 300, Description: "delivery", Metadata: map[string]string{"kind": "delivery"}},
+	}
+
+	return request
+}
+
+func TestHold_SplitTransactions(t *testing.T) {
+	var sent ipay.RequestWrapper
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":5,"status":3,"transactions":[
+			{"trn_id":51,"smch_id":10,"invoice":700,"amount":721,"smch_bank":"A"},
+			{"trn_id":52,"smch_id":20,"invoice":300,"amount":300,"smch_bank":"B"}]}}`)), nil
+	})
+	cl := NewClient(WithClient(&http.Client{Transport: rt}))
+
+	result, err := cl.HoldResult(splitRequest())
+	if err != nil {
+		t.Fatalf("HoldResult() error: %v", err)
+	}
+
+	txs := sent.Request.Body.Transactions
+	if len(txs) != 2 {
+		t.Fatalf("transactions = %+v, want 2", txs)
+	}
+	if *txs[0].SmchId != 10 || txs[0].Invoice != 700 || txs[0].Amount != 721 || txs[0].Desc != "order" || txs[0].Currency != currency.UAH {
+		t.Fatalf("transaction 0 = %+v", txs[0])
+	}
+	if *txs[1].SmchId != 20 || txs[1].Invoice != 300 || txs[1].Amount != 300 || txs[1].Desc != "delivery" {
+		t.Fatalf("transaction 1 = %+v", txs[1])
+	}
+	for i, tx := range txs {
+		if tx.Info == nil || tx.Info.Preauth == nil || *tx.Info.Preauth != 1 {
+			t.Fatalf("transaction %d info = %+v, want preauth", i, tx.Info)
+		}
+	}
+	if got := *txs[1].Info.Metadata; got != "kind:delivery" {
+		t.Fatalf("transaction 1 metadata = %q, want %q", got, "kind:delivery")
+	}
+
+	if tx := result.Transaction(20); tx == nil || tx.ID != 52 || tx.Amount.Amount != 300 || tx.Bank != "B" {
+		t.Fatalf("Transaction(20) = %+v", tx)
+	}
+}
+
+func TestRefund_SplitTransactions(t *testing.T) {
+	var sent ipay.RequestWrapper
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		var wrapper ipay.RequestWrapper
+		if err := json.Unmarshal(body, &wrapper); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if wrapper.Request.Action == ipay.ActionGetPaymentStatus {
+			return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":5,"amount":1000}}`)), nil
+		}
+
+		sent = wrapper
+		return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":1,"status":9}}`)), nil
+	})
+	cl := NewClient(WithClient(&http.Client{Transport: rt}))
+
+	result, err := cl.RefundResult(splitRequest())
+	if err != nil {
+		t.Fatalf("RefundResult() error: %v", err)
+	}
+
+	if sent.Request.Action != ipay.ActionReversal {
+		t.Fatalf("action = %s, want %s", sent.Request.Action, ipay.ActionReversal)
+	}
+
+	txs := sent.Request.Body.Transactions
+	if len(txs) != 2 {
+		t.Fatalf("transactions = %+v, want 2", txs)
+	}
+	if *txs[0].SmchId != 10 || txs[0].Amount != 721 || txs[0].Invoice != 0 {
+		t.Fatalf("transaction 0 = %+v", txs[0])
+	}
+	if *txs[1].SmchId != 20 || txs[1].Amount != 300 || txs[1].Invoice != 0 {
+		t.Fatalf("transaction 1 = %+v", txs[1])
+	}
+
+	if result.Refunded.Amount != 1000 || result.Refunded.Currency != currency.UAH {
+		t.Fatalf("Refunded = %v, want 1000 UAH", result.Refunded)
+	}
+}
+
+func TestPayment_SplitsMustAddUp(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)))
+
+	request := splitRequest()
+	request.PaymentData.Splits[1].Invoice = 200
+	request.PaymentData.Splits[0].SubMerchantID = 0
+
+	_, err := cl.Payment(request)
+	fields := validationFields(t, err)
+	if len(fields) != 2 || fields[0] != "PaymentData.Splits[0].SubMerchantID" || fields[1] != "PaymentData.Splits" {
+		t.Fatalf("fields = %v", fields)
+	}
+}
@@ -0,0 +1,516 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// DefaultInterval is how often Run looks for subscriptions to charge.
+const DefaultInterval = time.Minute
+
+// DefaultDunning retries a declined charge after 1, 3 and 7 days before giving up.
+func DefaultDunning() []time.Duration {
+	return []time.Duration{24 * time.Hour, 3 * 24 * time.Hour, 7 * 24 * time.Hour}
+}
+
+// Manager starts subscriptions with a first payment, records the recurrent token from its webhook
+// and charges the token at the end of every period. A Manager serves one merchant; run a single
+// scheduler per merchant and store.
+type Manager struct {
+	client   go_ipay.Ipay
+	merchant *go_ipay.Merchant
+	store    Store
+	dunning  []time.Duration
+	interval time.Duration
+	onError  func(Subscription, error)
+	now      func() time.Time
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithDunning sets the delays before retrying a declined charge: the first delay follows the
+// first decline, and so on. Once they run out the subscription becomes StateUnpaid. No delays
+// means a single decline is final.
+func WithDunning(schedule ...time.Duration) Option {
+	return func(m *Manager) {
+		m.dunning = schedule
+	}
+}
+
+// WithInterval sets how often Run looks for subscriptions to charge.
+func WithInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		m.interval = d
+	}
+}
+
+// WithErrorHandler receives declined charges and the errors Run and ProcessDue hit. They keep
+// going with the next subscription; a charge that failed to reach iPay is repeated on the next pass.
+func WithErrorHandler(handler func(Subscription, error)) Option {
+	return func(m *Manager) {
+		m.onError = handler
+	}
+}
+
+// NewManager creates a Manager for the subscriptions of merchant.
+func NewManager(client go_ipay.Ipay, merchant *go_ipay.Merchant, store Store, opts ...Option) *Manager {
+	m := &Manager{
+		client:   client,
+		merchant: merchant,
+		store:    store,
+		dunning:  DefaultDunning(),
+		interval: DefaultInterval,
+		onError:  func(Subscription, error) {},
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Subscribe charges the first payment of plan with the payment method of request and records the
+// subscription. Its amount, currency and ext_id are set from the plan; iPay is asked for a
+// recurrent token, which arrives with the webhook of the payment, see HandleWebhook. Until then the
+// subscription is StatePending. A dry run returns nil, nil.
+//
+// The subscription is saved before the payment is sent, so that a webhook arriving before the
+// response is recognised. Calling Subscribe again for a subscription whose first payment has no
+// known outcome, e.g. after a crash, looks the payment up and sends it only when iPay does not
+// know it.
+func (m *Manager) Subscribe(ctx context.Context, id string, plan Plan, request *go_ipay.Request, runOpts ...go_ipay.RunOption) (*Subscription, error) {
+	if id == "" {
+		return nil, fmt.Errorf("subscription: empty id")
+	}
+	if plan.Interval.IsZero() || plan.Amount.Amount <= 0 {
+		return nil, fmt.Errorf("subscription: plan %q needs a positive amount and interval", plan.ID)
+	}
+	if request == nil {
+		return nil, go_ipay.ErrRequestIsNil
+	}
+
+	sub, err := m.store.Get(ctx, id)
+	switch {
+	case err == nil:
+		if sub.State != StatePending || !sub.Charging || sub.Cycle != 0 || sub.Plan.ID != plan.ID {
+			return nil, fmt.Errorf("%w: %s already exists", ErrInvalidState, id)
+		}
+
+		if handled, err := m.lookup(ctx, sub); handled {
+			return m.firstPayment(sub, err)
+		}
+		// iPay has no payment with this ext_id, so it is sent now.
+	case errors.Is(err, ErrNotFound):
+		sub = &Subscription{
+			ID:         id,
+			MerchantID: m.merchant.MerchantID,
+			Plan:       plan,
+			State:      StatePending,
+			Charging:   true,
+			CreatedAt:  m.now(),
+		}
+	default:
+		return nil, fmt.Errorf("subscription: get %s: %w", id, err)
+	}
+
+	if request.Merchant == nil {
+		request.Merchant = m.merchant
+	}
+	if request.PaymentData == nil {
+		request.PaymentData = &go_ipay.PaymentData{}
+	}
+
+	data := request.PaymentData
+	if err := data.SetMoney(plan.Amount); err != nil {
+		return nil, fmt.Errorf("subscription: plan %q: %w", plan.ID, err)
+	}
+
+	extID := sub.ExtID()
+	data.PaymentID = &extID
+	data.GetRecurrent = true
+	if data.Description == "" {
+		data.Description = plan.Description
+	}
+	sub.WebhookURL = utils.Deref(data.WebhookURL)
+
+	if go_ipay.IsDryRun(runOpts...) {
+		_, err := m.client.PaymentResultContext(ctx, request, runOpts...)
+		return nil, err
+	}
+	if _, err := m.save(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	result, err := m.client.PaymentResultContext(ctx, request, runOpts...)
+	if result == nil {
+		return nil, err
+	}
+
+	var declined error
+	if stored, gerr := m.store.Get(ctx, id); gerr == nil && !stored.Charging {
+		// The webhook has settled the payment already.
+		sub = stored
+		m.captureToken(sub, result.RecurrentToken)
+	} else {
+		declined = m.settle(sub, result.Result, err)
+	}
+
+	if _, err := m.save(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	return m.firstPayment(sub, declined)
+}
+
+// firstPayment returns the subscription after its first payment was settled or looked up. A
+// declined first payment is reported with ErrFirstPaymentDeclined.
+func (m *Manager) firstPayment(sub *Subscription, err error) (*Subscription, error) {
+	switch {
+	case sub.Charging:
+		if err != nil {
+			return nil, err
+		}
+	case sub.Cycle == 0:
+		if err == nil {
+			// Settled by the webhook, which leaves only the reason.
+			err = errors.New(sub.LastError)
+		}
+
+		return sub, fmt.Errorf("%w: %w", ErrFirstPaymentDeclined, err)
+	}
+
+	return sub, nil
+}
+
+// HandleWebhook applies a verified webhook of a subscription payment: it records the recurrent
+// token of the first payment and settles a charge that was still processing. It returns an error
+// wrapping ErrNotFound when the payment does not belong to a subscription.
+func (m *Manager) HandleWebhook(ctx context.Context, payment *ipay.Payment) (*Subscription, error) {
+	if payment == nil {
+		return nil, fmt.Errorf("%w: no payment", ErrNotFound)
+	}
+
+	id, cycle, attempt, ok := ParseExtID(utils.Deref(payment.ExtID))
+	if !ok {
+		return nil, fmt.Errorf("%w: ext_id %q", ErrNotFound, utils.Deref(payment.ExtID))
+	}
+
+	sub, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ipay.Response{Pmt: payment}.Result()
+	result.Status = payment.Status
+
+	if sub.Charging && cycle == sub.Cycle && attempt == sub.Attempt && payment.Status.IsFinal() {
+		m.settle(sub, result, nil)
+	} else {
+		m.captureToken(sub, result.RecurrentToken)
+	}
+
+	return m.save(ctx, sub)
+}
+
+// ProcessDue charges the subscriptions whose period has ended or whose dunning retry is due.
+// Declines and failures go to the error handler; only a failing Store stops the pass.
+func (m *Manager) ProcessDue(ctx context.Context) error {
+	due, err := m.store.Due(ctx, m.merchant.MerchantID, m.now())
+	if err != nil {
+		return fmt.Errorf("subscription: due subscriptions: %w", err)
+	}
+
+	for i := range due {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := m.charge(ctx, &due[i]); err != nil {
+			m.onError(due[i], err)
+		}
+	}
+
+	return nil
+}
+
+// Run calls ProcessDue right away and then at every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.ProcessDue(ctx); err != nil && ctx.Err() == nil {
+			m.onError(Subscription{}, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Pause stops charging an active or past due subscription. The period keeps running, so a
+// subscription resumed after its period ended is charged on the next pass.
+func (m *Manager) Pause(ctx context.Context, id string) (*Subscription, error) {
+	return m.transition(ctx, id, StatePaused, StateActive, StatePastDue)
+}
+
+// Resume charges a paused or unpaid subscription again. An unpaid subscription is charged on the
+// next pass and gets the full dunning schedule.
+func (m *Manager) Resume(ctx context.Context, id string) (*Subscription, error) {
+	sub, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sub.State {
+	case StatePaused:
+		sub.State = StateActive
+		if sub.Declines > 0 {
+			sub.State = StatePastDue
+		}
+	case StateUnpaid:
+		sub.State = StatePastDue
+		sub.Declines = 0
+		sub.NextChargeAt = m.now()
+	default:
+		return nil, fmt.Errorf("%w: cannot resume %s, it is %s", ErrInvalidState, id, sub.State)
+	}
+
+	return m.save(ctx, sub)
+}
+
+// Cancel ends the subscription. A charge already sent to iPay is still recorded when its webhook
+// arrives.
+func (m *Manager) Cancel(ctx context.Context, id string) (*Subscription, error) {
+	return m.transition(ctx, id, StateCanceled, StatePending, StateActive, StatePastDue, StateUnpaid, StatePaused)
+}
+
+func (m *Manager) transition(ctx context.Context, id string, to State, from ...State) (*Subscription, error) {
+	sub, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, state := range from {
+		if sub.State == state {
+			sub.State = to
+			return m.save(ctx, sub)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: cannot move %s from %s to %s", ErrInvalidState, id, sub.State, to)
+}
+
+// charge settles the charge of the current ext_id if iPay already has it, and sends it only when
+// iPay answers that it does not know it. The subscription is saved as charging before the
+// request, so that a charge whose answer was lost is looked up on the next pass instead of being
+// made twice.
+func (m *Manager) charge(ctx context.Context, sub *Subscription) error {
+	if sub.Charging {
+		if handled, err := m.lookup(ctx, sub); handled {
+			return err
+		}
+		// iPay has no payment with this ext_id, so it is sent now.
+	}
+
+	request, err := m.chargeRequest(sub)
+	if err != nil {
+		return err
+	}
+
+	sub.Charging = true
+	if _, err := m.save(ctx, sub); err != nil {
+		return err
+	}
+
+	result, err := m.client.PaymentResultContext(ctx, request)
+	if result == nil {
+		return fmt.Errorf("subscription: charge %s: %w", sub.ExtID(), err)
+	}
+
+	declined := m.settle(sub, result.Result, err)
+	if _, err := m.save(ctx, sub); err != nil {
+		return err
+	}
+
+	return declined
+}
+
+// lookup settles the charge of the current ext_id if iPay already has it and returns the decline,
+// if any. It reports false only when iPay answers that it does not know the charge, so that it can
+// be sent.
+func (m *Manager) lookup(ctx context.Context, sub *Subscription) (bool, error) {
+	extID := sub.ExtID()
+	status, err := m.client.StatusResultContext(ctx, &go_ipay.Request{
+		Merchant:    m.merchant,
+		PaymentData: &go_ipay.PaymentData{PaymentID: &extID},
+	})
+	if status != nil && status.PaymentID != 0 {
+		declined := m.settle(sub, status.Result, err)
+		if _, err := m.save(ctx, sub); err != nil {
+			return true, err
+		}
+
+		return true, declined
+	}
+
+	var ierr *ipay.IpayError
+	if errors.As(err, &ierr) && ierr.IsNotFound() {
+		return false, nil
+	}
+
+	// The charge may exist: keep it charging and look it up again on the next pass.
+	if err == nil {
+		err = errors.New("status lookup returned no payment")
+	}
+	sub.LastError = err.Error()
+	if _, saveErr := m.save(ctx, sub); saveErr != nil {
+		return true, saveErr
+	}
+
+	return true, fmt.Errorf("subscription: look up charge %s: %w", extID, err)
+}
+
+func (m *Manager) chargeRequest(sub *Subscription) (*go_ipay.Request, error) {
+	extID := sub.ExtID()
+	data := &go_ipay.PaymentData{PaymentID: &extID, Description: sub.Plan.Description}
+	if err := data.SetMoney(sub.Plan.Amount); err != nil {
+		return nil, fmt.Errorf("subscription: plan %q: %w", sub.Plan.ID, err)
+	}
+	if sub.WebhookURL != "" {
+		data.WebhookURL = &sub.WebhookURL
+	}
+
+	token := sub.RecurrentToken
+
+	return &go_ipay.Request{
+		Merchant:      m.merchant,
+		PaymentData:   data,
+		PaymentMethod: &go_ipay.PaymentMethod{RecurrentToken: &token},
+	}, nil
+}
+
+// settle applies the outcome of the current charge and returns an error describing a decline.
+// A charge still processing is left for the next pass or its webhook.
+func (m *Manager) settle(sub *Subscription, result ipay.Result, err error) error {
+	if result.PaymentID != 0 {
+		sub.LastPaymentID = result.PaymentID
+	}
+
+	switch result.Status {
+	case ipay.PaymentStatusSuccess, ipay.PaymentStatusSuccessWithoutClaim:
+		m.paid(sub, result)
+		return nil
+	case ipay.PaymentStatusRegistered, ipay.PaymentStatusManualProcessing, ipay.PaymentStatusAMLRequired:
+		sub.Charging = true
+		return nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf("payment %d is %s", result.PaymentID, result.Status.String())
+	}
+
+	extID := sub.ExtID()
+	m.declined(sub, err)
+
+	return fmt.Errorf("subscription: charge %s declined: %w", extID, err)
+}
+
+func (m *Manager) paid(sub *Subscription, result ipay.Result) {
+	start := sub.PeriodEnd
+	if sub.Cycle == 0 {
+		start = m.now()
+	}
+
+	sub.PeriodEnd = sub.Plan.Interval.Next(start)
+	sub.NextChargeAt = sub.PeriodEnd
+	sub.Cycle++
+	sub.Attempt = 0
+	sub.Declines = 0
+	sub.Charging = false
+	sub.LastError = ""
+
+	if sub.State != StatePaused && sub.State != StateCanceled {
+		sub.State = StatePending
+	}
+	m.captureToken(sub, result.RecurrentToken)
+}
+
+func (m *Manager) declined(sub *Subscription, err error) {
+	sub.Attempt++
+	sub.Declines++
+	sub.Charging = false
+	sub.LastError = err.Error()
+
+	if sub.State == StatePaused || sub.State == StateCanceled {
+		return
+	}
+
+	switch {
+	case sub.Cycle == 0:
+		sub.State = StateCanceled
+	case sub.Declines > len(m.dunning):
+		sub.State = StateUnpaid
+	default:
+		sub.State = StatePastDue
+		sub.NextChargeAt = m.now().Add(m.dunning[sub.Declines-1])
+	}
+}
+
+// captureToken records the recurrent token of the first payment and activates a subscription
+// whose first payment has gone through.
+func (m *Manager) captureToken(sub *Subscription, token string) {
+	if sub.RecurrentToken == "" && token != "" {
+		sub.RecurrentToken = token
+	}
+
+	if sub.State == StatePending && sub.Cycle > 0 && sub.RecurrentToken != "" {
+		sub.State = StateActive
+	}
+}
+
+func (m *Manager) save(ctx context.Context, sub *Subscription) (*Subscription, error) {
+	sub.UpdatedAt = m.now()
+
+	if err := m.store.Save(ctx, *sub); err != nil {
+		return nil, fmt.Errorf("subscription: save %s: %w", sub.ID, err)
+	}
+
+	return sub, nil
+}
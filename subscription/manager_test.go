@@ -0,0 +1,344 @@
+package subscription
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/ipaytest"
+)
+
+var (
+	merchant = &go_ipay.Merchant{MerchantID: "1", MerchantKey: "key"}
+	plan     = Plan{ID: "basic", Amount: currency.New(9900, currency.UAH), Interval: Monthly, Description: "Basic plan"}
+)
+
+func cardRequest() *go_ipay.Request {
+	return &go_ipay.Request{PaymentMethod: &go_ipay.PaymentMethod{Card: &go_ipay.Card{Token: utils.Ref("tok")}}}
+}
+
+func newManager(t *testing.T, opts ...Option) (*Manager, *ipaytest.Fake, *time.Time) {
+	t.Helper()
+
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(cl, merchant, NewMemoryStore(), opts...)
+	m.now = func() time.Time { return now }
+
+	return m, fake, &now
+}
+
+// subscribe starts a subscription and delivers the webhook of its first payment.
+func subscribe(t *testing.T, m *Manager, fake *ipaytest.Fake, id string) *Subscription {
+	t.Helper()
+
+	sub, err := m.Subscribe(t.Context(), id, plan, cardRequest())
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	if sub.State != StatePending || sub.Cycle != 1 {
+		t.Fatalf("Subscribe() = %+v, want a pending subscription past cycle 0", sub)
+	}
+
+	p, ok := fake.Payment(sub.LastPaymentID)
+	if !ok || p.ExtID != ExtID(id, 0, 0) || p.Invoice != 9900 {
+		t.Fatalf("first payment = %+v", p)
+	}
+
+	sub, err = m.HandleWebhook(t.Context(), &ipay.Payment{PmtId: int(p.ID), ExtID: &p.ExtID, Status: p.Status, RecurrentToken: &p.RecurrentToken})
+	if err != nil {
+		t.Fatalf("HandleWebhook() error: %v", err)
+	}
+	if sub.State != StateActive || sub.RecurrentToken != p.RecurrentToken {
+		t.Fatalf("HandleWebhook() = %+v, want an active subscription with the token", sub)
+	}
+
+	return sub
+}
+
+func TestManager_ChargesEveryPeriod(t *testing.T) {
+	ctx := t.Context()
+	m, fake, now := newManager(t)
+	sub := subscribe(t, m, fake, "sub-1")
+
+	if want := now.AddDate(0, 1, 0); !sub.NextChargeAt.Equal(want) {
+		t.Fatalf("NextChargeAt = %v, want %v", sub.NextChargeAt, want)
+	}
+
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+	if _, ok := fake.PaymentByExtID(ExtID("sub-1", 1, 0)); ok {
+		t.Fatal("charged before the period ended")
+	}
+
+	*now = sub.NextChargeAt
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+
+	p, ok := fake.PaymentByExtID(ExtID("sub-1", 1, 0))
+	if !ok || p.Status != ipay.PaymentStatusSuccess || p.Invoice != 9900 {
+		t.Fatalf("renewal = %+v, %v", p, ok)
+	}
+
+	sub, _ = m.store.Get(ctx, "sub-1")
+	if sub.Cycle != 2 || sub.State != StateActive || sub.LastPaymentID != p.ID || !sub.PeriodEnd.Equal(now.AddDate(0, 1, 0)) {
+		t.Fatalf("subscription = %+v", sub)
+	}
+}
+
+func TestManager_Dunning(t *testing.T) {
+	ctx := t.Context()
+	var declines []error
+	m, fake, now := newManager(t, WithDunning(time.Hour), WithErrorHandler(func(_ Subscription, err error) {
+		declines = append(declines, err)
+	}))
+	sub := subscribe(t, m, fake, "sub-2")
+
+	fake.RegisterCard(sub.RecurrentToken, "3333333333333349")
+	*now = sub.NextChargeAt
+
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+	sub, _ = m.store.Get(ctx, "sub-2")
+	if sub.State != StatePastDue || sub.Declines != 1 || !sub.NextChargeAt.Equal(now.Add(time.Hour)) || len(declines) != 1 {
+		t.Fatalf("after a decline: %+v, declines %v", sub, declines)
+	}
+
+	*now = now.Add(time.Hour)
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+	sub, _ = m.store.Get(ctx, "sub-2")
+	if sub.State != StateUnpaid || sub.Attempt != 2 {
+		t.Fatalf("after dunning: %+v", sub)
+	}
+
+	fake.RegisterCard(sub.RecurrentToken, ipaytest.DefaultPan)
+	if _, err := m.Resume(ctx, "sub-2"); err != nil {
+		t.Fatalf("Resume() error: %v", err)
+	}
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+
+	if p, ok := fake.PaymentByExtID(ExtID("sub-2", 1, 2)); !ok || p.Status != ipay.PaymentStatusSuccess {
+		t.Fatalf("retry = %+v, %v", p, ok)
+	}
+	sub, _ = m.store.Get(ctx, "sub-2")
+	if sub.State != StateActive || sub.Cycle != 2 || sub.Declines != 0 || sub.LastError != "" {
+		t.Fatalf("after paying: %+v", sub)
+	}
+}
+
+func TestManager_PauseAndCancel(t *testing.T) {
+	ctx := t.Context()
+	m, fake, now := newManager(t)
+	sub := subscribe(t, m, fake, "sub-3")
+
+	if _, err := m.Pause(ctx, "sub-3"); err != nil {
+		t.Fatalf("Pause() error: %v", err)
+	}
+
+	*now = sub.NextChargeAt
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+	if _, ok := fake.PaymentByExtID(ExtID("sub-3", 1, 0)); ok {
+		t.Fatal("charged a paused subscription")
+	}
+
+	if sub, err := m.Cancel(ctx, "sub-3"); err != nil || sub.State != StateCanceled {
+		t.Fatalf("Cancel() = %+v, %v", sub, err)
+	}
+	if _, err := m.Resume(ctx, "sub-3"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Resume() error = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestManager_FirstPaymentDeclined(t *testing.T) {
+	m, fake, _ := newManager(t)
+	fake.RegisterCard("tok", "3333333333333349")
+
+	sub, err := m.Subscribe(t.Context(), "sub-4", plan, cardRequest())
+	if !errors.Is(err, ErrFirstPaymentDeclined) {
+		t.Fatalf("Subscribe() error = %v, want %v", err, ErrFirstPaymentDeclined)
+	}
+	if sub == nil || sub.State != StateCanceled {
+		t.Fatalf("Subscribe() = %+v, want a canceled subscription", sub)
+	}
+
+	if _, err := m.Subscribe(t.Context(), "sub-4", plan, cardRequest()); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Subscribe() again error = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestParseExtID(t *testing.T) {
+	id, cycle, attempt, ok := ParseExtID(ExtID("team-42", 3, 1))
+	if !ok || id != "team-42" || cycle != 3 || attempt != 1 {
+		t.Fatalf("ParseExtID() = %q, %d, %d, %v", id, cycle, attempt, ok)
+	}
+
+	for _, extID := range []string{"", "order-1", "-1-0", "sub-x-0", "sub-1--1"} {
+		if _, _, _, ok := ParseExtID(extID); ok {
+			t.Fatalf("ParseExtID(%q) ok = true", extID)
+		}
+	}
+}
+
+func TestManager_LookupErrorKeepsCharging(t *testing.T) {
+	ctx := t.Context()
+	fake := ipaytest.New()
+
+	var (
+		fault   func(action ipay.Action) (*http.Response, error)
+		actions []ipay.Action
+	)
+	cl := go_ipay.NewClient(
+		go_ipay.WithRetryPolicy(go_ipay.RetryPolicy{MaxAttempts: 1}),
+		go_ipay.WithClient(&http.Client{Transport: teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			var wrapper ipay.RequestWrapper
+			if err := json.Unmarshal(body, &wrapper); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			actions = append(actions, wrapper.Request.Action)
+
+			if fault != nil {
+				if resp, err := fault(wrapper.Request.Action); resp != nil || err != nil {
+					return resp, err
+				}
+			}
+
+			return fake.RoundTrip(req)
+		})}),
+	)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(cl, merchant, NewMemoryStore())
+	m.now = func() time.Time { return now }
+
+	sub := subscribe(t, m, fake, "sub-1")
+	now = sub.NextChargeAt
+
+	// The renewal never reaches iPay.
+	fault = func(ipay.Action) (*http.Response, error) { return nil, errors.New("connection reset") }
+	_ = m.ProcessDue(ctx)
+
+	// The lookup of the renewal fails with a temporary code: nothing is sent.
+	actions = nil
+	fault = func(ipay.Action) (*http.Response, error) {
+		return teststand.Response(200, "application/json", []byte(`{"response":{"res_auth_code":907}}`)), nil
+	}
+	_ = m.ProcessDue(ctx)
+
+	if len(actions) != 1 || actions[0] != ipay.ActionGetPaymentStatus {
+		t.Fatalf("actions = %v, want a single status lookup", actions)
+	}
+	sub, _ = m.store.Get(ctx, "sub-1")
+	if !sub.Charging || sub.LastError == "" || sub.Cycle != 1 {
+		t.Fatalf("subscription = %+v, want it still charging with the lookup error", sub)
+	}
+
+	// iPay answers that it does not know the renewal, so it is sent.
+	fault = nil
+	if err := m.ProcessDue(ctx); err != nil {
+		t.Fatalf("ProcessDue() error: %v", err)
+	}
+
+	p, ok := fake.PaymentByExtID(ExtID("sub-1", 1, 0))
+	if !ok || p.Status != ipay.PaymentStatusSuccess {
+		t.Fatalf("renewal = %+v, %v", p, ok)
+	}
+	sub, _ = m.store.Get(ctx, "sub-1")
+	if sub.Charging || sub.Cycle != 2 || sub.LastError != "" {
+		t.Fatalf("subscription = %+v, want it renewed", sub)
+	}
+}
+
+func TestManager_WebhookBeforeResponse(t *testing.T) {
+	fake := ipaytest.New()
+
+	var m *Manager
+	cl := go_ipay.NewClient(go_ipay.WithClient(&http.Client{Transport: teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := fake.RoundTrip(req)
+
+		// The webhook of the first payment overtakes its response.
+		p, _ := fake.PaymentByExtID(ExtID("sub-1", 0, 0))
+		if _, err := m.HandleWebhook(t.Context(), &ipay.Payment{PmtId: int(p.ID), ExtID: &p.ExtID, Status: p.Status, RecurrentToken: &p.RecurrentToken}); err != nil {
+			t.Errorf("HandleWebhook() error: %v", err)
+		}
+
+		return resp, err
+	})}))
+	m = NewManager(cl, merchant, NewMemoryStore())
+
+	sub, err := m.Subscribe(t.Context(), "sub-1", plan, cardRequest())
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	if sub.State != StateActive || sub.Cycle != 1 || sub.RecurrentToken == "" {
+		t.Fatalf("Subscribe() = %+v, want an active subscription paid once", sub)
+	}
+}
+
+func TestManager_SubscribeAgainAfterLostResponse(t *testing.T) {
+	fake := ipaytest.New()
+
+	var actions []ipay.Action
+	cl := go_ipay.NewClient(
+		go_ipay.WithRetryPolicy(go_ipay.RetryPolicy{MaxAttempts: 1}),
+		go_ipay.WithClient(&http.Client{Transport: teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			var wrapper ipay.RequestWrapper
+			if err := json.Unmarshal(body, &wrapper); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			actions = append(actions, wrapper.Request.Action)
+
+			resp, err := fake.RoundTrip(req)
+			if len(actions) == 1 {
+				// iPay makes the first payment, but its answer is lost.
+				return nil, errors.New("connection reset")
+			}
+
+			return resp, err
+		})}),
+	)
+	m := NewManager(cl, merchant, NewMemoryStore())
+
+	if _, err := m.Subscribe(t.Context(), "sub-1", plan, cardRequest()); err == nil {
+		t.Fatal("Subscribe() error = nil, want the lost response")
+	}
+	if sub, err := m.store.Get(t.Context(), "sub-1"); err != nil || !sub.Charging {
+		t.Fatalf("stored subscription = %+v, %v, want it charging", sub, err)
+	}
+
+	sub, err := m.Subscribe(t.Context(), "sub-1", plan, cardRequest())
+	if err != nil {
+		t.Fatalf("Subscribe() again error: %v", err)
+	}
+	if sub.Cycle != 1 || sub.Charging {
+		t.Fatalf("Subscribe() again = %+v, want the first payment settled", sub)
+	}
+	if len(actions) != 2 || actions[1] != ipay.ActionGetPaymentStatus {
+		t.Fatalf("actions = %v, want the payment and a status lookup", actions)
+	}
+}
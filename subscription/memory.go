@@ -0,0 +1,87 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package subscription
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps subscriptions in process memory, so they are lost on restart. Use a
+// persistent Store in production.
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subscriptions: make(map[string]Subscription)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, subscription Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions[subscription.ID] = subscription
+
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, id string) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.subscriptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &subscription, nil
+}
+
+// Due implements Store. Subscriptions are returned by NextChargeAt, earliest first.
+func (s *MemoryStore) Due(_ context.Context, merchantID string, t time.Time) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Subscription
+	for _, sub := range s.subscriptions {
+		if sub.MerchantID != merchantID || (sub.State != StateActive && sub.State != StatePastDue) {
+			continue
+		}
+		if !sub.NextChargeAt.After(t) {
+			due = append(due, sub)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].NextChargeAt.Before(due[j].NextChargeAt) })
+
+	return due, nil
+}
@@ -0,0 +1,182 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package subscription bills customers on a schedule with the recurrent token of their first
+// payment, retries declined charges and lets subscriptions be paused, resumed and canceled.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/currency"
+)
+
+// State is the lifecycle stage of a subscription.
+type State string
+
+const (
+	// StatePending means the first payment was made and the recurrent token has not arrived yet.
+	StatePending State = "pending"
+	// StateActive means the subscription is paid up and charged at the end of every period.
+	StateActive State = "active"
+	// StatePastDue means a charge was declined and is retried on the dunning schedule.
+	StatePastDue State = "past_due"
+	// StateUnpaid means every dunning retry was declined. Resume charges the subscription again.
+	StateUnpaid State = "unpaid"
+	// StatePaused means no charges are made until Resume.
+	StatePaused State = "paused"
+	// StateCanceled means the subscription has ended for good.
+	StateCanceled State = "canceled"
+)
+
+var (
+	// ErrNotFound is returned by Store.Get when the subscription is unknown.
+	ErrNotFound = errors.New("subscription: not found")
+	// ErrInvalidState is returned for a change the subscription cannot make in its current state,
+	// e.g. resuming a canceled subscription.
+	ErrInvalidState = errors.New("subscription: invalid state")
+	// ErrFirstPaymentDeclined is returned by Subscribe when the first payment fails.
+	ErrFirstPaymentDeclined = errors.New("subscription: first payment declined")
+)
+
+// Interval is the length of a billing period.
+type Interval struct {
+	Months int
+	Days   int
+}
+
+var (
+	Weekly  = Interval{Days: 7}
+	Monthly = Interval{Months: 1}
+	Yearly  = Interval{Months: 12}
+)
+
+// Next returns the end of the period starting at t. Months follow time.AddDate, so a period
+// starting on January 31 ends on March 2 or 3.
+func (i Interval) Next(t time.Time) time.Time {
+	return t.AddDate(0, i.Months, i.Days)
+}
+
+// IsZero reports whether the interval is empty.
+func (i Interval) IsZero() bool {
+	return i.Months <= 0 && i.Days <= 0
+}
+
+// Plan is what a subscription charges and how often.
+type Plan struct {
+	ID string
+	// Amount is charged once per Interval.
+	Amount      currency.Money
+	Interval    Interval
+	Description string
+}
+
+// Subscription is a customer billed on a Plan.
+type Subscription struct {
+	ID         string
+	MerchantID string
+	Plan       Plan
+	State      State
+	// RecurrentToken is taken from the webhook of the first payment and charged on every cycle.
+	RecurrentToken string
+	// WebhookURL receives the notifications of the recurring charges.
+	WebhookURL string
+	// Cycle is the number of the next billing cycle; the first payment is cycle 0.
+	Cycle int
+	// Attempt counts the declined charges of the current cycle and keeps their ext_ids apart.
+	Attempt int
+	// Declines counts the declined charges since the last successful one or Resume; it walks the
+	// dunning schedule.
+	Declines int
+	// Charging is set while a charge with the current ext_id may exist at iPay, so that the
+	// scheduler looks it up instead of charging again.
+	Charging bool
+	// PeriodEnd is when the paid period ends and the next cycle is due.
+	PeriodEnd time.Time
+	// NextChargeAt is when the scheduler charges next: PeriodEnd, or a dunning retry.
+	NextChargeAt  time.Time
+	LastPaymentID int64
+	LastError     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// ExtID is the ext_id of the current charge. It only changes when a cycle is paid or declined,
+// so a charge repeated after a crash is recognised by iPay.
+func (s *Subscription) ExtID() string {
+	return ExtID(s.ID, s.Cycle, s.Attempt)
+}
+
+// ExtID is the ext_id of the attempt of the billing cycle of subscription id.
+func ExtID(id string, cycle, attempt int) string {
+	return fmt.Sprintf("%s-%d-%d", id, cycle, attempt)
+}
+
+// ParseExtID splits an ext_id made by ExtID. ok is false for any other ext_id.
+func ParseExtID(extID string) (id string, cycle, attempt int, ok bool) {
+	rest, attemptPart, found := cut(extID)
+	if !found {
+		return "", 0, 0, false
+	}
+	id, cyclePart, found := cut(rest)
+	if !found || id == "" {
+		return "", 0, 0, false
+	}
+
+	cycle, err := strconv.Atoi(cyclePart)
+	if err != nil || cycle < 0 {
+		return "", 0, 0, false
+	}
+	attempt, err = strconv.Atoi(attemptPart)
+	if err != nil || attempt < 0 {
+		return "", 0, 0, false
+	}
+
+	return id, cycle, attempt, true
+}
+
+func cut(s string) (string, string, bool) {
+	i := strings.LastIndexByte(s, '-')
+	if i < 0 {
+		return "", "", false
+	}
+
+	return s[:i], s[i+1:], true
+}
+
+// Store persists subscriptions. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save creates or replaces the subscription with the same ID.
+	Save(ctx context.Context, subscription Subscription) error
+	// Get returns the subscription or ErrNotFound.
+	Get(ctx context.Context, id string) (*Subscription, error)
+	// Due returns the active and past due subscriptions of the merchant whose NextChargeAt is not
+	// after t.
+	Due(ctx context.Context, merchantID string, t time.Time) ([]Subscription, error)
+}
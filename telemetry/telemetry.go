@@ -0,0 +1,116 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package telemetry defines the tracing and metrics hooks of the iPay client. The client only
+// depends on the interfaces below; adapters for OpenTelemetry or any other backend live in the
+// application, so the module does not pull a telemetry SDK into every build.
+package telemetry
+
+import (
+	"context"
+	"time"
+)
+
+// Attribute keys set on spans.
+const (
+	AttrOperation  = "ipay.operation"
+	AttrAction     = "ipay.action"
+	AttrMerchantID = "ipay.merchant_id"
+	AttrEndpoint   = "ipay.endpoint"
+	AttrAttempt    = "ipay.attempt"
+	AttrStatus     = "ipay.status"
+	AttrErrorType  = "ipay.error_type"
+	AttrHTTPStatus = "http.response.status_code"
+)
+
+// Span names. Operation spans are named SpanPrefix + the operation, e.g. "ipay.Payment".
+const (
+	SpanPrefix  = "ipay."
+	SpanAttempt = "ipay.http.attempt"
+)
+
+// Attribute is a key/value pair attached to a span. Value is a string, int, int64 or bool.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an int attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans. The context returned by Start must carry the span, so that spans started
+// from it become its children.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a unit of work started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Request describes one HTTP attempt sent to iPay.
+type Request struct {
+	Operation  string
+	Action     string
+	Endpoint   string
+	MerchantID string
+	Attempt    int
+	StatusCode int // HTTP status, 0 if no response arrived.
+	Duration   time.Duration
+	Err        error
+	// ErrorType classifies Err: the Type of an ipay.IpayError ("validation", "bank", "system"),
+	// "repayment" for repayment API errors and "transport" for everything else. Empty on success.
+	ErrorType string
+}
+
+// Metrics receives a Request after every HTTP attempt. A typical implementation feeds a
+// request counter, a duration histogram and an error counter keyed by ErrorType.
+type Metrics interface {
+	RecordRequest(ctx context.Context, r Request)
+}
+
+// Noop is a Tracer and Metrics that does nothing.
+type Noop struct{}
+
+func (Noop) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (Noop) RecordRequest(context.Context, Request) {}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
@@ -0,0 +1,128 @@
+package go_ipay
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/telemetry"
+)
+
+type spanKey struct{}
+
+type testSpan struct {
+	name   string
+	parent *testSpan
+	attrs  map[string]any
+	errs   []error
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...telemetry.Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) { s.errs = append(s.errs, err) }
+func (s *testSpan) End()                  { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...telemetry.Attribute) (context.Context, telemetry.Span) {
+	parent, _ := ctx.Value(spanKey{}).(*testSpan)
+	span := &testSpan{name: name, parent: parent, attrs: map[string]any{}}
+	span.SetAttributes(attrs...)
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type testMetrics struct {
+	requests []telemetry.Request
+}
+
+func (m *testMetrics) RecordRequest(_ context.Context, r telemetry.Request) {
+	m.requests = append(m.requests, r)
+}
+
+func TestTelemetry_SpanPerOperationAndAttempt(t *testing.T) {
+	var requestIDs []string
+	rt := flakyTransport(
+		[]*http.Response{teststand.Response(502, "text/html", []byte("bad gateway"))},
+		[]byte(`{"response":{"pmt_id":1,"status":5}}`),
+		&requestIDs,
+	)
+
+	tracer, metrics := &testTracer{}, &testMetrics{}
+	cl := NewClient(
+		WithClient(&http.Client{Transport: rt}),
+		WithRetryPolicy(testRetryPolicy),
+		WithTracer(tracer),
+		WithMetrics(metrics),
+	)
+
+	if _, err := cl.Status(paymentRequest()); err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("spans = %d, want 1 operation and 2 attempts", len(tracer.spans))
+	}
+
+	op := tracer.spans[0]
+	if op.name != "ipay.Status" || op.parent != nil || !op.ended {
+		t.Fatalf("operation span = %+v", op)
+	}
+	if op.attrs[telemetry.AttrMerchantID] != "1" || op.attrs[telemetry.AttrAction] != string(ipay.ActionGetPaymentStatus) {
+		t.Fatalf("operation attrs = %v", op.attrs)
+	}
+	if op.attrs[telemetry.AttrStatus] == nil {
+		t.Fatalf("operation span has no outcome status: %v", op.attrs)
+	}
+
+	for i, attempt := range tracer.spans[1:] {
+		if attempt.name != telemetry.SpanAttempt || attempt.parent != op || !attempt.ended {
+			t.Fatalf("attempt span %d = %+v", i, attempt)
+		}
+		if attempt.attrs[telemetry.AttrAttempt] != i+1 {
+			t.Fatalf("attempt span %d attempt = %v", i, attempt.attrs[telemetry.AttrAttempt])
+		}
+	}
+	if got := tracer.spans[1].attrs[telemetry.AttrHTTPStatus]; got != 502 {
+		t.Fatalf("first attempt status = %v, want 502", got)
+	}
+
+	if len(metrics.requests) != 2 {
+		t.Fatalf("metrics requests = %d, want 2", len(metrics.requests))
+	}
+	if r := metrics.requests[0]; r.ErrorType != ipay.ErrorTypeTransport || r.StatusCode != 502 || r.Operation != "Status" {
+		t.Fatalf("first request = %+v", r)
+	}
+	if r := metrics.requests[1]; r.Err != nil || r.ErrorType != "" || r.Attempt != 2 {
+		t.Fatalf("second request = %+v", r)
+	}
+}
+
+func TestTelemetry_ErrorTypeOfIpayError(t *testing.T) {
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return teststand.Response(200, "application/json", []byte(`{"response":{"error":"invalid sign","error_code":"604"}}`)), nil
+	})
+
+	metrics := &testMetrics{}
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithMetrics(metrics))
+
+	if _, err := cl.Payment(paymentRequest()); err == nil {
+		t.Fatal("Payment() error = nil, want an iPay error")
+	}
+	if len(metrics.requests) != 1 {
+		t.Fatalf("metrics requests = %d, want 1", len(metrics.requests))
+	}
+	if got := metrics.requests[0].ErrorType; got != ipay.ErrorTypeValidation {
+		t.Fatalf("error type = %q, want %q", got, ipay.ErrorTypeValidation)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+
+	"github.com/stremovskyy/go-ipay/consts"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// CreateToken opens a card entry page that saves the card without 3-D Secure. The card_token
+// arrives with the webhook of the payment, see the vault package for keeping it.
+func (c *client) CreateToken(request *Request, runOpts ...RunOption) (*ipay.TokenizationResult, error) {
+	return c.CreateTokenContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) CreateTokenContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.TokenizationResult, error) {
+	return c.tokenize(ctx, ipay.ActionCreateToken, consts.CreateToken, request, runOpts)
+}
+
+// CreateToken3DS is CreateToken with a 3-D Secure check of the card. It sends the same request as
+// VerificationLink.
+func (c *client) CreateToken3DS(request *Request, runOpts ...RunOption) (*ipay.TokenizationResult, error) {
+	return c.CreateToken3DSContext(context.Background(), request, runOpts...)
+}
+
+func (c *client) CreateToken3DSContext(ctx context.Context, request *Request, runOpts ...RunOption) (*ipay.TokenizationResult, error) {
+	return c.tokenize(ctx, ipay.ActionCreateToken3DS, consts.CreateToken3DS, request, runOpts)
+}
+
+func (c *client) tokenize(ctx context.Context, action ipay.Action, operation string, request *Request, runOpts []RunOption) (*ipay.TokenizationResult, error) {
+	response, err := c.createToken(ctx, action, operation, request, runOpts)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.TokenizationResult {
+		return &ipay.TokenizationResult{Result: r, URL: response.Url, Requested3DS: action == ipay.ActionCreateToken3DS}
+	})
+}
@@ -0,0 +1,578 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxExtIDLength is the longest ext_id iPay accepts.
+const maxExtIDLength = 50
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	// Field is the path of the field in the request, e.g. "PaymentMethod.Card.Pan".
+	Field   string
+	Message string
+	// Err is the underlying error, if any, e.g. currency.ErrUnknown.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned before anything is sent when a request fails client-side
+// validation. It lists every failing field, not just the first one.
+type ValidationError struct {
+	Operation string
+	Fields    []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Error()
+	}
+
+	return fmt.Sprintf("%s: invalid request: %s", e.Operation, strings.Join(messages, "; "))
+}
+
+// Unwrap returns the field errors, so errors.Is matches the errors they wrap.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f
+	}
+
+	return errs
+}
+
+// Field returns the error of the named field, or nil if it passed.
+func (e *ValidationError) Field(name string) *FieldError {
+	for _, f := range e.Fields {
+		if f.Field == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// AmountLimits bounds the amounts a client accepts, in minor units. A zero Max means no upper
+// limit; amounts must be positive regardless of Min.
+type AmountLimits struct {
+	Min int
+	Max int
+}
+
+// validator collects the field errors of one request.
+type validator struct {
+	limits AmountLimits
+	fields []*FieldError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.fields = append(v.fields, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) addErr(field string, err error) {
+	v.fields = append(v.fields, &FieldError{Field: field, Message: err.Error(), Err: err})
+}
+
+func (v *validator) err(operation string) error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Operation: operation, Fields: v.fields}
+}
+
+// merchant checks the credentials of the JSON and XML API.
+func (v *validator) merchant(m *Merchant) {
+	if m == nil {
+		v.addErr("Merchant", ErrMerchantIsNil)
+		return
+	}
+
+	if m.GetMerchantID() == nil {
+		v.add("Merchant.MerchantID", "must be numeric, got %q", m.MerchantID)
+	}
+
+	if m.MerchantKey == "" {
+		v.add("Merchant.MerchantKey", "is empty")
+	}
+}
+
+// mobileMerchant checks the credentials of the Apple Pay and Google Pay API.
+func (v *validator) mobileMerchant(m *Merchant) {
+	if m == nil {
+		v.addErr("Merchant", ErrMerchantIsNil)
+		return
+	}
+
+	if m.Login == "" {
+		v.add("Merchant.Login", "is empty")
+	}
+
+	if m.SystemKey == "" {
+		v.add("Merchant.SystemKey", "is empty")
+	}
+}
+
+// repaymentMerchant checks the credentials of the Repayment API.
+func (v *validator) repaymentMerchant(m *Merchant) {
+	if m == nil {
+		v.addErr("Merchant", ErrMerchantIsNil)
+		return
+	}
+
+	if m.Login == "" {
+		v.add("Merchant.Login", "is empty")
+	}
+
+	if m.RepaymentKey == "" {
+		v.add("Merchant.RepaymentKey", "is empty")
+	}
+}
+
+func (v *validator) amount(field string, amount int) {
+	switch {
+	case amount <= 0:
+		v.add(field, "must be positive, got %d", amount)
+	case amount < v.limits.Min:
+		v.add(field, "%d is below the minimum of %d", amount, v.limits.Min)
+	case v.limits.Max > 0 && amount > v.limits.Max:
+		v.add(field, "%d is above the maximum of %d", amount, v.limits.Max)
+	}
+}
+
+// splits checks that every part of a split payment is valid and that the parts add up to the
+// amount of the payment.
+func (v *validator) splits(r *Request) {
+	if !r.HasSplits() {
+		return
+	}
+
+	total := 0
+	for i, split := range r.PaymentData.Splits {
+		field := fmt.Sprintf("PaymentData.Splits[%d]", i)
+
+		if split.SubMerchantID <= 0 {
+			v.add(field+".SubMerchantID", "is empty")
+		}
+
+		if split.Invoice <= 0 {
+			v.add(field+".Invoice", "must be positive, got %d", split.Invoice)
+		}
+
+		if split.Amount != 0 && split.Amount < split.Invoice {
+			v.add(field+".Amount", "%d is below the invoice of %d", split.Amount, split.Invoice)
+		}
+
+		total += split.Invoice
+	}
+
+	if total != r.GetAmount() {
+		v.add("PaymentData.Splits", "invoices add up to %d, PaymentData.Amount is %d", total, r.GetAmount())
+	}
+}
+
+func (v *validator) extID(field string, extID *string) {
+	if extID != nil && len(*extID) > maxExtIDLength {
+		v.add(field, "is longer than %d characters", maxExtIDLength)
+	}
+}
+
+func (v *validator) url(field string, raw *string) {
+	if raw == nil || *raw == "" {
+		return
+	}
+
+	u, err := url.Parse(*raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "is not a valid http(s) URL")
+	}
+}
+
+func (v *validator) pan(field, pan string) {
+	if len(pan) < 12 || len(pan) > 19 || !isDigits(pan) {
+		v.add(field, "must be 12 to 19 digits")
+	} else if !luhn(pan) {
+		v.add(field, "fails the Luhn check")
+	}
+}
+
+func (v *validator) cardData(card *CardData) {
+	if card == nil {
+		return
+	}
+
+	v.pan("PaymentMethod.CardData.Pan", card.Pan)
+
+	if card.ExpMonth < 1 || card.ExpMonth > 12 {
+		v.add("PaymentMethod.CardData.ExpMonth", "must be between 1 and 12, got %d", card.ExpMonth)
+	}
+
+	if card.ExpYear < 0 || card.ExpYear > 9999 {
+		v.add("PaymentMethod.CardData.ExpYear", "must have two or four digits, got %d", card.ExpYear)
+	}
+
+	if card.Cvv != "" && (len(card.Cvv) < 3 || len(card.Cvv) > 4 || !isDigits(card.Cvv)) {
+		v.add("PaymentMethod.CardData.Cvv", "must be 3 or 4 digits")
+	}
+}
+
+func (v *validator) taxID(personal *PersonalData) {
+	if personal == nil || personal.TaxID == nil || *personal.TaxID == "" {
+		return
+	}
+
+	if !validRNOKPP(*personal.TaxID) {
+		v.add("PersonalData.TaxID", "is not a valid RNOKPP")
+	}
+}
+
+// party checks the format of the tax ID and phone of a sender or receiver.
+func (v *validator) party(field string, p *Party) {
+	if p == nil {
+		return
+	}
+
+	if p.TaxID != nil && *p.TaxID != "" && !validRNOKPP(*p.TaxID) {
+		v.add(field+".TaxID", "is not a valid RNOKPP")
+	}
+
+	if p.Phone != nil && *p.Phone != "" {
+		digits := strings.TrimPrefix(*p.Phone, "+")
+		if len(digits) < 10 || len(digits) > 15 || !isDigits(digits) {
+			v.add(field+".Phone", "is not an international phone number")
+		}
+	}
+}
+
+// monitoring checks the parties of a Credit transfer. Financial monitoring needs the full name of
+// the receiver and, when the transfer is made on behalf of a sender, the full name of the sender
+// and a document, tax ID or account number identifying them.
+func (v *validator) monitoring(r *Request) {
+	v.party("Sender", r.Sender)
+	v.party("Receiver", r.Receiver)
+
+	if r.Sender != nil {
+		v.name("Sender", r.Sender)
+
+		if empty(r.Sender.Document) && empty(r.Sender.TaxID) && empty(r.Sender.AccountNumber) {
+			v.add("Sender", "needs a Document, TaxID or AccountNumber")
+		}
+	}
+
+	switch {
+	case r.Receiver != nil:
+		v.name("Receiver", r.Receiver)
+	case r.Sender != nil:
+		if r.PersonalData == nil {
+			v.add("Receiver", "is required when a Sender is set")
+		} else {
+			v.name("PersonalData", r.PersonalData.party())
+		}
+	}
+}
+
+func (v *validator) name(field string, p *Party) {
+	if empty(p.FirstName) {
+		v.add(field+".FirstName", "is empty")
+	}
+	if empty(p.LastName) {
+		v.add(field+".LastName", "is empty")
+	}
+}
+
+func empty(s *string) bool {
+	return s == nil || strings.TrimSpace(*s) == ""
+}
+
+func (v *validator) appleContainer(container *string) {
+	var data struct {
+		Token json.RawMessage `json:"token"`
+	}
+
+	if err := decodeContainer(container, &data); err != nil {
+		v.add("PaymentMethod.AppleContainer", "%v", err)
+	} else if len(data.Token) == 0 || string(data.Token) == "null" {
+		v.add("PaymentMethod.AppleContainer", "has no token")
+	}
+}
+
+func (v *validator) googleToken(token *string) {
+	var data struct {
+		PaymentMethodData struct {
+			TokenizationData struct {
+				Token string `json:"token"`
+			} `json:"tokenizationData"`
+		} `json:"paymentMethodData"`
+	}
+
+	if err := decodeContainer(token, &data); err != nil {
+		v.add("PaymentMethod.GoogleToken", "%v", err)
+	} else if data.PaymentMethodData.TokenizationData.Token == "" {
+		v.add("PaymentMethod.GoogleToken", "has no paymentMethodData.tokenizationData.token")
+	}
+}
+
+func decodeContainer(raw *string, into any) error {
+	if raw == nil || *raw == "" {
+		return fmt.Errorf("is empty")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(*raw)
+	if err != nil {
+		return fmt.Errorf("is not valid base64")
+	}
+
+	if err := json.Unmarshal(decoded, into); err != nil {
+		return fmt.Errorf("is not a JSON object")
+	}
+
+	return nil
+}
+
+// luhn reports whether the digits pass the Luhn checksum used by card numbers.
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// rnokppWeights are the weights of the first nine digits of a Ukrainian individual tax number.
+var rnokppWeights = [9]int{-1, 5, 7, 9, 4, 6, 10, 5, 7}
+
+// validRNOKPP reports whether s is a ten-digit RNOKPP with a valid check digit.
+func validRNOKPP(s string) bool {
+	if len(s) != 10 || !isDigits(s) {
+		return false
+	}
+
+	sum := 0
+	for i, w := range rnokppWeights {
+		sum += int(s[i]-'0') * w
+	}
+
+	return ((sum%11)+11)%11%10 == int(s[9]-'0')
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// validate runs check on a fresh validator unless validation is turned off for the client.
+func (c *client) validate(operation string, check func(v *validator)) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{limits: c.amountLimits}
+	check(v)
+
+	return v.err(operation)
+}
+
+// currency rejects unknown currency codes and codes the merchant may not charge in. An empty
+// currency is left to iPay, which defaults to UAH.
+func (v *validator) currency(r *Request) {
+	code := r.GetCurrency()
+	if code == "" {
+		return
+	}
+
+	if err := code.Validate(); err != nil {
+		v.addErr("PaymentData.Currency", err)
+	} else if r.Merchant != nil && !r.Merchant.Currencies.Allows(code) {
+		v.addErr("PaymentData.Currency", fmt.Errorf("%w: %s", ErrCurrencyNotAllowed, code))
+	}
+}
+
+// payment checks Payment and Hold, for both the card and the mobile API.
+func (v *validator) payment(r *Request) {
+	if r.IsMobile() {
+		v.mobileMerchant(r.Merchant)
+	} else {
+		v.merchant(r.Merchant)
+	}
+
+	v.amount("PaymentData.Amount", r.GetAmount())
+	v.splits(r)
+	v.currency(r)
+	v.extID("PaymentData.PaymentID", r.GetPaymentID())
+	v.url("PaymentData.WebhookURL", r.GetWebhookURL())
+	v.taxID(r.PersonalData)
+	v.party("Sender", r.Sender)
+	v.party("Receiver", r.Receiver)
+
+	switch {
+	case r.HasRecurrent():
+	case r.IsApplePay():
+		v.appleContainer(r.PaymentMethod.AppleContainer)
+	case r.IsGooglePay():
+		v.googleToken(r.PaymentMethod.GoogleToken)
+	case r.IsMobile():
+		v.add("PaymentMethod", "mobile payments need an AppleContainer or a GoogleToken")
+	case r.HasCardData():
+		v.cardData(r.PaymentMethod.CardData)
+	default:
+		if token := r.GetCardToken(); token == nil || *token == "" {
+			v.add("PaymentMethod.Card.Token", "is empty")
+		}
+	}
+}
+
+func (v *validator) paymentURL(r *Request) {
+	v.merchant(r.Merchant)
+	v.amount("PaymentData.Amount", r.GetAmount())
+	v.currency(r)
+	v.extID("PaymentData.PaymentID", r.GetPaymentID())
+	v.redirects(r)
+	v.taxID(r.PersonalData)
+	v.party("Sender", r.Sender)
+	v.party("Receiver", r.Receiver)
+
+	if r.HasCardData() {
+		v.cardData(r.PaymentMethod.CardData)
+	}
+}
+
+func (v *validator) verification(r *Request) {
+	v.merchant(r.Merchant)
+	v.extID("PaymentData.PaymentID", r.GetPaymentID())
+	v.url("PaymentData.WebhookURL", r.GetWebhookURL())
+	v.redirects(r)
+	v.taxID(r.PersonalData)
+	v.party("Sender", r.Sender)
+	v.party("Receiver", r.Receiver)
+
+	if r.VerifiesWithAmount() {
+		v.amount("PaymentData.Amount", r.GetAmount())
+	}
+
+	if r.HasCardData() {
+		v.cardData(r.PaymentMethod.CardData)
+	}
+}
+
+func (v *validator) redirects(r *Request) {
+	if r.Merchant == nil {
+		return
+	}
+
+	v.url("Merchant.SuccessRedirect", &r.Merchant.SuccessRedirect)
+	v.url("Merchant.FailRedirect", &r.Merchant.FailRedirect)
+}
+
+// lookup checks the calls that find a payment by pmt_id or ext_id. Status prefers pmt_id, while
+// A2CPaymentStatus takes exactly one of them.
+func (v *validator) lookup(r *Request, exactlyOne bool) {
+	v.merchant(r.Merchant)
+
+	extID := r.GetPaymentID()
+	hasExtID := extID != nil && *extID != ""
+	hasPmtID := r.GetIpayPaymentID() != 0
+
+	switch {
+	case !hasExtID && !hasPmtID:
+		v.add("PaymentData", "either IpayPaymentID (pmt_id) or PaymentID (ext_id) must be provided")
+	case exactlyOne && hasExtID && hasPmtID:
+		v.add("PaymentData", "only one of IpayPaymentID (pmt_id) or PaymentID (ext_id) must be provided")
+	}
+
+	v.extID("PaymentData.PaymentID", extID)
+}
+
+// capture checks Capture and, with requireAmount false, Refund, where an amount of 0 reverses
+// the whole balance. Splits of either must add up to the amount.
+func (v *validator) capture(r *Request, requireAmount bool) {
+	v.merchant(r.Merchant)
+
+	if r.GetIpayPaymentID() == 0 {
+		v.add("PaymentData.IpayPaymentID", "is empty")
+	}
+
+	if requireAmount || r.GetAmount() != 0 {
+		v.amount("PaymentData.Amount", r.GetAmount())
+	}
+
+	v.splits(r)
+
+	v.url("PaymentData.WebhookURL", r.GetWebhookURL())
+}
+
+func (v *validator) credit(r *Request) {
+	v.merchant(r.Merchant)
+	v.amount("PaymentData.Amount", r.GetAmount())
+	v.extID("PaymentData.PaymentID", r.GetPaymentID())
+	v.url("PaymentData.WebhookURL", r.GetWebhookURL())
+	v.taxID(r.PersonalData)
+	v.monitoring(r)
+
+	if token := r.GetCardToken(); token != nil && *token != "" {
+		return
+	}
+
+	if pan := r.GetCardPan(); pan != nil {
+		v.pan("PaymentMethod.Card.Pan", *pan)
+	} else {
+		v.add("PaymentMethod.Card", "needs a Token or a Pan")
+	}
+}
+
+// repayment checks the Repayment API calls; extID is the ext_id of the repayment, if any.
+func (v *validator) repayment(m *Merchant, extID *string) {
+	v.repaymentMerchant(m)
+	v.extID("ExtID", extID)
+}
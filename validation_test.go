@@ -0,0 +1,184 @@
+package go_ipay
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+)
+
+// unreachable fails the test if a request reaches the transport.
+func unreachable(t *testing.T) *http.Client {
+	return &http.Client{Transport: teststand.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("request was sent")
+		return nil, nil
+	})}
+}
+
+func validationFields(t *testing.T, err error) []string {
+	t.Helper()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want a *ValidationError", err)
+	}
+
+	fields := make([]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		fields[i] = f.Field
+	}
+
+	return fields
+}
+
+func TestLuhnAndRNOKPP(t *testing.T) {
+	for pan, want := range map[string]bool{"4111111111111111": true, "4444333322221111": true, "4111111111111112": false} {
+		if got := luhn(pan); got != want {
+			t.Fatalf("luhn(%q) = %v, want %v", pan, got, want)
+		}
+	}
+
+	for taxID, want := range map[string]bool{"1234567899": true, "1234567890": false, "123456789": false, "12345678AB": false} {
+		if got := validRNOKPP(taxID); got != want {
+			t.Fatalf("validRNOKPP(%q) = %v, want %v", taxID, got, want)
+		}
+	}
+}
+
+func TestPayment_ValidationListsEveryField(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)))
+
+	request := &Request{
+		Merchant:     &Merchant{MerchantID: "abc"},
+		PaymentData:  &PaymentData{Amount: 0, WebhookURL: utils.Ref("not a url")},
+		PersonalData: &PersonalData{TaxID: utils.Ref("1234567890")},
+		PaymentMethod: &PaymentMethod{CardData: &CardData{
+			Pan: "4111111111111112", ExpMonth: 13, ExpYear: 27, Cvv: "12",
+		}},
+	}
+
+	_, err := cl.Payment(request)
+
+	want := []string{
+		"Merchant.MerchantID", "Merchant.MerchantKey", "PaymentData.Amount", "PaymentData.WebhookURL",
+		"PersonalData.TaxID", "PaymentMethod.CardData.Pan", "PaymentMethod.CardData.ExpMonth",
+		"PaymentMethod.CardData.Cvv",
+	}
+	got := validationFields(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("fields = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPayment_ValidatesMobileContainers(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)))
+
+	tests := []struct {
+		name      string
+		container string
+	}{
+		{name: "not base64", container: "%%%"},
+		{name: "not JSON", container: base64.StdEncoding.EncodeToString([]byte("nope"))},
+		{name: "no token", container: base64.StdEncoding.EncodeToString([]byte(`{"paymentData":{}}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := paymentRequest()
+			request.Merchant.Login = "login"
+			request.Merchant.SystemKey = "system"
+			request.PaymentMethod = &PaymentMethod{AppleContainer: utils.Ref(tt.container)}
+
+			_, err := cl.Payment(request)
+			if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "PaymentMethod.AppleContainer" {
+				t.Fatalf("fields = %v, want [PaymentMethod.AppleContainer]", fields)
+			}
+		})
+	}
+}
+
+func TestWithAmountLimits(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)), WithAmountLimits(100, 5000))
+
+	request := paymentRequest()
+	request.PaymentData.Amount = 5001
+
+	_, err := cl.Hold(request)
+	if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "PaymentData.Amount" {
+		t.Fatalf("fields = %v, want [PaymentData.Amount]", fields)
+	}
+}
+
+func TestVerification_WithAmountNeedsAmount(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)))
+
+	request := paymentRequest()
+	request.PaymentData.Amount = 0
+
+	if _, err := cl.VerificationLink(request, DryRun(func(string, any) {})); err != nil {
+		t.Fatalf("VerificationLink() error: %v", err)
+	}
+
+	request.PaymentData.VerifyWithAmount = true
+	_, err := cl.VerificationLink(request)
+	if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "PaymentData.Amount" {
+		t.Fatalf("fields = %v, want [PaymentData.Amount]", fields)
+	}
+}
+
+func TestWithoutValidation(t *testing.T) {
+	cl := NewClient(WithClient(jsonTransport(`{"response":{"pmt_id":1,"status":5}}`)), WithoutValidation())
+
+	request := paymentRequest()
+	request.PaymentData.Amount = 0
+
+	if _, err := cl.Payment(request); err != nil {
+		t.Fatalf("Payment() error: %v", err)
+	}
+}
+
+func TestRepayment_ValidatesCredentials(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)))
+
+	_, err := cl.GetRepaymentStatus(&GetRepaymentStatusRequest{
+		Merchant: &Merchant{Login: "login"},
+		ExtID:    utils.Ref("ext"),
+	})
+	if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "Merchant.RepaymentKey" {
+		t.Fatalf("fields = %v, want [Merchant.RepaymentKey]", fields)
+	}
+}
+
+func TestCredit_ValidatesParties(t *testing.T) {
+	cl := NewClient(WithClient(unreachable(t)))
+
+	request := paymentRequest()
+	request.Sender = &Party{FirstName: utils.Ref("Ivan"), TaxID: utils.Ref("1234567890")}
+	request.PersonalData = &PersonalData{LastName: utils.Ref("Shevchenko")}
+
+	_, err := cl.Credit(request)
+
+	want := []string{"Sender.TaxID", "Sender.LastName", "PersonalData.FirstName"}
+	if got := validationFields(t, err); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+
+	request.Sender = &Party{FirstName: utils.Ref("Ivan"), LastName: utils.Ref("Petrenko")}
+	request.Receiver = &Party{FirstName: utils.Ref("Olena"), LastName: utils.Ref("Shevchenko"), Phone: utils.Ref("050")}
+
+	_, err = cl.Credit(request)
+
+	want = []string{"Receiver.Phone", "Sender"}
+	if got := validationFields(t, err); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+}
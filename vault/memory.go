@@ -0,0 +1,89 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package vault
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps cards in process memory, so they are lost on restart. Use a persistent Store
+// in production.
+type MemoryStore struct {
+	mu    sync.Mutex
+	cards map[string]map[string]Card
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{cards: make(map[string]map[string]Card)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, card Card) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cards[card.UserID] == nil {
+		s.cards[card.UserID] = make(map[string]Card)
+	}
+	s.cards[card.UserID][card.Token] = card
+
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(_ context.Context, userID string) ([]Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cards := make([]Card, 0, len(s.cards[userID]))
+	for _, card := range s.cards[userID] {
+		cards = append(cards, card)
+	}
+
+	sort.Slice(cards, func(i, j int) bool {
+		if !cards[i].CreatedAt.Equal(cards[j].CreatedAt) {
+			return cards[i].CreatedAt.Before(cards[j].CreatedAt)
+		}
+		return cards[i].Token < cards[j].Token
+	})
+
+	return cards, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, userID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cards[userID][token]; !ok {
+		return ErrNotFound
+	}
+	delete(s.cards[userID], token)
+
+	return nil
+}
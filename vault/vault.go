@@ -0,0 +1,262 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package vault keeps the card tokens of your users: it records the card of a tokenization or
+// payment webhook, lists and removes a user's cards and picks the default card to charge.
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+var (
+	// ErrNotFound is returned when the user has no card with the token, or no cards at all.
+	ErrNotFound = errors.New("vault: card not found")
+	// ErrNoToken is returned by Save for a payment that carries no card_token, e.g. a declined one.
+	ErrNoToken = errors.New("vault: payment has no card token")
+	// ErrNotSuccessful is returned by Save for a payment that did not succeed, even if it carries
+	// a card_token.
+	ErrNotSuccessful = errors.New("vault: payment did not succeed")
+)
+
+// Card is a saved card of a user.
+type Card struct {
+	// Token is the iPay card_token the card is charged with.
+	Token  string
+	UserID string
+	// Mask is the masked card number, e.g. 444433******1111.
+	Mask string
+	Bank string
+	// Type is the card type reported by iPay, e.g. VISA or MasterCard.
+	Type    string
+	Holder  string
+	Prepaid bool
+	// Default marks the card charged when no card is chosen; each user with cards has one.
+	Default   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store persists cards. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save creates or replaces the card with the same UserID and Token.
+	Save(ctx context.Context, card Card) error
+	// List returns the cards of the user, oldest first.
+	List(ctx context.Context, userID string) ([]Card, error)
+	// Delete removes the card of the user or returns ErrNotFound.
+	Delete(ctx context.Context, userID, token string) error
+}
+
+// Vault manages the saved cards of users on top of a Store.
+type Vault struct {
+	store Store
+	now   func() time.Time
+}
+
+// New creates a Vault keeping cards in store.
+func New(store Store) *Vault {
+	return &Vault{store: store, now: time.Now}
+}
+
+// Save records the card of a verified webhook, sent after CreateToken, CreateToken3DS,
+// VerificationLink or a payment, for userID. Only payments that succeeded or hold the funds are
+// saved. A card already saved is updated with the new details. The first card of a user becomes
+// the default.
+func (v *Vault) Save(ctx context.Context, userID string, payment *ipay.Payment) (*Card, error) {
+	if payment == nil || utils.Deref(payment.CardToken) == "" {
+		return nil, ErrNoToken
+	}
+
+	switch payment.Status {
+	case ipay.PaymentStatusSuccess, ipay.PaymentStatusSuccessWithoutClaim, ipay.PaymentStatusPreAuthorized:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotSuccessful, payment.Status.String())
+	}
+
+	cards, err := v.store.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("vault: list cards of %s: %w", userID, err)
+	}
+
+	now := v.now()
+	card := Card{
+		Token:     *payment.CardToken,
+		UserID:    userID,
+		Default:   len(cards) == 0,
+		CreatedAt: now,
+	}
+
+	for _, saved := range cards {
+		if saved.Token == card.Token {
+			card = saved
+		}
+	}
+
+	card.Mask = utils.Deref(payment.CardMask)
+	card.Bank = utils.Deref(payment.BankName)
+	card.Type = utils.Deref(payment.CardType)
+	card.Holder = payment.CardHolder
+	card.Prepaid = payment.CardIsPrepaid == "1"
+
+	return v.save(ctx, card)
+}
+
+// Cards returns the cards of the user, oldest first.
+func (v *Vault) Cards(ctx context.Context, userID string) ([]Card, error) {
+	cards, err := v.store.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("vault: list cards of %s: %w", userID, err)
+	}
+
+	return cards, nil
+}
+
+// Default returns the default card of the user, or ErrNotFound when there is none.
+func (v *Vault) Default(ctx context.Context, userID string) (*Card, error) {
+	cards, err := v.Cards(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	card := defaultCard(cards)
+	if card == nil {
+		return nil, fmt.Errorf("%w: %s has no cards", ErrNotFound, userID)
+	}
+
+	return card, nil
+}
+
+// SetDefault makes the card the default of the user.
+func (v *Vault) SetDefault(ctx context.Context, userID, token string) (*Card, error) {
+	cards, err := v.Cards(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var chosen *Card
+	for i := range cards {
+		if cards[i].Token == token {
+			chosen = &cards[i]
+		}
+	}
+	if chosen == nil {
+		return nil, fmt.Errorf("%w: %s of %s", ErrNotFound, token, userID)
+	}
+
+	for i := range cards {
+		if cards[i].Default && cards[i].Token != token {
+			cards[i].Default = false
+			if _, err := v.save(ctx, cards[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	chosen.Default = true
+
+	return v.save(ctx, *chosen)
+}
+
+// Remove deletes the card of the user. When it was the default, the most recently added of the
+// remaining cards takes its place.
+func (v *Vault) Remove(ctx context.Context, userID, token string) error {
+	if err := v.store.Delete(ctx, userID, token); err != nil {
+		return fmt.Errorf("vault: delete %s of %s: %w", token, userID, err)
+	}
+
+	cards, err := v.Cards(ctx, userID)
+	if err != nil || len(cards) == 0 {
+		return err
+	}
+
+	for _, card := range cards {
+		if card.Default {
+			return nil
+		}
+	}
+
+	newest := cards[len(cards)-1]
+	newest.Default = true
+	_, err = v.save(ctx, newest)
+
+	return err
+}
+
+// PaymentMethod returns the payment method that charges the card of the user, or the default card
+// when token is empty.
+func (v *Vault) PaymentMethod(ctx context.Context, userID, token string) (*go_ipay.PaymentMethod, error) {
+	cards, err := v.Cards(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var card *Card
+	if token == "" {
+		card = defaultCard(cards)
+	} else {
+		for i := range cards {
+			if cards[i].Token == token {
+				card = &cards[i]
+			}
+		}
+	}
+	if card == nil {
+		return nil, fmt.Errorf("%w: no card to charge for %s", ErrNotFound, userID)
+	}
+
+	return &go_ipay.PaymentMethod{Card: &go_ipay.Card{Name: card.Holder, Token: utils.Ref(card.Token)}}, nil
+}
+
+// defaultCard returns the card marked default, falling back to the newest card if a Store lost
+// the mark.
+func defaultCard(cards []Card) *Card {
+	for i := range cards {
+		if cards[i].Default {
+			return &cards[i]
+		}
+	}
+
+	if len(cards) == 0 {
+		return nil
+	}
+
+	return &cards[len(cards)-1]
+}
+
+func (v *Vault) save(ctx context.Context, card Card) (*Card, error) {
+	card.UpdatedAt = v.now()
+
+	if err := v.store.Save(ctx, card); err != nil {
+		return nil, fmt.Errorf("vault: save %s of %s: %w", card.Token, card.UserID, err)
+	}
+
+	return &card, nil
+}
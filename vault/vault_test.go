@@ -0,0 +1,134 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/ipaytest"
+)
+
+var merchant = &go_ipay.Merchant{MerchantID: "1", MerchantKey: "key"}
+
+func newVault() (*Vault, *time.Time) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	v := New(NewMemoryStore())
+	v.now = func() time.Time { return now }
+
+	return v, &now
+}
+
+// tokenize runs CreateToken against the fake and returns the webhook payment of the saved card.
+func tokenize(t *testing.T, cl go_ipay.Ipay, fake *ipaytest.Fake, extID, pan string) *ipay.Payment {
+	t.Helper()
+
+	result, err := cl.CreateToken(&go_ipay.Request{
+		Merchant:    merchant,
+		PaymentData: &go_ipay.PaymentData{PaymentID: utils.Ref(extID)},
+	})
+	if err != nil {
+		t.Fatalf("CreateToken() error: %v", err)
+	}
+	if result.URL == "" || result.Requested3DS {
+		t.Fatalf("CreateToken() = %+v", result)
+	}
+
+	p, err := fake.Complete(result.PaymentID, pan)
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	return &ipay.Payment{
+		PmtId:     int(p.ID),
+		ExtID:     &p.ExtID,
+		Status:    p.Status,
+		CardToken: &p.CardToken,
+		CardMask:  &p.CardMask,
+		BankName:  utils.Ref("Test Bank"),
+	}
+}
+
+func TestVault_SaveAndDefault(t *testing.T) {
+	ctx := t.Context()
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+	v, now := newVault()
+
+	first, err := v.Save(ctx, "user-1", tokenize(t, cl, fake, "card-1", "3333333333333331"))
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if !first.Default || first.Mask == "" || first.Bank != "Test Bank" {
+		t.Fatalf("Save() = %+v, want the default card with its details", first)
+	}
+
+	*now = now.Add(time.Minute)
+	second, err := v.Save(ctx, "user-1", tokenize(t, cl, fake, "card-2", "3333333333332705"))
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if second.Default {
+		t.Fatal("the second card became the default")
+	}
+
+	if cards, _ := v.Cards(ctx, "user-1"); len(cards) != 2 || cards[0].Token != first.Token {
+		t.Fatalf("Cards() = %+v", cards)
+	}
+
+	if _, err := v.SetDefault(ctx, "user-1", second.Token); err != nil {
+		t.Fatalf("SetDefault() error: %v", err)
+	}
+	method, err := v.PaymentMethod(ctx, "user-1", "")
+	if err != nil || *method.Card.Token != second.Token {
+		t.Fatalf("PaymentMethod() = %+v, %v, want the new default", method, err)
+	}
+
+	if err := v.Remove(ctx, "user-1", second.Token); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if card, err := v.Default(ctx, "user-1"); err != nil || card.Token != first.Token {
+		t.Fatalf("Default() = %+v, %v, want the remaining card", card, err)
+	}
+
+	if err := v.Remove(ctx, "user-1", first.Token); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if _, err := v.Default(ctx, "user-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Default() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestVault_SaveUpdatesKnownCard(t *testing.T) {
+	ctx := t.Context()
+	v, _ := newVault()
+
+	payment := &ipay.Payment{Status: ipay.PaymentStatusSuccess, CardToken: utils.Ref("tok"), CardMask: utils.Ref("444433******1111")}
+	if _, err := v.Save(ctx, "user-1", payment); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	payment.CardType = utils.Ref("VISA")
+	card, err := v.Save(ctx, "user-1", payment)
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if !card.Default || card.Type != "VISA" {
+		t.Fatalf("Save() = %+v, want the default card updated", card)
+	}
+	if cards, _ := v.Cards(ctx, "user-1"); len(cards) != 1 {
+		t.Fatalf("Cards() = %+v, want one card", cards)
+	}
+
+	if _, err := v.Save(ctx, "user-1", &ipay.Payment{Status: ipay.PaymentStatusFailed}); !errors.Is(err, ErrNoToken) {
+		t.Fatalf("Save() error = %v, want %v", err, ErrNoToken)
+	}
+	if _, err := v.Save(ctx, "user-2", &ipay.Payment{Status: ipay.PaymentStatusFailed, CardToken: utils.Ref("declined")}); !errors.Is(err, ErrNotSuccessful) {
+		t.Fatalf("Save() error = %v, want %v", err, ErrNotSuccessful)
+	}
+	if _, err := v.PaymentMethod(ctx, "user-2", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("PaymentMethod() error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,292 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// Manager starts verifications, records them in a Store and settles them from their webhook or
+// Status, reversing the charge of a verification with an amount. Sessions are settled with the
+// merchant they were started with.
+type Manager struct {
+	client   go_ipay.Ipay
+	merchant *go_ipay.Merchant
+	store    Store
+	now      func() time.Time
+
+	mu        sync.Mutex
+	merchants map[string]*go_ipay.Merchant
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithMerchants registers the merchants of sessions started by another process, e.g. before a
+// restart. Start registers the merchant of each request it is given.
+func WithMerchants(merchants ...*go_ipay.Merchant) Option {
+	return func(m *Manager) {
+		for _, merchant := range merchants {
+			m.remember(merchant)
+		}
+	}
+}
+
+// NewManager creates a Manager for the verifications of merchant.
+func NewManager(client go_ipay.Ipay, merchant *go_ipay.Merchant, store Store, opts ...Option) *Manager {
+	m := &Manager{
+		client:    client,
+		merchant:  merchant,
+		store:     store,
+		now:       time.Now,
+		merchants: make(map[string]*go_ipay.Merchant),
+	}
+	m.remember(merchant)
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+
+	return m
+}
+
+// Start creates the verification page and records the session under the ext_id of the request,
+// which is required. Set PaymentData.VerifyWithAmount and Amount to verify the card with a charge.
+// The merchant of the manager is used when the request has none. A dry run returns nil, nil.
+func (m *Manager) Start(ctx context.Context, request *go_ipay.Request, runOpts ...go_ipay.RunOption) (*Session, error) {
+	if request == nil {
+		return nil, go_ipay.ErrRequestIsNil
+	}
+
+	extID := utils.Deref(request.GetPaymentID())
+	if extID == "" {
+		return nil, fmt.Errorf("verification: PaymentData.PaymentID is required to track the session")
+	}
+	if request.Merchant == nil {
+		request.Merchant = m.merchant
+	}
+
+	result, err := m.client.VerificationResultContext(ctx, request, runOpts...)
+	if err != nil || result == nil {
+		return nil, err
+	}
+
+	m.remember(request.Merchant)
+
+	now := m.now()
+	s := &Session{
+		ExtID:      extID,
+		PaymentID:  result.PaymentID,
+		MerchantID: request.Merchant.MerchantID,
+		URL:        result.URL,
+		State:      StatePending,
+		WithAmount: request.VerifiesWithAmount(),
+		CreatedAt:  now,
+	}
+	if s.WithAmount {
+		s.Amount = request.GetMoney()
+	}
+
+	return m.save(ctx, s)
+}
+
+// HandleWebhook settles the session of a verified webhook. It returns ErrNotFound when the payment
+// is not a tracked verification.
+func (m *Manager) HandleWebhook(ctx context.Context, payment *ipay.Payment) (*Session, error) {
+	if payment == nil {
+		return nil, ErrNotFound
+	}
+
+	s, err := m.store.Get(ctx, utils.Deref(payment.ExtID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := ipay.Response{Pmt: payment}.Result()
+	result.Status = payment.Status
+
+	return m.settle(ctx, s, &result)
+}
+
+// Resolve settles a pending session with Status, e.g. when the customer is back from the 3-D
+// Secure redirect before the webhook arrived. It also repeats a reversal that failed before.
+func (m *Manager) Resolve(ctx context.Context, extID string) (*Session, error) {
+	s, err := m.store.Get(ctx, extID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.State != StatePending {
+		return m.settle(ctx, s, nil)
+	}
+
+	request, err := m.request(s)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := m.client.StatusResultContext(ctx, request)
+	if status == nil {
+		return nil, fmt.Errorf("verification: status of %s: %w", extID, err)
+	}
+
+	return m.settle(ctx, s, &status.Result)
+}
+
+// settle records the outcome of a pending session once result has a final status, and reverses
+// the charge of a passed verification with an amount. Notifications that arrive after the
+// outcome, such as the one of the reversal, do not change it.
+func (m *Manager) settle(ctx context.Context, s *Session, result *ipay.Result) (*Session, error) {
+	if s.State == StatePending && result != nil && result.Status.IsFinal() {
+		if result.PaymentID != 0 {
+			s.PaymentID = result.PaymentID
+		}
+
+		s.Result = &Result{
+			Status:     result.Status,
+			CardToken:  result.CardToken,
+			CardMask:   result.CardMask,
+			CardType:   result.CardType,
+			Bank:       result.Bank.Name,
+			Use3DS:     result.Use3DS,
+			ValidTaxID: result.ValidTaxID,
+			BankError:  result.Bank.ErrorCode,
+		}
+
+		switch result.Status {
+		case ipay.PaymentStatusSuccess, ipay.PaymentStatusPreAuthorized, ipay.PaymentStatusSuccessWithoutClaim:
+			s.State = StateVerified
+		default:
+			s.State = StateFailed
+		}
+	}
+
+	var reverseErr error
+	if s.WithAmount && s.State == StateVerified && !s.Reversed {
+		if err := m.reverse(ctx, s); err != nil {
+			s.LastError = err.Error()
+			reverseErr = fmt.Errorf("verification: reverse %s: %w", s.ExtID, err)
+		} else {
+			s.Reversed = true
+			s.LastError = ""
+		}
+	}
+
+	if _, err := m.save(ctx, s); err != nil {
+		return nil, err
+	}
+
+	return s, reverseErr
+}
+
+func (m *Manager) save(ctx context.Context, s *Session) (*Session, error) {
+	s.UpdatedAt = m.now()
+
+	if err := m.store.Save(ctx, *s); err != nil {
+		return nil, fmt.Errorf("verification: save %s: %w", s.ExtID, err)
+	}
+
+	return s, nil
+}
+
+// reverse returns the charge of a verified session. Refund needs the pmt_id, so a session that
+// only has its ext_id, e.g. one settled from a notification without pmt_id, looks it up first.
+func (m *Manager) reverse(ctx context.Context, s *Session) error {
+	request, err := m.request(s)
+	if err != nil {
+		return err
+	}
+
+	if s.PaymentID == 0 {
+		status, err := m.client.StatusResultContext(ctx, request)
+		if err != nil {
+			return fmt.Errorf("look up pmt_id: %w", err)
+		}
+		if status.PaymentID == 0 {
+			return errors.New("status lookup returned no pmt_id")
+		}
+
+		s.PaymentID = status.PaymentID
+		request.PaymentData = &go_ipay.PaymentData{IpayPaymentID: utils.Ref(s.PaymentID)}
+	}
+
+	_, err = m.client.RefundResultContext(ctx, request)
+
+	return err
+}
+
+// request addresses the payment of s, by pmt_id when known, with the merchant it was started with.
+func (m *Manager) request(s *Session) (*go_ipay.Request, error) {
+	merchant, err := m.merchantOf(s)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &go_ipay.PaymentData{IpayPaymentID: utils.Ref(s.PaymentID)}
+	if s.PaymentID == 0 {
+		data = &go_ipay.PaymentData{PaymentID: utils.Ref(s.ExtID)}
+	}
+
+	return &go_ipay.Request{Merchant: merchant, PaymentData: data}, nil
+}
+
+func (m *Manager) remember(merchant *go_ipay.Merchant) {
+	if merchant == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.merchants[merchant.MerchantID] = merchant
+}
+
+// merchantOf returns the merchant s was started with. Sessions without a MerchantID belong to the
+// merchant of the manager.
+func (m *Manager) merchantOf(s *Session) (*go_ipay.Merchant, error) {
+	if s.MerchantID == "" {
+		return m.merchant, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	merchant, ok := m.merchants[s.MerchantID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMerchant, s.MerchantID)
+	}
+
+	return merchant, nil
+}
@@ -0,0 +1,219 @@
+package verification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	go_ipay "github.com/stremovskyy/go-ipay"
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/ipaytest"
+)
+
+var merchant = &go_ipay.Merchant{MerchantID: "1", MerchantKey: "key"}
+
+func newManager() (*Manager, *ipaytest.Fake) {
+	fake := ipaytest.New()
+	cl := go_ipay.NewClient(go_ipay.WithClient(fake.Client()))
+
+	return NewManager(cl, merchant, NewMemoryStore()), fake
+}
+
+func start(t *testing.T, m *Manager, extID string, amount int) *Session {
+	t.Helper()
+
+	s, err := m.Start(t.Context(), &go_ipay.Request{
+		PaymentData: &go_ipay.PaymentData{PaymentID: utils.Ref(extID), Amount: amount, Currency: "UAH", VerifyWithAmount: amount > 0},
+	})
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	if s.State != StatePending || s.URL == "" || s.PaymentID == 0 {
+		t.Fatalf("Start() = %+v", s)
+	}
+
+	return s
+}
+
+func TestManager_NoAmountFromWebhook(t *testing.T) {
+	m, fake := newManager()
+	s := start(t, m, "verify-1", 0)
+
+	p, err := fake.Complete(s.PaymentID, ipaytest.DefaultPan)
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	s, err = m.HandleWebhook(t.Context(), &ipay.Payment{
+		PmtId:      int(p.ID),
+		ExtID:      &p.ExtID,
+		Status:     p.Status,
+		CardToken:  &p.CardToken,
+		CardMask:   &p.CardMask,
+		Use3DS:     utils.Ref(true),
+		ValidTaxID: 1,
+		BankName:   utils.Ref("Test Bank"),
+	})
+	if err != nil {
+		t.Fatalf("HandleWebhook() error: %v", err)
+	}
+	if s.State != StateVerified || s.WithAmount || s.Reversed {
+		t.Fatalf("HandleWebhook() = %+v", s)
+	}
+	if r := s.Result; r.CardToken != p.CardToken || r.CardMask != p.CardMask || !r.Use3DS || !r.ValidTaxID || r.Bank != "Test Bank" {
+		t.Fatalf("Result = %+v", r)
+	}
+
+	if _, err := m.HandleWebhook(t.Context(), &ipay.Payment{ExtID: utils.Ref("order-1")}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("HandleWebhook(unknown) error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestManager_WithAmountIsReversed(t *testing.T) {
+	m, fake := newManager()
+	s := start(t, m, "verify-2", 100)
+
+	if p, _ := fake.Payment(s.PaymentID); p.Invoice != 100 {
+		t.Fatalf("verification invoice = %d, want 100", p.Invoice)
+	}
+
+	if s, err := m.Resolve(t.Context(), "verify-2"); err != nil || s.State != StatePending {
+		t.Fatalf("Resolve() before the customer = %+v, %v", s, err)
+	}
+
+	if _, err := fake.Complete(s.PaymentID, ipaytest.DefaultPan); err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	s, err := m.Resolve(t.Context(), "verify-2")
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if s.State != StateVerified || !s.Reversed || s.Amount.Amount != 100 {
+		t.Fatalf("Resolve() = %+v", s)
+	}
+	if p, _ := fake.Payment(s.PaymentID); p.Status != ipay.PaymentStatusCanceled || p.Refunded != 100 {
+		t.Fatalf("verification charge = %+v, want it reversed", p)
+	}
+}
+
+func TestManager_Declined(t *testing.T) {
+	m, fake := newManager()
+	s := start(t, m, "verify-3", 100)
+
+	if _, err := fake.Complete(s.PaymentID, "3333333333333349"); err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	s, err := m.Resolve(t.Context(), "verify-3")
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if s.State != StateFailed || s.Reversed || s.Result.BankError == "" {
+		t.Fatalf("Resolve() = %+v, %+v", s, s.Result)
+	}
+}
+
+func TestManager_SuccessWithoutClaimIsReversed(t *testing.T) {
+	m, fake := newManager()
+	s := start(t, m, "verify-4", 100)
+
+	if _, err := fake.Complete(s.PaymentID, ipaytest.DefaultPan); err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+	if err := fake.SetStatus(s.PaymentID, ipay.PaymentStatusSuccessWithoutClaim); err != nil {
+		t.Fatalf("SetStatus() error: %v", err)
+	}
+
+	s, err := m.Resolve(t.Context(), "verify-4")
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if s.State != StateVerified || !s.Reversed {
+		t.Fatalf("Resolve() = %+v", s)
+	}
+	if p, _ := fake.Payment(s.PaymentID); p.Refunded != 100 {
+		t.Fatalf("verification charge = %+v, want it reversed", p)
+	}
+}
+
+func TestManager_ReversesByExtID(t *testing.T) {
+	m, fake := newManager()
+	s := start(t, m, "verify-5", 100)
+	pmtID := s.PaymentID
+
+	// A session saved before its pmt_id was known, settled by a notification without one.
+	s.PaymentID = 0
+	if err := m.store.Save(t.Context(), *s); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	p, err := fake.Complete(pmtID, ipaytest.DefaultPan)
+	if err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	s, err = m.HandleWebhook(t.Context(), &ipay.Payment{ExtID: &p.ExtID, Status: p.Status})
+	if err != nil {
+		t.Fatalf("HandleWebhook() error: %v", err)
+	}
+	if s.State != StateVerified || !s.Reversed || s.PaymentID != pmtID {
+		t.Fatalf("HandleWebhook() = %+v", s)
+	}
+	if p, _ := fake.Payment(pmtID); p.Refunded != 100 {
+		t.Fatalf("verification charge = %+v, want it reversed", p)
+	}
+}
+
+func TestManager_UsesMerchantOfStart(t *testing.T) {
+	fake := ipaytest.New()
+	var mchIDs []int64
+	rt := teststand.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		var wrapper ipay.RequestWrapper
+		if err := json.Unmarshal(body, &wrapper); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		mchIDs = append(mchIDs, utils.Deref(wrapper.Request.Auth.MchID))
+
+		return fake.RoundTrip(req)
+	})
+	cl := go_ipay.NewClient(go_ipay.WithClient(&http.Client{Transport: rt}))
+	store := NewMemoryStore()
+	other := &go_ipay.Merchant{MerchantID: "2", MerchantKey: "other"}
+
+	s, err := NewManager(cl, merchant, store).Start(t.Context(), &go_ipay.Request{
+		Merchant:    other,
+		PaymentData: &go_ipay.PaymentData{PaymentID: utils.Ref("verify-6"), Amount: 100, Currency: "UAH", VerifyWithAmount: true},
+	})
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	if _, err := fake.Complete(s.PaymentID, ipaytest.DefaultPan); err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+
+	// A manager of another process does not know the merchant until it is registered.
+	if _, err := NewManager(cl, merchant, store).Resolve(t.Context(), "verify-6"); !errors.Is(err, ErrUnknownMerchant) {
+		t.Fatalf("Resolve() error = %v, want %v", err, ErrUnknownMerchant)
+	}
+
+	mchIDs = nil
+	s, err = NewManager(cl, merchant, store, WithMerchants(other)).Resolve(t.Context(), "verify-6")
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if !s.Reversed {
+		t.Fatalf("Resolve() = %+v", s)
+	}
+	if len(mchIDs) != 3 || mchIDs[0] != 2 || mchIDs[1] != 2 || mchIDs[2] != 2 {
+		t.Fatalf("mch_id of status, balance lookup and reversal = %v, want 2", mchIDs)
+	}
+}
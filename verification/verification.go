@@ -0,0 +1,100 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package verification follows card verifications started with VerificationLink until their
+// outcome is known, and reverses the charge of a verification made with an amount.
+package verification
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/currency"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// State is the stage of a verification session.
+type State string
+
+const (
+	// StatePending means the customer has not finished the verification page yet.
+	StatePending State = "pending"
+	// StateVerified means the card passed the verification.
+	StateVerified State = "verified"
+	// StateFailed means the card was declined or the verification was canceled.
+	StateFailed State = "failed"
+)
+
+// ErrNotFound is returned by Store.Get when no session has the ext_id.
+var ErrNotFound = errors.New("verification: not found")
+
+// ErrUnknownMerchant is returned when a session was started with a merchant the Manager does not
+// know; register it with WithMerchants.
+var ErrUnknownMerchant = errors.New("verification: unknown merchant")
+
+// Session is a card verification tracked by its ext_id.
+type Session struct {
+	ExtID      string
+	PaymentID  int64
+	MerchantID string
+	// URL is the verification page the customer is sent to.
+	URL   string
+	State State
+	// WithAmount is set when the card is verified by charging Amount, which is reversed once the
+	// verification passes.
+	WithAmount bool
+	Amount     currency.Money
+	// Reversed is set once the verification charge has been returned.
+	Reversed bool
+	// Result is filled in once the verification has a final status.
+	Result *Result
+	// LastError is the error of the last failed reversal.
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Result is what the verification found out about the card.
+type Result struct {
+	Status    ipay.PaymentStatus
+	CardToken string
+	CardMask  string
+	CardType  string
+	Bank      string
+	// Use3DS is set when the customer passed 3-D Secure.
+	Use3DS bool
+	// ValidTaxID is set when iPay accepted the tax ID sent with the request.
+	ValidTaxID bool
+	// BankError is the reason of a decline, e.g. "42-insufficient_funds".
+	BankError string
+}
+
+// Store persists verification sessions. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save creates or replaces the session with the same ExtID.
+	Save(ctx context.Context, session Session) error
+	// Get returns the session with the ext_id or ErrNotFound.
+	Get(ctx context.Context, extID string) (*Session, error)
+}
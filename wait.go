@@ -0,0 +1,270 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package go_ipay
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/internal/http"
+	"github.com/stremovskyy/go-ipay/internal/utils"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// PollPolicy controls how WaitForFinalStatus polls iPay.
+type PollPolicy struct {
+	// InitialInterval is the delay before the second status request.
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between status requests.
+	MaxInterval time.Duration
+	// Multiplier grows the delay after every request.
+	Multiplier float64
+	// Jitter is the fraction (0..1) of every delay that is randomized.
+	Jitter float64
+	// A2C polls with A2CPaymentStatus instead of Status. Set it when waiting for a Credit.
+	A2C bool
+	// Notifier, when set, ends the wait as soon as it receives a final status for the payment.
+	Notifier *StatusNotifier
+	// OnTransition is called whenever the observed status changes, starting with the first one.
+	OnTransition func(StatusTransition)
+}
+
+// DefaultPollPolicy polls after about 1s, doubling the delay up to 30s.
+func DefaultPollPolicy() PollPolicy {
+	return PollPolicy{
+		InitialInterval: time.Second,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+func (p PollPolicy) interval(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+// StatusSource tells where an observed status came from.
+type StatusSource string
+
+const (
+	StatusSourcePoll    StatusSource = "poll"
+	StatusSourceWebhook StatusSource = "webhook"
+)
+
+// StatusTransition is a change of the payment status seen by WaitForFinalStatus.
+type StatusTransition struct {
+	// From is the previous status, PaymentStatusUnknown for the first observation.
+	From ipay.PaymentStatus
+	// To is the new status.
+	To ipay.PaymentStatus
+	// Source is the poll or the webhook that reported To.
+	Source StatusSource
+	// Result is the payment as reported by Source.
+	Result *ipay.StatusResult
+	// At is the time the status was observed.
+	At time.Time
+	// Err is set when the change breaks the payment state machine, see
+	// ipay.PaymentStatus.CheckTransition. The status is still taken, as iPay reported it.
+	Err error
+}
+
+// StatusNotifier hands verified webhook payments to running WaitForFinalStatus calls. Feed it from
+// the webhook handler, which only dispatches notifications with a valid signature:
+//
+//	handler.OnAny(func(ctx context.Context, e *webhook.Event) error {
+//		notifier.Notify(e.Payment)
+//		return nil
+//	})
+type StatusNotifier struct {
+	mu       sync.Mutex
+	watchers map[*statusWatcher]struct{}
+}
+
+type statusWatcher struct {
+	paymentID int64
+	extID     string
+	payments  chan *ipay.Payment
+}
+
+// NewStatusNotifier returns a notifier with no waiters.
+func NewStatusNotifier() *StatusNotifier {
+	return &StatusNotifier{watchers: make(map[*statusWatcher]struct{})}
+}
+
+// Notify delivers payment to the waiters whose request matches its pmt_id or ext_id. It never
+// blocks; a waiter that has not consumed the previous notification keeps polling instead.
+func (n *StatusNotifier) Notify(payment *ipay.Payment) {
+	if payment == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for w := range n.watchers {
+		if !w.matches(payment) {
+			continue
+		}
+
+		select {
+		case w.payments <- payment:
+		default:
+		}
+	}
+}
+
+func (n *StatusNotifier) watch(request *Request) (<-chan *ipay.Payment, func()) {
+	w := &statusWatcher{
+		paymentID: request.GetIpayPaymentID(),
+		extID:     utils.Deref(request.GetPaymentID()),
+		payments:  make(chan *ipay.Payment, 1),
+	}
+
+	n.mu.Lock()
+	n.watchers[w] = struct{}{}
+	n.mu.Unlock()
+
+	return w.payments, func() {
+		n.mu.Lock()
+		delete(n.watchers, w)
+		n.mu.Unlock()
+	}
+}
+
+func (w *statusWatcher) matches(p *ipay.Payment) bool {
+	if w.paymentID != 0 && (int64(p.PmtId) == w.paymentID || p.ID == w.paymentID) {
+		return true
+	}
+
+	return w.extID != "" && utils.Deref(p.ExtID) == w.extID
+}
+
+// WaitForFinalStatus polls the payment status until it is final (see ipay.PaymentStatus.IsFinal)
+// or ctx is done. The request identifies the payment the same way as for Status. Status requests
+// that fail with a retryable error, such as a timeout or a transient iPay code, are repeated at
+// the next interval; other errors end the wait. When ctx ends first, the last observed result is
+// returned together with the context error.
+func (c *client) WaitForFinalStatus(ctx context.Context, request *Request, policy PollPolicy) (*ipay.StatusResult, error) {
+	if request == nil {
+		return nil, ErrRequestIsNil
+	}
+
+	var notifications <-chan *ipay.Payment
+	if policy.Notifier != nil {
+		var stop func()
+		notifications, stop = policy.Notifier.watch(request)
+		defer stop()
+	}
+
+	// observe records result and reports whether it was taken. A webhook the payment has already
+	// gone past is dropped; a poll answer is always taken, since it is iPay's current view.
+	var last *ipay.StatusResult
+	observe := func(result *ipay.StatusResult, source StatusSource) bool {
+		from := ipay.PaymentStatusUnknown
+		if last != nil {
+			from = last.Status
+		}
+
+		err := from.CheckTransition(result.Status)
+		if source == StatusSourceWebhook && errors.Is(err, ipay.ErrStaleStatus) {
+			return false
+		}
+
+		if policy.OnTransition != nil && (last == nil || from != result.Status) {
+			policy.OnTransition(StatusTransition{From: from, To: result.Status, Source: source, Result: result, At: time.Now(), Err: err})
+		}
+
+		last = result
+
+		return true
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := c.pollStatus(ctx, request, policy.A2C)
+		if result != nil {
+			observe(result, StatusSourcePoll)
+			if result.Status.IsFinal() {
+				return result, err
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return last, ctx.Err()
+			}
+			if !http.IsRetryable(ctx, 0, err) {
+				if result != nil {
+					return result, err
+				}
+
+				return last, err
+			}
+		}
+
+		timer := time.NewTimer(policy.interval(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return last, ctx.Err()
+		case payment := <-notifications:
+			timer.Stop()
+
+			result := &ipay.StatusResult{Result: ipay.Response{Pmt: payment}.Result()}
+			result.Status = payment.Status
+			if observe(result, StatusSourceWebhook) && result.Status.IsFinal() {
+				return result, nil
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *client) pollStatus(ctx context.Context, request *Request, a2c bool) (*ipay.StatusResult, error) {
+	if !a2c {
+		return c.StatusResultContext(ctx, request)
+	}
+
+	response, err := c.A2CPaymentStatusContext(ctx, request)
+
+	return typedResult(response, err, func(r ipay.Result) *ipay.StatusResult { return &ipay.StatusResult{Result: r} })
+}
@@ -0,0 +1,151 @@
+package go_ipay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/internal/teststand"
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+func statusSequence(statuses ...ipay.PaymentStatus) *http.Client {
+	var calls atomic.Int32
+
+	return &http.Client{Transport: teststand.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		i := min(int(calls.Add(1))-1, len(statuses)-1)
+		body := fmt.Sprintf(`{"response":{"pmt_id":10,"ext_id":"ext","status":%d}}`, statuses[i])
+
+		return teststand.Response(200, "application/json", []byte(body)), nil
+	})}
+}
+
+func fastPolling() PollPolicy {
+	return PollPolicy{InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Multiplier: 2}
+}
+
+func TestWaitForFinalStatus_ReportsTransitions(t *testing.T) {
+	cl := NewClient(WithClient(statusSequence(
+		ipay.PaymentStatusRegistered,
+		ipay.PaymentStatusRegistered,
+		ipay.PaymentStatusManualProcessing,
+		ipay.PaymentStatusSuccess,
+	)))
+
+	var seen []StatusTransition
+	policy := fastPolling()
+	policy.OnTransition = func(tr StatusTransition) { seen = append(seen, tr) }
+
+	result, err := cl.WaitForFinalStatus(t.Context(), paymentRequest(), policy)
+	if err != nil {
+		t.Fatalf("WaitForFinalStatus() error: %v", err)
+	}
+	if result.Status != ipay.PaymentStatusSuccess || result.PaymentID != 10 {
+		t.Fatalf("WaitForFinalStatus() = %+v, want the successful payment", result)
+	}
+
+	want := [][2]ipay.PaymentStatus{
+		{ipay.PaymentStatusUnknown, ipay.PaymentStatusRegistered},
+		{ipay.PaymentStatusRegistered, ipay.PaymentStatusManualProcessing},
+		{ipay.PaymentStatusManualProcessing, ipay.PaymentStatusSuccess},
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("transitions = %+v, want %v", seen, want)
+	}
+	for i, tr := range seen {
+		if tr.From != want[i][0] || tr.To != want[i][1] || tr.Source != StatusSourcePoll {
+			t.Fatalf("transition %d = %+v, want %v", i, tr, want[i])
+		}
+	}
+}
+
+func TestWaitForFinalStatus_ContextExpires(t *testing.T) {
+	cl := NewClient(WithClient(statusSequence(ipay.PaymentStatusRegistered)))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	result, err := cl.WaitForFinalStatus(ctx, paymentRequest(), fastPolling())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForFinalStatus() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if result == nil || result.Status != ipay.PaymentStatusRegistered {
+		t.Fatalf("WaitForFinalStatus() = %+v, want the last observed status", result)
+	}
+}
+
+func TestWaitForFinalStatus_Webhook(t *testing.T) {
+	cl := NewClient(WithClient(statusSequence(ipay.PaymentStatusRegistered)))
+	notifier := NewStatusNotifier()
+
+	var sources []StatusSource
+	policy := PollPolicy{InitialInterval: time.Hour, Notifier: notifier}
+	policy.OnTransition = func(tr StatusTransition) {
+		sources = append(sources, tr.Source)
+		if tr.Source == StatusSourcePoll {
+			extID := "other"
+			notifier.Notify(&ipay.Payment{PmtId: 11, ExtID: &extID, Status: ipay.PaymentStatusSuccess})
+			extID = "ext"
+			go notifier.Notify(&ipay.Payment{PmtId: 10, ExtID: &extID, Status: ipay.PaymentStatusSuccess})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	result, err := cl.WaitForFinalStatus(ctx, paymentRequest(), policy)
+	if err != nil {
+		t.Fatalf("WaitForFinalStatus() error: %v", err)
+	}
+	if result.Status != ipay.PaymentStatusSuccess || result.PaymentID != 10 {
+		t.Fatalf("WaitForFinalStatus() = %+v, want the notified payment", result)
+	}
+	if len(sources) != 2 || sources[1] != StatusSourceWebhook {
+		t.Fatalf("sources = %v, want [poll webhook]", sources)
+	}
+}
+
+func TestWaitForFinalStatus_RetryableErrors(t *testing.T) {
+	var calls int
+	rt := teststand.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, errors.New("i/o timeout")
+		case 2:
+			return teststand.Response(200, "application/json", []byte(`{"response":{"res_auth_code":908}}`)), nil
+		default:
+			return teststand.Response(200, "application/json", []byte(`{"response":{"pmt_id":10,"status":5}}`)), nil
+		}
+	})
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	result, err := cl.WaitForFinalStatus(t.Context(), paymentRequest(), fastPolling())
+	if err != nil {
+		t.Fatalf("WaitForFinalStatus() error: %v", err)
+	}
+	if result.Status != ipay.PaymentStatusSuccess || calls != 3 {
+		t.Fatalf("WaitForFinalStatus() = %+v after %d calls, want success after 3", result, calls)
+	}
+}
+
+func TestWaitForFinalStatus_PermanentError(t *testing.T) {
+	var calls int
+	rt := teststand.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		return teststand.Response(200, "application/json", []byte(`{"response":{"error":"Invalid sign"}}`)), nil
+	})
+	cl := NewClient(WithClient(&http.Client{Transport: rt}), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	var ierr *ipay.IpayError
+	if _, err := cl.WaitForFinalStatus(t.Context(), paymentRequest(), fastPolling()); !errors.As(err, &ierr) {
+		t.Fatalf("WaitForFinalStatus() error = %v, want an iPay error", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"time"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// EventType is the kind of a webhook event, derived from the payment status.
+type EventType string
+
+const (
+	EventPaymentRegistered       EventType = "payment.registered"
+	EventPaymentPreAuthorized    EventType = "payment.pre_authorized"
+	EventPaymentFailed           EventType = "payment.failed"
+	EventPaymentSucceeded        EventType = "payment.succeeded"
+	EventPaymentCanceled         EventType = "payment.canceled"
+	EventAMLRequired             EventType = "payment.aml_required"
+	EventManualProcessing        EventType = "payment.manual_processing"
+	EventPaymentSucceededNoClaim EventType = "payment.succeeded_without_claim"
+	EventSecurityRefusal         EventType = "payment.security_refusal"
+	EventUnknown                 EventType = "payment.unknown"
+)
+
+// Event is a verified iPay notification.
+type Event struct {
+	// Type is derived from Payment.Status.
+	Type EventType
+	// Payment is the parsed notification.
+	Payment *ipay.Payment
+	// Raw is the XML document as received.
+	Raw []byte
+	// ReceivedAt is the time the notification was received.
+	ReceivedAt time.Time
+}
+
+// EventTypeForStatus maps an iPay payment status to an event type.
+func EventTypeForStatus(status ipay.PaymentStatus) EventType {
+	switch status {
+	case ipay.PaymentStatusRegistered:
+		return EventPaymentRegistered
+	case ipay.PaymentStatusPreAuthorized:
+		return EventPaymentPreAuthorized
+	case ipay.PaymentStatusFailed:
+		return EventPaymentFailed
+	case ipay.PaymentStatusSuccess:
+		return EventPaymentSucceeded
+	case ipay.PaymentStatusCanceled:
+		return EventPaymentCanceled
+	case ipay.PaymentStatusAMLRequired:
+		return EventAMLRequired
+	case ipay.PaymentStatusManualProcessing:
+		return EventManualProcessing
+	case ipay.PaymentStatusSuccessWithoutClaim:
+		return EventPaymentSucceededNoClaim
+	case ipay.PaymentStatusSecurityRefusal:
+		return EventSecurityRefusal
+	default:
+		return EventUnknown
+	}
+}
@@ -0,0 +1,266 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2024 Anton Stremovskyy
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+	"github.com/stremovskyy/go-ipay/log"
+)
+
+// Acknowledgement is the body written back to iPay once a notification has been handled.
+const Acknowledgement = "OK"
+
+// DefaultMaxBodySize limits the size of a notification body.
+const DefaultMaxBodySize int64 = 1 << 20
+
+// HandlerFunc handles a single event. Returning an error makes the handler reply with
+// 500 and release the salt from the replay cache, so that iPay re-delivers the notification
+// later.
+type HandlerFunc func(ctx context.Context, event *Event) error
+
+// Handler is an http.Handler that verifies iPay notifications and dispatches them to callbacks.
+type Handler struct {
+	verifier    *ipay.WebhookVerifier
+	maxBodySize int64
+	now         func() time.Time
+	logger      *log.Logger
+
+	mu       sync.RWMutex
+	handlers map[EventType][]HandlerFunc
+	any      []HandlerFunc
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithMaxBodySize overrides DefaultMaxBodySize.
+func WithMaxBodySize(size int64) Option {
+	return func(h *Handler) {
+		if size > 0 {
+			h.maxBodySize = size
+		}
+	}
+}
+
+// WithClock overrides the clock used for Event.ReceivedAt.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		if now != nil {
+			h.now = now
+		}
+	}
+}
+
+// WithLogger sends the handler's logs to l instead of stderr.
+func WithLogger(l *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = log.NewSink(l).Logger("iPay Webhook:")
+	}
+}
+
+// WithLogSink sends the handler's logs to s, e.g. the LogSink of the client, so that they follow
+// its logger and level.
+func WithLogSink(s *log.Sink) Option {
+	return func(h *Handler) {
+		if s != nil {
+			h.logger = s.Logger("iPay Webhook:")
+		}
+	}
+}
+
+// NewHandler creates a Handler that accepts only notifications verified by verifier.
+func NewHandler(verifier *ipay.WebhookVerifier, opts ...Option) *Handler {
+	h := &Handler{
+		verifier:    verifier,
+		maxBodySize: DefaultMaxBodySize,
+		now:         time.Now,
+		logger:      log.NewSink(nil).Logger("iPay Webhook:"),
+		handlers:    make(map[EventType][]HandlerFunc),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+
+	return h
+}
+
+// On registers fn for events of type t. Callbacks run in registration order.
+func (h *Handler) On(t EventType, fn HandlerFunc) *Handler {
+	if fn == nil {
+		return h
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers[t] = append(h.handlers[t], fn)
+
+	return h
+}
+
+// OnAny registers fn for every event, after the type-specific callbacks.
+func (h *Handler) OnAny(fn HandlerFunc) *Handler {
+	if fn == nil {
+		return h
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.any = append(h.any, fn)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.verifier == nil {
+		h.logger.Error("webhook verifier is not configured")
+		http.Error(w, "webhook verifier is not configured", http.StatusInternalServerError)
+		return
+	}
+
+	raw, err := readNotification(r, h.maxBodySize)
+	if err != nil {
+		h.logger.Warning("cannot read notification: %v", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := ipay.ParsePaymentXML(raw)
+	if err != nil {
+		h.logger.Warning("cannot parse notification: %v", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.verifier.Verify(payment); err != nil {
+		// A replayed salt is a re-delivery of a notification that was already handled, e.g.
+		// because the acknowledgement was lost. It is acknowledged again so iPay stops retrying.
+		if errors.Is(err, ipay.ErrWebhookSaltReplayed) {
+			h.logger.With("pmt_id", payment.ID).Info("notification already handled, acknowledging again")
+			acknowledge(w)
+			return
+		}
+
+		h.logger.With("pmt_id", payment.ID).Warning("rejected notification: %v", err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	event := &Event{
+		Type:       EventTypeForStatus(payment.Status),
+		Payment:    payment,
+		Raw:        raw,
+		ReceivedAt: h.now(),
+	}
+
+	if err := h.dispatch(r.Context(), event); err != nil {
+		h.logger.With("pmt_id", payment.ID, "event", string(event.Type)).Error("notification handler failed for payment %d: %v", payment.ID, err)
+		h.verifier.Release(payment)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	acknowledge(w)
+}
+
+func acknowledge(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, Acknowledgement)
+}
+
+func (h *Handler) dispatch(ctx context.Context, event *Event) error {
+	h.mu.RLock()
+	callbacks := make([]HandlerFunc, 0, len(h.handlers[event.Type])+len(h.any))
+	callbacks = append(callbacks, h.handlers[event.Type]...)
+	callbacks = append(callbacks, h.any...)
+	h.mu.RUnlock()
+
+	var errs []error
+	for _, fn := range callbacks {
+		if err := fn(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// readNotification extracts the XML document from the request. iPay posts it either as the
+// "xml" (or "data") form field or as the raw request body.
+func readNotification(r *http.Request, maxBodySize int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(body)) > maxBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxBodySize)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("parse form: %w", err)
+		}
+
+		for _, field := range []string{"xml", "data"} {
+			if v := values.Get(field); v != "" {
+				body = []byte(v)
+				break
+			}
+		}
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '<' {
+		return nil, errors.New("body is not an XML document")
+	}
+
+	return body, nil
+}
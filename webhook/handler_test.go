@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/go-ipay/ipay"
+)
+
+// HMAC-SHA512("hello", "key")
+const testSign = "ff06ab36757777815c008d32c8e14a705b4e7bf310351a06a23b612dc4c7433e7757d20525a5593b71020ea2ee162d2311b247e9855862b270122419652c0c92"
+
+func notification(status, sign string) string {
+	return `<payment id="42"><status>` + status + `</status><salt>hello</salt><sign>` + sign + `</sign></payment>`
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		callbackErr error
+		wantStatus  int
+		wantEvent   EventType
+	}{
+		{
+			name:       "raw xml success",
+			method:     http.MethodPost,
+			body:       notification("5", testSign),
+			wantStatus: http.StatusOK,
+			wantEvent:  EventPaymentSucceeded,
+		},
+		{
+			name:        "form field",
+			method:      http.MethodPost,
+			contentType: "application/x-www-form-urlencoded",
+			body:        url.Values{"xml": {notification("4", testSign)}}.Encode(),
+			wantStatus:  http.StatusOK,
+			wantEvent:   EventPaymentFailed,
+		},
+		{
+			name:       "invalid signature",
+			method:     http.MethodPost,
+			body:       notification("5", strings.Repeat("0", 128)),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "not xml",
+			method:     http.MethodPost,
+			body:       "garbage",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "callback error",
+			method:      http.MethodPost,
+			body:        notification("3", testSign),
+			callbackErr: errors.New("db down"),
+			wantStatus:  http.StatusInternalServerError,
+			wantEvent:   EventPaymentPreAuthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got EventType
+			h := NewHandler(ipay.NewWebhookVerifier([]string{"key"})).OnAny(
+				func(_ context.Context, e *Event) error {
+					got = e.Type
+					return tt.callbackErr
+				},
+			)
+
+			req := httptest.NewRequest(tt.method, "/notify", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got != tt.wantEvent {
+				t.Fatalf("event = %q, want %q", got, tt.wantEvent)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != Acknowledgement {
+				t.Fatalf("body = %q, want %q", rec.Body.String(), Acknowledgement)
+			}
+		})
+	}
+}
+
+func TestHandler_OnDispatchesByType(t *testing.T) {
+	var succeeded, canceled int
+	h := NewHandler(ipay.NewWebhookVerifier([]string{"key"})).
+		On(EventPaymentSucceeded, func(context.Context, *Event) error { succeeded++; return nil }).
+		On(EventPaymentCanceled, func(context.Context, *Event) error { canceled++; return nil })
+
+	req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(notification("9", testSign)))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if succeeded != 0 || canceled != 1 {
+		t.Fatalf("succeeded = %d, canceled = %d, want 0 and 1", succeeded, canceled)
+	}
+}
+
+func TestHandler_RedeliveryAfterCallbackError(t *testing.T) {
+	verifier := ipay.NewWebhookVerifier([]string{"key"}, ipay.WithWebhookReplayCache(ipay.NewMemoryReplayCache(time.Minute)))
+
+	calls := 0
+	h := NewHandler(verifier).OnAny(func(context.Context, *Event) error {
+		calls++
+		if calls == 1 {
+			return errors.New("db down")
+		}
+		return nil
+	})
+
+	for i, want := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(notification("5", testSign))))
+
+		if rec.Code != want {
+			t.Fatalf("delivery %d: status = %d, want %d", i+1, rec.Code, want)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("callback ran %d times, want 2", calls)
+	}
+}
+
+func TestHandler_DuplicateIsAcknowledged(t *testing.T) {
+	verifier := ipay.NewWebhookVerifier([]string{"key"}, ipay.WithWebhookReplayCache(ipay.NewMemoryReplayCache(time.Minute)))
+
+	calls := 0
+	h := NewHandler(verifier).OnAny(func(context.Context, *Event) error { calls++; return nil })
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(notification("5", testSign))))
+
+		if rec.Code != http.StatusOK || rec.Body.String() != Acknowledgement {
+			t.Fatalf("delivery %d: %d %q, want %d %q", i+1, rec.Code, rec.Body.String(), http.StatusOK, Acknowledgement)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("callback ran %d times, want 1", calls)
+	}
+}